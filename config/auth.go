@@ -0,0 +1,121 @@
+package config
+
+import "time"
+
+// 找回密码：重置链接的有效期、两次申请的最短间隔以及前端重置页面地址，链接末尾附加 token
+var (
+	PasswordResetTTL      = 30 * time.Minute
+	PasswordResetCooldown = time.Minute
+	PasswordResetURL      = "http://localhost:8080/password/reset?token="
+)
+
+// 密码策略：最短长度、必须包含的字符类别，密码不能与账号相同，也不能是常见弱密码
+// CommonPasswordFile 为额外的弱密码列表文件，每行一个，为空时只使用内置列表
+var (
+	PasswordMinLength     = 8
+	PasswordRequireLetter = true
+	PasswordRequireUpper  = false
+	PasswordRequireLower  = false
+	PasswordRequireDigit  = true
+	PasswordRequireSymbol = false
+	CommonPasswordFile    = ""
+)
+
+// 新增和批量导入用户的初始密码，用户首次登录后必须修改
+var InitialPassword = "123456"
+
+// 管理员重置密码时生成的临时密码长度
+var TempPasswordLength = 12
+
+// 两步验证：TwoFactorKey 用于加密保存 TOTP 密钥，登录第二步的有效期，连续验证失败的次数上限及锁定时长
+// 拥有 TwoFactorRequiredPermissions 中任一权限的用户必须启用两步验证，列表为空时不强制
+var (
+	TwoFactorIssuer              = "竞赛管理系统"
+	TwoFactorKey                 = "your-two-factor-key"
+	TwoFactorChallengeTTL        = 5 * time.Minute
+	TwoFactorMaxAttempts         = 5
+	TwoFactorLockout             = 15 * time.Minute
+	TwoFactorRecoveryCodes       = 10
+	TwoFactorRequiredPermissions = []string{"role:update", "permission:add", "permission:update", "permission:delete"}
+)
+
+// 单点登录（OIDC、CAS）公共配置：登录完成后跳转到前端 SSOPostLoginURL，结果通过查询参数传递
+// SSOIdentityValues 将身份提供方返回的用户类型映射为 student/teacher
+// SSOProvision 为 true 时首次登录自动创建学生/教师档案，角色为默认角色；只有身份提供方明确给出用户类型时才会创建
+// SSODeniedRoles 中的角色（默认超级管理员）不能通过单点登录登录，也不会自动创建这些角色的账户；
+// 这些角色的账户始终使用本地密码登录，不受 AuthProviders 中 ldap/cas/oidc 的影响
+var (
+	SSOPostLoginURL   = "http://localhost:8080/sso"
+	SSOIdentityValues = map[string]string{"student": "student", "undergraduate": "student", "graduate": "student", "teacher": "teacher", "faculty": "teacher", "staff": "teacher"}
+	SSOProvision      = true
+	SSODeniedRoles    = []int{1}
+)
+
+// OpenID Connect 单点登录：身份提供方地址、客户端信息及回调地址
+// 本地测试时可将 OIDCIssuer 指向本机的模拟身份提供方（http 地址即可）
+var (
+	OIDCEnabled      = false
+	OIDCIssuer       = "http://localhost:9000"
+	OIDCClientID     = "competition-server"
+	OIDCClientSecret = "your-client-secret"
+	OIDCRedirectURL  = "http://localhost:3000/auth/oidc/callback"
+	OIDCScopes       = []string{"openid", "profile", "email"}
+)
+
+// OIDC 声明映射：OIDCAccountClaim 对应学号/工号（Students.SID/Teachers.TID），必须是用户不能自行修改的声明，
+// 默认为 sub；身份提供方的 sub 不是学号/工号时改为其提供的学工号声明，不能使用 preferred_username、email 等可修改的声明
+// OIDCIdentityClaim 为用户类型，缺少该声明时使用 OIDCDefaultIdentity（为空则拒绝登录）
+// OIDCGenderClaim 取值 male/female，缺少时按女生 0 处理（与批量导入一致）
+var (
+	OIDCAccountClaim    = "sub"
+	OIDCIdentityClaim   = "user_type"
+	OIDCDefaultIdentity = ""
+	OIDCNameClaim       = "name"
+	OIDCEmailClaim      = "email"
+	OIDCGenderClaim     = "gender"
+)
+
+// AuthProviders 按身份类型选择登录方式：local（本地密码）、ldap（LDAP 绑定）、cas（跳转 CAS 统一认证）、
+// oidc（禁用账号密码登录，只能通过 OIDC 单点登录）
+var AuthProviders = map[string]string{
+	"student": "local",
+	"teacher": "local",
+}
+
+// LDAP 认证：LDAPURL 为 ldaps:// 时直接使用 TLS，为 ldap:// 时 LDAPStartTLS 为 true 则先升级为 TLS
+// 关闭 LDAPStartTLS 会以明文传输密码，只应在本机测试时使用；LDAPCAFile 为校验服务器证书的 CA 证书，为空时使用系统证书
+// LDAPUserDN 为各身份类型的用户 DN 模板，%s 替换为账号
+var (
+	LDAPURL      = "ldaps://localhost:636"
+	LDAPStartTLS = true
+	LDAPCAFile   = ""
+	LDAPTimeout  = 5 * time.Second
+	LDAPUserDN   = map[string]string{
+		"student": "uid=%s,ou=students,dc=example,dc=com",
+		"teacher": "uid=%s,ou=teachers,dc=example,dc=com",
+	}
+)
+
+// CAS 认证：CAS 服务器地址、本系统的回调地址，以及从 CAS 属性中读取姓名、邮箱和用户类型的属性名
+// CASIdentityAttribute 为空时 CAS 不提供用户类型，只允许已存在的账户登录
+var (
+	CASServerURL         = "http://localhost:8443/cas"
+	CASServiceURL        = "http://localhost:3000/auth/cas/callback"
+	CASNameAttribute     = "cn"
+	CASEmailAttribute    = "mail"
+	CASIdentityAttribute = ""
+)
+
+// 登录会话：令牌有效期，最近访问时间的更新间隔，以及过期或注销的会话保留多久后清理
+var (
+	SessionTTL           = 7 * 24 * time.Hour
+	SessionTouchInterval = time.Minute
+	SessionPurgeInterval = 24 * time.Hour
+	SessionRetention     = 30 * 24 * time.Hour
+)
+
+// 模拟登录：模拟会话的有效期，ImpersonationReadOnly 为 true 时模拟期间只允许查询，不能修改数据
+var (
+	ImpersonationTTL      = 30 * time.Minute
+	ImpersonationReadOnly = true
+)
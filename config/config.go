@@ -10,6 +10,24 @@ import (
 
 var CookieKey = "your-cookie-key"
 
+// StatsCacheTTL 统计结果的缓存时间
+var StatsCacheTTL = 5 * time.Minute
+
+// 回收站：比赛、参赛记录、学生和教师删除后保留 RecycleRetention，到期由定时任务彻底删除
+// 其余数据不软删除，原因见 services/recycle.go
+var (
+	RecyclePurgeEnabled  = true
+	RecyclePurgeInterval = 24 * time.Hour
+	RecycleRetention     = 30 * 24 * time.Hour
+)
+
+// 比赛截止提醒：每隔 DeadlineReminderInterval 检查一次，在截止前 DeadlineReminderAhead 内提醒已报名的学生
+var (
+	DeadlineReminderEnabled  = true
+	DeadlineReminderInterval = time.Hour
+	DeadlineReminderAhead    = 3 * 24 * time.Hour
+)
+
 type ValidationError struct {
 	Message string
 }
@@ -45,6 +63,17 @@ func InitDB() {
 	log.Println("用户信息同步成功")
 }
 
+// DefaultRoleID 新用户的默认角色：学生为 3，教师为 4，学生账号 admin 为超级管理员 1
+func DefaultRoleID(identity, account string) int {
+	if identity == "teacher" {
+		return 4
+	}
+	if account == "admin" {
+		return 1
+	}
+	return 3
+}
+
 func SyncUsers(db *gorm.DB) error {
 	// 获取所有学生信息
 	var students []models.Students
@@ -70,15 +99,11 @@ func SyncUsers(db *gorm.DB) error {
 			continue
 		}
 
-		roleID := 3 // 默认角色ID为3
-		if student.SID == "admin" {
-			roleID = 1
-		}
 		users = append(users, models.User{
 			Account:   student.SID,
 			Password:  student.Password,
 			Identity:  "student",
-			RoleID:    roleID,
+			RoleID:    DefaultRoleID("student", student.SID),
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		})
@@ -97,7 +122,7 @@ func SyncUsers(db *gorm.DB) error {
 			Account:   teacher.TID,
 			Password:  teacher.Password,
 			Identity:  "teacher",
-			RoleID:    4, // 教师角色ID默认设置为4
+			RoleID:    DefaultRoleID("teacher", teacher.TID),
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		})
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// 邮件发送配置，MailBackend 可选 smtp 或 log（只写日志不发送，开发使用）
+// 测试时可将 SMTP 地址指向本地的模拟 SMTP 服务（如 MailHog 的 localhost:1025），用户名为空时不进行认证
+var (
+	MailBackend  = "log"
+	SMTPHost     = "localhost"
+	SMTPPort     = 1025
+	SMTPUsername = ""
+	SMTPPassword = ""
+	MailFrom     = "竞赛管理系统 <noreply@example.com>"
+
+	// 默认邮件语言，可选 zh 或 en
+	DefaultMailLang = "zh"
+)
+
+// 邮件发送队列：每隔 MailQueueInterval 发送一批，失败后按 MailRetryInterval 的倍数退避重试，超过 MailMaxAttempts 次标记为失败
+var (
+	MailQueueInterval = 30 * time.Second
+	MailQueueBatch    = 50
+	MailRetryInterval = time.Minute
+	MailMaxAttempts   = 5
+)
+
+// 邮箱验证：验证链接的有效期、两次发送的最短间隔以及前端验证页面地址，链接末尾附加 token
+var (
+	EmailVerifyTTL      = 24 * time.Hour
+	EmailVerifyCooldown = time.Minute
+	EmailVerifyURL      = "http://localhost:8080/email/verify?token="
+)
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// TeacherPointRules 教师指导获奖积分规则：比赛级别 -> 获奖等级 -> 积分
+// 比赛级别 1 校级、2 省级、3 国家级；未列出的获奖等级按 TeacherPointDefault 计分
+var TeacherPointRules = map[int]map[string]float64{
+	1: {"一等奖": 3, "二等奖": 2, "三等奖": 1, "优秀奖": 0.5},
+	2: {"一等奖": 8, "二等奖": 6, "三等奖": 4, "优秀奖": 2},
+	3: {"特等奖": 30, "一等奖": 20, "二等奖": 15, "三等奖": 10, "优秀奖": 5},
+}
+
+// TeacherPointDefault 获奖等级不在规则中时的积分
+var TeacherPointDefault = 0.0
+
+// RaceLevelNames 比赛级别名称
+var RaceLevelNames = map[int]string{1: "校级", 2: "省级", 3: "国家级"}
+
+// AcademicYearStartMonth 学年开始的月份，此月之前的日期属于上一学年
+var AcademicYearStartMonth = time.September
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"net/http"
+	"time"
+)
+
+// Cookie 安全设置：登录令牌等 Cookie 默认 Secure 且 SameSite=Lax，本地 HTTP 调试可将 CookieSecure 改为 false
+// SameSite 不能设为 Strict，否则统一身份认证跳转回来时浏览器不携带会话 Cookie
+var (
+	CookieSecure   = true
+	CookieSameSite = http.SameSiteLaxMode
+	CookieDomain   = ""
+)
+
+// CSRF 双重提交校验：CSRFCookieName 的 Cookie 可被前端读取，修改类请求需在 CSRFHeaderName 请求头中带上相同的值
+// 使用 API 密钥（Authorization: Bearer）的请求不携带 Cookie，无需校验；CSRFExemptPaths 为免校验的路径前缀
+var (
+	CSRFCookieName  = "csrf_token"
+	CSRFHeaderName  = "X-CSRF-Token"
+	CSRFTokenTTL    = 7 * 24 * time.Hour
+	CSRFExemptPaths = []string{"/storage/local/"}
+)
+
+// 安全响应头：HSTSMaxAge 为 0 时不发送 Strict-Transport-Security，ContentSecurityPolicy 为空时不发送 CSP
+var (
+	ContentSecurityPolicy = "default-src 'self'; img-src 'self' data: https:; style-src 'self' 'unsafe-inline'; frame-ancestors 'none'"
+	HSTSMaxAge            = 365 * 24 * time.Hour
+	FrameOptions          = "DENY"
+	ReferrerPolicy        = "no-referrer"
+)
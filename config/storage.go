@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+// 文件存储配置，StorageBackend 可选 qiniu（七牛云）或 local（本地磁盘，开发测试使用）
+var (
+	StorageBackend = "qiniu"
+
+	// 七牛云的访问密钥、桶名和域名
+	QiniuAccessKey = "your_access_key"
+	QiniuSecretKey = "your_secret_key"
+	QiniuBucket    = "your_bucket"
+	QiniuDomain    = "your_domain"
+
+	// 本地存储的根目录、对外访问地址以及签名链接使用的密钥
+	LocalStorageDir     = "./storage"
+	LocalStorageBaseURL = "http://localhost:3000/storage/local"
+	LocalStorageSignKey = "your-storage-sign-key"
+)
+
+// UploadPolicy 上传用途对应的大小上限（字节）和允许的文件类型，文件类型按内容探测而不是扩展名
+type UploadPolicy struct {
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+// UploadPolicies 服务端上传支持的用途
+var UploadPolicies = map[string]UploadPolicy{
+	// 证明材料：获奖证书、现场照片等
+	"evidence": {MaxSize: 20 << 20, AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "application/pdf"}},
+	// 参赛报告、作品等
+	"report": {MaxSize: 50 << 20, AllowedTypes: []string{"application/pdf", "application/zip"}},
+	// 图片
+	"image": {MaxSize: 5 << 20, AllowedTypes: []string{"image/jpeg", "image/png"}},
+}
+
+// 孤立文件回收：定期删除未被任何附件或实体引用、且上传时间超过保留期的文件
+// 只扫描 FileGCPrefixes 下、且在 files 表中有记录的对象，没有记录的旧文件一律保留；
+// 默认关闭，启用前先通过回收报告（dry run）确认待删除的文件
+var (
+	FileGCEnabled     = false
+	FileGCInterval    = 24 * time.Hour
+	FileGCGracePeriod = 7 * 24 * time.Hour
+	FileGCPrefixes    = []string{"evidence/", "report/", "image/", "direct/", "certificate/"}
+)
+
+// 证书生成：字体文件需包含中文字形（如思源黑体），为空时使用内置的文泉驿微米黑，导出 PDF 报表也使用该字体；
+// CertificateVerifyURL 为证书上印制的验证地址前缀，后接验证码
+var (
+	CertificateFontPath  = ""
+	CertificateVerifyURL = "http://localhost:3000/certificate/verify/"
+)
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ListAttachments 查询参赛记录或比赛的证明材料
+func ListAttachments(c *gin.Context) {
+	ownerType := c.Query("owner_type")
+	ownerID, err := strconv.Atoi(c.Query("owner_id"))
+	if err != nil || ownerID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	if ok, msg := checkOwnerAccess(c, ownerType, ownerID, false); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": msg})
+		return
+	}
+
+	result := loadAttachments(ownerType, []int{ownerID})[ownerID]
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": len(result),
+		"data":  result,
+	})
+}
+
+// AddAttachment 将已上传到对象存储的文件关联到参赛记录或比赛
+func AddAttachment(c *gin.Context) {
+	var input struct {
+		OwnerType string `json:"owner_type"`
+		OwnerID   int    `json:"owner_id"`
+		Key       string `json:"key"`
+		Name      string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.OwnerID == 0 || input.Key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	if ok, msg := checkOwnerAccess(c, input.OwnerType, input.OwnerID, true); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": msg})
+		return
+	}
+
+	if ok, status, msg := checkFileAccess(c, input.Key, "query"); !ok {
+		c.JSON(status, gin.H{"code": status, "msg": msg})
+		return
+	}
+
+	// 附件记录本身就是文件的引用，同一文件可以被多个附件引用；已关联证书等实体的文件不能作为附件
+	var file models.Files
+	if err := config.DB.Where("object_key = ?", input.Key).Limit(1).Find(&file).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询文件失败"})
+		return
+	}
+	if file.LinkedType != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "文件已被其他对象使用"})
+		return
+	}
+
+	// 文件元数据以对象存储为准，不信任客户端传入的大小和类型
+	info, err := utils.GetFileInfo(input.Key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "文件不存在，请先上传"})
+		return
+	}
+
+	authUser, _ := c.Get("authenticatedUser")
+	attachment := models.Attachments{
+		OwnerType:  input.OwnerType,
+		OwnerID:    input.OwnerID,
+		ObjectKey:  input.Key,
+		Name:       input.Name,
+		Size:       info.Fsize,
+		Hash:       info.Hash,
+		MimeType:   info.MimeType,
+		Uploader:   authUser.(models.AuthenticatedUser).Account,
+		CreateTime: time.Now(),
+	}
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": attachment})
+}
+
+// DeleteAttachments 删除证明材料及其对应的存储对象
+func DeleteAttachments(c *gin.Context) {
+	var data []int
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	var attachments []models.Attachments
+	if err := config.DB.Where("id IN ?", data).Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询附件失败"})
+		return
+	}
+
+	var keys []string
+	for _, attachment := range attachments {
+		if ok, msg := checkOwnerAccess(c, attachment.OwnerType, attachment.OwnerID, true); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": msg})
+			return
+		}
+		keys = append(keys, attachment.ObjectKey)
+	}
+
+	if len(attachments) > 0 {
+		// 同一文件可能被多个附件引用，只删除不再被引用的文件
+		var orphanKeys []string
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&attachments).Error; err != nil {
+				return err
+			}
+			var referenced []string
+			if err := tx.Model(&models.Attachments{}).Where("object_key IN ?", keys).Distinct().Pluck("object_key", &referenced).Error; err != nil {
+				return err
+			}
+			inUse := make(map[string]bool)
+			for _, key := range referenced {
+				inUse[key] = true
+			}
+			for _, key := range keys {
+				if !inUse[key] {
+					orphanKeys = append(orphanKeys, key)
+				}
+			}
+			return nil
+		})
+		services.Audit(c, "file:delete", "attachment", intsToString(data), gin.H{"keys": keys}, err)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+			return
+		}
+		// 附件已删除，文件删除失败时保留文件记录，由孤立文件回收处理
+		if len(orphanKeys) > 0 {
+			if err := utils.DeleteFile(orphanKeys); err != nil {
+				log.Warn().Err(err).Strs("keys", orphanKeys).Msg("删除附件文件失败，将由孤立文件回收处理")
+			} else if err := config.DB.Where("object_key IN ?", orphanKeys).Delete(&models.Files{}).Error; err != nil {
+				log.Warn().Err(err).Strs("keys", orphanKeys).Msg("删除附件文件记录失败")
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
+
+// intsToString 将 ID 列表拼接为逗号分隔的字符串
+func intsToString(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// checkOwnerAccess 检查当前用户能否查看/修改某条记录或比赛的附件
+// 学生只能操作自己的参赛记录，其余情况需要对应的 query/update 权限
+func checkOwnerAccess(c *gin.Context, ownerType string, ownerID int, write bool) (bool, string) {
+	action := "query"
+	if write {
+		action = "update"
+	}
+
+	switch ownerType {
+	case models.AttachmentOwnerRecord:
+		var record models.Records
+		if err := config.DB.Where("record_id = ?", ownerID).First(&record).Error; err != nil {
+			return false, "记录不存在"
+		}
+		if checkPermission(c, "record:"+action) {
+			return true, ""
+		}
+		user, exists := c.Get("authenticatedUser")
+		if exists {
+			authUser := user.(models.AuthenticatedUser)
+			if authUser.Identity == "student" && authUser.Account == record.SID {
+				return true, ""
+			}
+		}
+		return false, "暂无权限"
+	case models.AttachmentOwnerRace:
+		if err := config.DB.Where("race_id = ?", ownerID).First(&models.Races{}).Error; err != nil {
+			return false, "比赛不存在"
+		}
+		if checkPermission(c, "race:"+action) {
+			return true, ""
+		}
+		return false, "暂无权限"
+	default:
+		return false, "未知的附件类型"
+	}
+}
+
+// loadAttachments 批量加载附件元数据，并为每个附件生成短期有效的下载链接
+func loadAttachments(ownerType string, ownerIDs []int) map[int][]map[string]interface{} {
+	result := make(map[int][]map[string]interface{})
+	if len(ownerIDs) == 0 {
+		return result
+	}
+
+	var attachments []models.Attachments
+	config.DB.Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).Order("create_time ASC").Find(&attachments)
+	for _, attachment := range attachments {
+		result[attachment.OwnerID] = append(result[attachment.OwnerID], map[string]interface{}{
+			"id":          attachment.ID,
+			"name":        attachment.Name,
+			"key":         attachment.ObjectKey,
+			"size":        attachment.Size,
+			"hash":        attachment.Hash,
+			"mime_type":   attachment.MimeType,
+			"uploader":    attachment.Uploader,
+			"create_time": attachment.CreateTime,
+			"url":         utils.GetFileUrl(attachment.ObjectKey),
+		})
+	}
+	return result
+}
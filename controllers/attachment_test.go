@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAttachmentsShareFileWithoutOverwritingLinks(t *testing.T) {
+	testutil.OpenDB(t)
+	for _, id := range []int{1, 2} {
+		config.DB.Create(&models.Records{RecordID: id, SID: "20210001", RaceID: id, CreateTime: time.Now()})
+	}
+	r := fileRouter(t, models.AuthenticatedUser{Account: "20210001", Identity: "student"})
+	r.POST("/attachment/add", AddAttachment)
+	r.POST("/attachment/delete", DeleteAttachments)
+
+	content := []byte("%PDF-1.4 evidence")
+	if w := upload(r, "evidence", "a.pdf", content); w.Code != http.StatusOK {
+		t.Fatalf("上传应成功，得到 %d %s", w.Code, w.Body)
+	}
+	sum := sha256.Sum256(content)
+	key := "evidence/20210001/" + hex.EncodeToString(sum[:]) + ".pdf"
+
+	// 同一文件作为两条记录的附件
+	var ids []int
+	for _, recordID := range []int{1, 2} {
+		_, resp := postJSON(t, r, "/attachment/add", gin.H{"owner_type": "record", "owner_id": recordID, "key": key, "name": "a.pdf"})
+		if resp.Code != 200 {
+			t.Fatalf("添加附件应成功，得到 %d %s", resp.Code, resp.Msg)
+		}
+		ids = append(ids, int(resp.Data["id"].(float64)))
+	}
+	var file models.Files
+	config.DB.Where("object_key = ?", key).First(&file)
+	if file.LinkedType != "" {
+		t.Fatalf("附件不应写入文件的关联字段，得到 %s/%d", file.LinkedType, file.LinkedID)
+	}
+
+	// 已作为附件的文件不能再关联到证书模板
+	var validationErr *config.ValidationError
+	if err := linkFile(config.DB, key, "certificate_template", 1); !errors.As(err, &validationErr) {
+		t.Fatalf("已作为附件的文件不能关联其他对象，得到 %v", err)
+	}
+
+	// 删除其中一个附件后，另一个附件引用的文件仍保留
+	if _, resp := postJSON(t, r, "/attachment/delete", []int{ids[0]}); resp.Code != 200 {
+		t.Fatalf("删除附件应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if err := config.DB.Where("object_key = ?", key).First(&models.Files{}).Error; err != nil {
+		t.Fatal("仍被引用的文件记录不应删除")
+	}
+	if _, err := utils.GetFileInfo(key); err != nil {
+		t.Fatal("仍被引用的文件不应从存储中删除")
+	}
+
+	// 已关联证书模板的文件不能作为附件
+	config.DB.Create(&models.Files{ObjectKey: "certificate/bg.png", Owner: "20210001", LinkedType: "certificate_template", LinkedID: 1})
+	if _, resp := postJSON(t, r, "/attachment/add", gin.H{"owner_type": "record", "owner_id": 1, "key": "certificate/bg.png"}); resp.Code != 400 {
+		t.Fatalf("已关联其他对象的文件不能作为附件，得到 %d", resp.Code)
+	}
+	if err := linkFile(config.DB, "certificate/bg.png", "certificate_template", 2); !errors.As(err, &validationErr) {
+		t.Fatalf("不应覆盖其他模板的关联，得到 %v", err)
+	}
+	if err := linkFile(config.DB, "certificate/bg.png", "certificate_template", 1); err != nil {
+		t.Fatalf("重复关联同一对象应成功，得到 %v", err)
+	}
+}
+
+// failingDeleteStorage 模拟对象存储删除失败
+type failingDeleteStorage struct {
+	utils.Storage
+}
+
+func (failingDeleteStorage) Delete([]string) error {
+	return errors.New("storage unavailable")
+}
+
+func TestDeleteAttachmentSucceedsWhenStorageDeleteFails(t *testing.T) {
+	testutil.OpenDB(t)
+	config.DB.Create(&models.Records{RecordID: 1, SID: "20210001", RaceID: 1, CreateTime: time.Now()})
+	r := fileRouter(t, models.AuthenticatedUser{Account: "20210001", Identity: "student"})
+	r.POST("/attachment/add", AddAttachment)
+	r.POST("/attachment/delete", DeleteAttachments)
+
+	content := []byte("%PDF-1.4 evidence")
+	if w := upload(r, "evidence", "a.pdf", content); w.Code != http.StatusOK {
+		t.Fatalf("上传应成功，得到 %d %s", w.Code, w.Body)
+	}
+	sum := sha256.Sum256(content)
+	key := "evidence/20210001/" + hex.EncodeToString(sum[:]) + ".pdf"
+	_, resp := postJSON(t, r, "/attachment/add", gin.H{"owner_type": "record", "owner_id": 1, "key": key, "name": "a.pdf"})
+	if resp.Code != 200 {
+		t.Fatalf("添加附件应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	id := int(resp.Data["id"].(float64))
+
+	// 附件已从数据库删除，存储删除失败不影响结果，文件记录保留给孤立文件回收
+	utils.FileStorage = failingDeleteStorage{utils.FileStorage}
+	if _, resp := postJSON(t, r, "/attachment/delete", []int{id}); resp.Code != 200 {
+		t.Fatalf("数据库删除成功后应返回成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if err := config.DB.First(&models.Attachments{}, id).Error; err == nil {
+		t.Fatal("附件应已删除")
+	}
+	var file models.Files
+	if err := config.DB.Where("object_key = ?", key).First(&file).Error; err != nil || file.LinkedType != "" {
+		t.Fatalf("存储删除失败时应保留未关联的文件记录: %+v, %v", file, err)
+	}
+}
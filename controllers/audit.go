@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditLogs 查询审计日志，可按操作人、被模拟的账号、操作、路由、操作对象、结果和时间范围（yyyy-mm-dd，含起止日期）筛选
+func ListAuditLogs(c *gin.Context) {
+	var logs []models.AuditLogs
+	var count int64
+	query := config.DB.Model(&models.AuditLogs{})
+
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor = ?", actor)
+	}
+	if onBehalfOf := c.Query("on_behalf_of"); onBehalfOf != "" {
+		query = query.Where("on_behalf_of = ?", onBehalfOf)
+	}
+	if identity := c.Query("identity"); identity != "" {
+		query = query.Where("identity = ?", identity)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action LIKE ?", action+"%")
+	}
+	if route := c.Query("route"); route != "" {
+		query = query.Where("route LIKE ?", route+"%")
+	}
+	if method := c.Query("method"); method != "" {
+		query = query.Where("method = ?", method)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if outcome := c.Query("outcome"); outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if start := c.Query("start"); start != "" {
+		t, err := time.ParseInLocation("2006-01-02", start, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+			return
+		}
+		query = query.Where("create_time >= ?", t)
+	}
+	if end := c.Query("end"); end != "" {
+		t, err := time.ParseInLocation("2006-01-02", end, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+			return
+		}
+		query = query.Where("create_time < ?", t.AddDate(0, 0, 1))
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "1"))
+	if err := query.Count(&count).Order("id DESC").Limit(limit).Offset(limit * (offset - 1)).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": count,
+		"data":  logs,
+	})
+}
@@ -3,11 +3,15 @@ package controllers
 import (
 	"competition-server/config"
 	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/mojocn/base64Captcha"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/rs/zerolog/log"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -35,32 +39,152 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// 按身份类型选择认证方式，CAS 需要跳转到统一认证登录页；禁止单点登录的角色始终使用本地密码
 	var user models.User
-	if err := config.DB.Where("account = ? AND identity = ?", req.Account, req.Identity).First(&user).Error; err != nil {
+	found := config.DB.Where("account = ? AND identity = ?", req.Account, req.Identity).First(&user).Error == nil
+	provider, err := services.LoginProviderFor(req.Identity, user.RoleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "认证方式配置有误"})
+		return
+	}
+	if !provider.PasswordLogin() {
+		loginURL := "/auth/oidc/login"
+		if _, ok := provider.(*services.CASProvider); ok {
+			loginURL = "/auth/cas/login?identity=" + url.QueryEscape(req.Identity)
+		}
+		c.JSON(http.StatusForbidden, gin.H{"code": 7, "msg": "请使用统一身份认证登录", "data": gin.H{"url": loginURL}})
+		return
+	}
+
+	if !found {
 		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "用户不存在"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	_, err = provider.Authenticate(services.Credentials{Identity: req.Identity, Account: req.Account, Password: req.Password})
+	if errors.Is(err, services.ErrInvalidCredentials) {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 2, "msg": "密码错误"})
 		return
 	}
+	if err != nil {
+		log.Error().Err(err).Str("account", req.Account).Msg("认证服务请求失败")
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": "认证服务不可用"})
+		return
+	}
+
+	// 已启用两步验证时先不签发登录令牌，返回临时令牌用于提交验证码
+	enabled, err := services.TwoFactorEnabled(user.Account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询两步验证失败"})
+		return
+	}
+	if enabled {
+		challenge, err := twoFactorChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成令牌失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 5, "msg": "请输入两步验证码", "data": gin.H{"challenge": challenge}})
+		return
+	}
+	loginSuccess(c, user, false)
+}
+
+// loginSuccess 签发登录令牌并返回登录后需要完成的操作
+func loginSuccess(c *gin.Context, user models.User, twoFactorEnabled bool) {
+	data, err := loginResult(c, user, twoFactorEnabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "登陆成功", "data": data})
+}
+
+// loginResult 签发登录令牌，返回登录后需要完成的操作：修改密码、启用两步验证
+func loginResult(c *gin.Context, user models.User, twoFactorEnabled bool) (gin.H, error) {
+	permissions, err := services.RolePermissions(user.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := issueToken(c, user.Account, user.Identity); err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"must_change_password":      user.MustChangePassword,
+		"two_factor_setup_required": !twoFactorEnabled && services.TwoFactorRequired(permissions),
+	}, nil
+}
 
-	exp := time.Now().Add(7 * 24 * time.Hour)
+// issueToken 创建登录会话，签发登录令牌并写入 Cookie
+// sid 对应服务端会话，注销会话后令牌立即失效；iat 用于在修改密码后使旧令牌失效
+func issueToken(c *gin.Context, account, identity string) error {
+	exp := time.Now().Add(config.SessionTTL)
+	sid, err := services.CreateSession(account, c.ClientIP(), c.Request.UserAgent(), exp)
+	if err != nil {
+		return err
+	}
+	return signToken(c, account, identity, sid, exp)
+}
+
+// signToken 为已有会话签发登录令牌并写入 Cookie，令牌与会话同时过期
+func signToken(c *gin.Context, account, identity, sid string, exp time.Time) error {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"account":  req.Account,
-		"identity": req.Identity,
+		"account":  account,
+		"identity": identity,
+		"sid":      sid,
+		"iat":      time.Now().Unix(),
 		"exp":      exp.Unix(),
 	})
 
 	tokenString, err := token.SignedString([]byte(TokenKey))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成令牌失败"})
+		return err
+	}
+	// 登录令牌只通过 HttpOnly Cookie 传递，前端脚本无法读取；登录状态变化时同时更换 CSRF 令牌
+	utils.SetCookie(c, "uid", tokenString, int(time.Until(exp).Seconds()), true)
+	_, err = utils.IssueCSRFToken(c)
+	return err
+}
+
+// ForgotPassword 申请找回密码，账号和已验证的邮箱匹配时发送重置链接
+// 无论是否匹配都返回相同的结果，避免泄露账号信息
+func ForgotPassword(c *gin.Context) {
+	var req struct {
+		Account string `json:"account" binding:"required"`
+		Email   string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	if err := services.RequestPasswordReset(req.Account, req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "申请失败"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "如果账号和邮箱匹配，重置链接将发送到该邮箱"})
+}
 
-	c.SetCookie("uid", tokenString, int(exp.Unix()), "/", "", false, false)
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "登陆成功"})
+// ResetPasswordByToken 通过邮件中的重置链接设置新密码，成功后该账户已登录的会话全部失效
+func ResetPasswordByToken(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	err := services.ResetPasswordByToken(req.Token, req.Password)
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "重置密码失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "密码重置成功，请重新登录"})
 }
 
 // GenerateCaptcha 生成验证码
@@ -72,7 +196,7 @@ func GenerateCaptcha(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成验证码失败"})
 		return
 	}
-	c.SetCookie("captchaAnswer", answer, 5*60, "/", "", false, true)
+	utils.SetCookie(c, "captchaAnswer", answer, 5*60, true)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "获取成功",
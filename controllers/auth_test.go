@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/services"
+	"competition-server/testutil"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// apiResponse 接口统一的响应格式
+type apiResponse struct {
+	Code int                    `json:"code"`
+	Msg  string                 `json:"msg"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// postJSON 以 JSON 提交请求，cookies 附加到请求中，返回响应及解析后的响应体
+func postJSON(t *testing.T, r *gin.Engine, path string, body interface{}, cookies ...*http.Cookie) (*httptest.ResponseRecorder, apiResponse) {
+	t.Helper()
+	raw, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp apiResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func loginRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/auth/login", Login)
+	r.POST("/auth/2fa/login", LoginTwoFactor)
+	return r
+}
+
+var captcha = &http.Cookie{Name: "captchaAnswer", Value: "abcd"}
+
+func login(t *testing.T, r *gin.Engine, account, password string) (*httptest.ResponseRecorder, apiResponse) {
+	t.Helper()
+	return postJSON(t, r, "/auth/login", gin.H{"account": account, "password": password, "identity": "student", "code": "abcd"}, captcha)
+}
+
+func TestLoginWithLocalPassword(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "20210001", "student", 3, "Passw0rd!")
+	r := loginRouter()
+
+	if _, resp := postJSON(t, r, "/auth/login", gin.H{"account": "20210001", "password": "Passw0rd!", "identity": "student", "code": "abcd"}); resp.Code != 3 {
+		t.Fatalf("缺少验证码 Cookie 应返回 3，得到 %d", resp.Code)
+	}
+	if _, resp := login(t, r, "nobody", "x"); resp.Code != 1 {
+		t.Fatalf("用户不存在应返回 1，得到 %d", resp.Code)
+	}
+	if w, resp := login(t, r, "20210001", "wrong"); resp.Code != 2 || hasCookie(w, "uid") {
+		t.Fatalf("密码错误应返回 2 且不签发令牌，得到 %d", resp.Code)
+	}
+
+	w, resp := login(t, r, "20210001", "Passw0rd!")
+	if resp.Code != 200 || !hasCookie(w, "uid") {
+		t.Fatalf("登录应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	sessions, err := services.ListSessions("20210001", "")
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("应创建一个登录会话，得到 %d, %v", len(sessions), err)
+	}
+}
+
+func TestLoginWithTwoFactor(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "20210001", "student", 3, "Passw0rd!")
+	secret, _, err := services.SetupTwoFactor("20210001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := utils.TOTPStep(time.Now())
+	code, _ := utils.TOTPCode(secret, step-1)
+	if _, err := services.EnableTwoFactor("20210001", code); err != nil {
+		t.Fatal(err)
+	}
+	r := loginRouter()
+
+	w, resp := login(t, r, "20210001", "Passw0rd!")
+	challenge, _ := resp.Data["challenge"].(string)
+	if resp.Code != 5 || challenge == "" || hasCookie(w, "uid") {
+		t.Fatalf("启用两步验证后应返回 5 和临时令牌且不签发登录令牌，得到 %d", resp.Code)
+	}
+
+	if w, resp := postJSON(t, r, "/auth/2fa/login", gin.H{"challenge": challenge, "code": "000000"}); w.Code != http.StatusBadRequest || hasCookie(w, "uid") {
+		t.Fatalf("验证码错误应被拒绝，得到 %d %s", w.Code, resp.Msg)
+	}
+	if w, _ := postJSON(t, r, "/auth/2fa/login", gin.H{"challenge": "forged", "code": code}); w.Code != http.StatusBadRequest {
+		t.Fatalf("伪造的临时令牌应被拒绝，得到 %d", w.Code)
+	}
+
+	code, _ = utils.TOTPCode(secret, step)
+	w, resp = postJSON(t, r, "/auth/2fa/login", gin.H{"challenge": challenge, "code": code})
+	if resp.Code != 200 || !hasCookie(w, "uid") {
+		t.Fatalf("验证码正确应登录成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if w, _ := postJSON(t, r, "/auth/2fa/login", gin.H{"challenge": challenge, "code": code}); hasCookie(w, "uid") {
+		t.Fatal("同一验证码不能再次登录")
+	}
+}
+
+func TestLoginRedirectsToSSOProvider(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "20210001", "student", 3, "Passw0rd!")
+	providers := config.AuthProviders
+	defer func() { config.AuthProviders = providers }()
+	r := loginRouter()
+
+	config.AuthProviders = map[string]string{"student": "oidc"}
+	w, resp := login(t, r, "20210001", "Passw0rd!")
+	if resp.Code != 7 || resp.Data["url"] != "/auth/oidc/login" || hasCookie(w, "uid") {
+		t.Fatalf("只允许单点登录时应返回 7 和 OIDC 登录地址，得到 %d %v", resp.Code, resp.Data)
+	}
+
+	config.AuthProviders = map[string]string{"student": "cas"}
+	if _, resp := login(t, r, "20210001", "Passw0rd!"); resp.Code != 7 || resp.Data["url"] != "/auth/cas/login?identity=student" {
+		t.Fatalf("CAS 认证应返回 7 和 CAS 登录地址，得到 %d %v", resp.Code, resp.Data)
+	}
+
+	if sessions, _ := services.ListSessions("20210001", ""); len(sessions) != 0 {
+		t.Fatal("不应创建登录会话")
+	}
+}
+
+func TestDeniedRolesAlwaysUseLocalPassword(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 1, "race:query")
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "admin", "student", 1, "Passw0rd!")
+	testutil.CreateUser(t, "20210001", "student", 3, "Passw0rd!")
+	providers, userDN := config.AuthProviders, config.LDAPUserDN
+	defer func() { config.AuthProviders, config.LDAPUserDN = providers, userDN }()
+	r := loginRouter()
+
+	// 超级管理员不能单点登录，也不能被跳转到单点登录，仍使用本地密码
+	for _, provider := range []string{"oidc", "cas"} {
+		config.AuthProviders = map[string]string{"student": provider}
+		if w, resp := login(t, r, "admin", "Passw0rd!"); resp.Code != 200 || !hasCookie(w, "uid") {
+			t.Fatalf("%s: 超级管理员应使用本地密码登录，得到 %d %s", provider, resp.Code, resp.Msg)
+		}
+		if _, resp := login(t, r, "20210001", "Passw0rd!"); resp.Code != 7 {
+			t.Fatalf("%s: 普通用户应跳转到单点登录，得到 %d", provider, resp.Code)
+		}
+	}
+
+	// LDAP 认证时超级管理员的密码也不交给 LDAP 校验；未配置用户 DN 时普通用户的 LDAP 认证失败
+	config.AuthProviders = map[string]string{"student": "ldap"}
+	config.LDAPUserDN = map[string]string{}
+	if _, resp := login(t, r, "admin", "wrong"); resp.Code != 2 {
+		t.Fatalf("超级管理员应校验本地密码，得到 %d", resp.Code)
+	}
+	if _, resp := login(t, r, "admin", "Passw0rd!"); resp.Code != 200 {
+		t.Fatalf("超级管理员应使用本地密码登录，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if _, resp := login(t, r, "20210001", "Passw0rd!"); resp.Code != 502 {
+		t.Fatalf("普通用户应使用 LDAP 认证，得到 %d %s", resp.Code, resp.Msg)
+	}
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"competition-server/config"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// casProvider 返回身份类型对应的 CAS 认证方式，未配置为 CAS 时返回 nil
+func casProvider(identity string) *services.CASProvider {
+	provider, err := services.AuthProviderFor(identity)
+	if err != nil {
+		return nil
+	}
+	cas, _ := provider.(*services.CASProvider)
+	return cas
+}
+
+// CASLogin 跳转到 CAS 登录页，identity 指定登录的身份类型
+func CASLogin(c *gin.Context) {
+	identity := c.Query("identity")
+	provider := casProvider(identity)
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "该身份未启用 CAS 认证"})
+		return
+	}
+	c.Redirect(http.StatusFound, provider.LoginURL(identity))
+}
+
+// CASCallback CAS 登录后的回调，校验票据后映射为本地账户并签发登录令牌
+func CASCallback(c *gin.Context) {
+	identity := c.Query("identity")
+	provider := casProvider(identity)
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "该身份未启用 CAS 认证"})
+		return
+	}
+
+	profile, err := provider.Authenticate(services.Credentials{
+		Identity: identity,
+		Ticket:   c.Query("ticket"),
+		Service:  services.CASService(identity),
+	})
+	var validationErr *config.ValidationError
+	switch {
+	case errors.Is(err, services.ErrInvalidCredentials):
+		ssoError(c, "CAS 票据无效，请重新登录")
+		return
+	case errors.As(err, &validationErr):
+		ssoError(c, validationErr.Message)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("CAS 票据校验失败")
+		ssoError(c, "统一身份认证服务不可用")
+		return
+	}
+	ssoComplete(c, profile)
+}
@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// ListCertificateTemplates 查询证书模板
+func ListCertificateTemplates(c *gin.Context) {
+	var templates []models.CertificateTemplates
+	var count int64
+	query := config.DB.Model(&models.CertificateTemplates{})
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	query.Count(&count).Order("create_time DESC").Find(&templates)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": count,
+		"data":  templates,
+	})
+}
+
+// AddCertificateTemplate 添加证书模板，背景图需先通过 /file/upload 上传
+func AddCertificateTemplate(c *gin.Context) {
+	var input struct {
+		Name          string          `json:"name"`
+		BackgroundKey string          `json:"background_key"`
+		Fields        json.RawMessage `json:"fields"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Name == "" || input.BackgroundKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if _, err := utils.ParseCertificateFields(string(input.Fields)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	if ok, status, msg := checkFileAccess(c, input.BackgroundKey, "query"); !ok {
+		c.JSON(status, gin.H{"code": status, "msg": msg})
+		return
+	}
+
+	now := time.Now()
+	template := models.CertificateTemplates{
+		Name:          input.Name,
+		BackgroundKey: input.BackgroundKey,
+		Fields:        string(input.Fields),
+		CreateTime:    now,
+		UpdateTime:    now,
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&template).Error; err != nil {
+			return err
+		}
+		return linkFile(tx, template.BackgroundKey, "certificate_template", template.ID)
+	})
+	if err != nil {
+		respondServiceError(c, err, "添加失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": template})
+}
+
+// UpdateCertificateTemplate 修改证书模板
+func UpdateCertificateTemplate(c *gin.Context) {
+	var input struct {
+		ID            int             `json:"id"`
+		Name          string          `json:"name"`
+		BackgroundKey string          `json:"background_key"`
+		Fields        json.RawMessage `json:"fields"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	updates := map[string]interface{}{"update_time": time.Now()}
+	if input.Name != "" {
+		updates["name"] = input.Name
+	}
+	if len(input.Fields) > 0 {
+		if _, err := utils.ParseCertificateFields(string(input.Fields)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		updates["fields"] = string(input.Fields)
+	}
+	if input.BackgroundKey != "" {
+		if ok, status, msg := checkFileAccess(c, input.BackgroundKey, "query"); !ok {
+			c.JSON(status, gin.H{"code": status, "msg": msg})
+			return
+		}
+		updates["background_key"] = input.BackgroundKey
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.CertificateTemplates{}).Where("id = ?", input.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if input.BackgroundKey == "" {
+			return nil
+		}
+		// 更换背景图时解除原背景图的关联，交由孤立文件回收处理
+		if err := tx.Model(&models.Files{}).Where("linked_type = ? AND linked_id = ? AND object_key <> ?", "certificate_template", input.ID, input.BackgroundKey).
+			Updates(map[string]interface{}{"linked_type": "", "linked_id": 0}).Error; err != nil {
+			return err
+		}
+		return linkFile(tx, input.BackgroundKey, "certificate_template", input.ID)
+	})
+	if err != nil {
+		respondServiceError(c, err, "修改失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
+
+// DeleteCertificateTemplate 删除证书模板，已生成的证书不受影响
+func DeleteCertificateTemplate(c *gin.Context) {
+	var data []int
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.CertificateTemplates{}, data).Error; err != nil {
+			return err
+		}
+		// 解除背景图的关联，交由孤立文件回收处理
+		return tx.Model(&models.Files{}).Where("linked_type = ? AND linked_id IN ?", "certificate_template", data).
+			Updates(map[string]interface{}{"linked_type": "", "linked_id": 0}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
+
+// GenerateCertificate 为获奖记录生成证书图片和 PDF
+// 同一记录已有证书时需传 force 重新生成，旧证书的验证码随之失效
+func GenerateCertificate(c *gin.Context) {
+	var input struct {
+		RecordID   int  `json:"record_id"`
+		TemplateID int  `json:"template_id"`
+		Force      bool `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.RecordID == 0 || input.TemplateID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	var record models.Records
+	if err := config.DB.Preload("Student").Preload("Race").Where("record_id = ?", input.RecordID).First(&record).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "记录不存在"})
+		return
+	}
+	if record.Status != models.RecordStatusApproved || record.Score == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "只有审核通过且已获奖的记录才能生成证书"})
+		return
+	}
+
+	var existing []models.Certificates
+	config.DB.Where("record_id = ?", record.RecordID).Find(&existing)
+	if len(existing) > 0 && !input.Force {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "证书已生成", "data": existing[0]})
+		return
+	}
+
+	var template models.CertificateTemplates
+	if err := config.DB.First(&template, input.TemplateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "证书模板不存在"})
+		return
+	}
+	fields, err := utils.ParseCertificateFields(template.Fields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	background, err := loadBackground(template.BackgroundKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "读取证书背景失败"})
+		return
+	}
+
+	verifyCode, err := newVerifyCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成验证码失败"})
+		return
+	}
+
+	now := time.Now()
+	certificate := models.Certificates{
+		RecordID:    record.RecordID,
+		TemplateID:  template.ID,
+		Serial:      verifyCode, // 插入后根据 ID 生成正式编号
+		VerifyCode:  verifyCode,
+		StudentName: record.Student.Name,
+		RaceTitle:   record.Race.Title,
+		Award:       record.Score,
+		IssueDate:   now,
+		CreateTime:  now,
+	}
+
+	// 证书编号依赖插入后的 ID，渲染和上传在事务内完成；事务回滚时删除已上传的文件
+	var oldKeys, uploaded []string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, old := range existing {
+			oldKeys = append(oldKeys, old.ImageKey, old.PdfKey)
+			if err := tx.Delete(&old).Error; err != nil {
+				return err
+			}
+		}
+		// 旧证书文件先解除关联，提交后再从存储中删除，删除失败时由孤立文件回收处理
+		if len(oldKeys) > 0 {
+			if err := tx.Model(&models.Files{}).Where("object_key IN ?", oldKeys).
+				Updates(map[string]interface{}{"linked_type": "", "linked_id": 0}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&certificate).Error; err != nil {
+			return err
+		}
+		certificate.Serial = fmt.Sprintf("CERT-%d-%06d", now.Year(), certificate.ID)
+		certificate.ImageKey = fmt.Sprintf("certificate/%s.png", certificate.Serial)
+		certificate.PdfKey = fmt.Sprintf("certificate/%s.pdf", certificate.Serial)
+
+		img, err := utils.RenderCertificate(background, fields, map[string]string{
+			"student_name": certificate.StudentName,
+			"race_title":   certificate.RaceTitle,
+			"award":        certificate.Award,
+			"date":         now.Format("2006年01月02日"),
+			"serial":       certificate.Serial,
+			"verify_code":  certificate.VerifyCode,
+			"verify_url":   config.CertificateVerifyURL + certificate.VerifyCode,
+		})
+		if err != nil {
+			return err
+		}
+		var pngData bytes.Buffer
+		if err := png.Encode(&pngData, img); err != nil {
+			return err
+		}
+		pdfData, err := utils.ImageToPDF(img)
+		if err != nil {
+			return err
+		}
+
+		outputs := []struct {
+			key      string
+			data     []byte
+			mimeType string
+		}{
+			{certificate.ImageKey, pngData.Bytes(), "image/png"},
+			{certificate.PdfKey, pdfData, "application/pdf"},
+		}
+		for _, output := range outputs {
+			if err := utils.FileStorage.Put(output.key, bytes.NewReader(output.data), int64(len(output.data)), output.mimeType); err != nil {
+				return err
+			}
+			uploaded = append(uploaded, output.key)
+			// 证书文件归属学生本人，并关联到证书避免被回收
+			file := models.Files{
+				ObjectKey:  output.key,
+				Owner:      record.SID,
+				Purpose:    "certificate",
+				Name:       certificate.Serial,
+				Size:       int64(len(output.data)),
+				MimeType:   output.mimeType,
+				LinkedType: "certificate",
+				LinkedID:   certificate.ID,
+				CreateTime: now,
+			}
+			if err := tx.Save(&file).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&certificate).Error
+	})
+	if err != nil {
+		if len(uploaded) > 0 {
+			if e := utils.DeleteFile(uploaded); e != nil {
+				log.Error().Err(e).Strs("keys", uploaded).Msg("清理生成失败的证书文件失败")
+			}
+		}
+		respondServiceError(c, err, "生成证书失败")
+		return
+	}
+	if len(oldKeys) > 0 {
+		if err := utils.DeleteFile(oldKeys); err != nil {
+			log.Warn().Err(err).Strs("keys", oldKeys).Msg("删除旧证书文件失败，将由孤立文件回收处理")
+		} else if err := config.DB.Where("object_key IN ?", oldKeys).Delete(&models.Files{}).Error; err != nil {
+			log.Warn().Err(err).Strs("keys", oldKeys).Msg("删除旧证书文件记录失败")
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "生成成功", "data": certificateDetail(certificate)})
+}
+
+// ListCertificates 查询参赛记录的证书，学生只能查看自己的证书
+func ListCertificates(c *gin.Context) {
+	recordID, err := strconv.Atoi(c.Query("record_id"))
+	if err != nil || recordID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if ok, msg := checkOwnerAccess(c, models.AttachmentOwnerRecord, recordID, false); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": msg})
+		return
+	}
+
+	var certificates []models.Certificates
+	config.DB.Where("record_id = ?", recordID).Order("create_time DESC").Find(&certificates)
+
+	var result []map[string]interface{}
+	for _, certificate := range certificates {
+		result = append(result, certificateDetail(certificate))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": len(result),
+		"data":  result,
+	})
+}
+
+// VerifyCertificate 公开验证证书真伪
+func VerifyCertificate(c *gin.Context) {
+	var certificate models.Certificates
+	if err := config.DB.Where("verify_code = ?", c.Param("code")).First(&certificate).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "证书不存在或已失效", "data": gin.H{"valid": false}})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "证书有效", "data": gin.H{
+		"valid":        true,
+		"serial":       certificate.Serial,
+		"student_name": certificate.StudentName,
+		"race_title":   certificate.RaceTitle,
+		"award":        certificate.Award,
+		"issue_date":   certificate.IssueDate.Format("2006-01-02"),
+	}})
+}
+
+// certificateDetail 返回证书信息及下载链接
+func certificateDetail(certificate models.Certificates) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          certificate.ID,
+		"record_id":   certificate.RecordID,
+		"serial":      certificate.Serial,
+		"verify_code": certificate.VerifyCode,
+		"award":       certificate.Award,
+		"issue_date":  certificate.IssueDate,
+		"image_url":   utils.GetFileUrl(certificate.ImageKey),
+		"pdf_url":     utils.GetFileUrl(certificate.PdfKey),
+	}
+}
+
+// linkFile 将文件关联到实体，避免被孤立文件回收删除
+// 一个文件只能关联一个实体，已关联其他实体或已作为附件使用的文件返回校验错误，不覆盖原有关联
+func linkFile(tx *gorm.DB, key, linkedType string, linkedID int) error {
+	var file models.Files
+	if err := tx.Where("object_key = ?", key).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if file.LinkedType != "" && (file.LinkedType != linkedType || file.LinkedID != linkedID) {
+		return &config.ValidationError{Message: "文件已被其他对象使用"}
+	}
+	var count int64
+	if err := tx.Model(&models.Attachments{}).Where("object_key = ?", key).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return &config.ValidationError{Message: "文件已作为附件使用"}
+	}
+	return tx.Model(&models.Files{}).Where("object_key = ?", key).Updates(map[string]interface{}{
+		"linked_type": linkedType,
+		"linked_id":   linkedID,
+	}).Error
+}
+
+// loadBackground 从存储中读取并解码证书背景图
+func loadBackground(key string) (image.Image, error) {
+	reader, err := utils.FileStorage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, errors.New("背景图格式不支持")
+	}
+	return img, nil
+}
+
+// newVerifyCode 生成16位随机验证码
+func newVerifyCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(buf), nil
+}
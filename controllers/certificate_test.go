@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestRegenerateCertificateCleansUpFiles(t *testing.T) {
+	testutil.OpenDB(t)
+	r := fileRouter(t, models.AuthenticatedUser{Account: "admin", Identity: "teacher"})
+	r.POST("/certificate/generate", GenerateCertificate)
+
+	var background bytes.Buffer
+	png.Encode(&background, image.NewRGBA(image.Rect(0, 0, 400, 200)))
+	if err := utils.FileStorage.Put("certificate/bg.png", bytes.NewReader(background.Bytes()), int64(background.Len()), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Create(&models.CertificateTemplates{ID: 1, Name: "默认", BackgroundKey: "certificate/bg.png",
+		Fields: `[{"key":"student_name","x":200,"y":100,"size":24}]`})
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "1"})
+	config.DB.Create(&models.Races{RaceID: 1, Title: "程序设计竞赛"})
+	config.DB.Create(&models.Records{RecordID: 1, SID: "20210001", RaceID: 1, Status: models.RecordStatusApproved, Score: "一等奖", CreateTime: time.Now()})
+
+	generate := func() apiResponse {
+		_, resp := postJSON(t, r, "/certificate/generate", gin.H{"record_id": 1, "template_id": 1, "force": true})
+		return resp
+	}
+	if resp := generate(); resp.Code != 200 {
+		t.Fatalf("生成证书应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	var first models.Certificates
+	config.DB.First(&first)
+
+	// 重新生成后旧证书的文件和文件记录都被删除
+	if resp := generate(); resp.Code != 200 {
+		t.Fatalf("重新生成证书应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	for _, key := range []string{first.ImageKey, first.PdfKey} {
+		if _, err := utils.GetFileInfo(key); err == nil {
+			t.Fatalf("旧证书文件 %s 应从存储中删除", key)
+		}
+		if err := config.DB.Where("object_key = ?", key).First(&models.Files{}).Error; err == nil {
+			t.Fatalf("旧证书文件 %s 的记录应删除", key)
+		}
+	}
+
+	// 事务回滚时删除已上传的新文件，旧证书保持不变
+	var second models.Certificates
+	config.DB.First(&second)
+	config.DB.Callback().Update().Before("gorm:update").Register("test:fail_certificate", func(db *gorm.DB) {
+		if db.Statement.Table == "certificates" {
+			db.AddError(errors.New("写入失败"))
+		}
+	})
+	if resp := generate(); resp.Code != 500 {
+		t.Fatalf("保存证书失败时应返回 500，得到 %d %s", resp.Code, resp.Msg)
+	}
+	config.DB.Callback().Update().Remove("test:fail_certificate")
+	var certificates []models.Certificates
+	config.DB.Find(&certificates)
+	if len(certificates) != 1 || certificates[0].ID != second.ID {
+		t.Fatalf("回滚后应保留原证书，得到 %+v", certificates)
+	}
+	for _, key := range []string{second.ImageKey, second.PdfKey} {
+		if _, err := utils.GetFileInfo(key); err != nil {
+			t.Fatalf("回滚后原证书文件 %s 应保留", key)
+		}
+	}
+	var files []models.Files
+	config.DB.Where("linked_type = ?", "certificate").Find(&files)
+	if len(files) != 2 {
+		t.Fatalf("回滚后只应有原证书的两条文件记录，得到 %d 条", len(files))
+	}
+	for _, ext := range []string{"png", "pdf"} {
+		key := fmt.Sprintf("certificate/CERT-%d-%06d.%s", time.Now().Year(), second.ID+1, ext)
+		if _, err := utils.GetFileInfo(key); err == nil {
+			t.Fatalf("回滚后应删除已上传的新证书文件 %s", key)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MyCredits 查询当前登录学生的竞赛学分
+func MyCredits(c *gin.Context) {
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+	if authUser.Identity != "student" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "仅学生可查询学分"})
+		return
+	}
+
+	var student models.Students
+	if err := config.DB.Where("sid = ?", authUser.Account).First(&student).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "学生信息未找到"})
+		return
+	}
+	credits, err := services.CalculateCredits([]models.Students{student})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "学分计算失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": credits[0]})
+}
+
+// CreditsReport 批量查询学生竞赛学分，可按年级、班级、学号和学年筛选，format=xlsx 时导出表格
+func CreditsReport(c *gin.Context) {
+	query := config.DB.Model(&models.Students{})
+	if grade := c.Query("grade"); grade != "" {
+		query = query.Where("grade = ?", grade)
+	}
+	if class := c.Query("class"); class != "" {
+		query = query.Where("class LIKE ?", "%"+class+"%")
+	}
+	if sid := c.Query("sid"); sid != "" {
+		query = query.Where("sid = ?", sid)
+	}
+	var students []models.Students
+	if err := query.Order("sid ASC").Find(&students).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询学生失败"})
+		return
+	}
+
+	credits, err := services.CalculateCredits(students)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "学分计算失败"})
+		return
+	}
+
+	// 只保留指定学年
+	if year, err := strconv.Atoi(c.Query("academic_year")); err == nil {
+		for i := range credits {
+			var years []services.CreditYear
+			credits[i].Total = 0
+			for _, creditYear := range credits[i].Years {
+				if creditYear.AcademicYear == year {
+					years = append(years, creditYear)
+					credits[i].Total += creditYear.Credits
+				}
+			}
+			credits[i].Years = years
+		}
+	}
+
+	if c.Query("format") == "xlsx" {
+		var buf bytes.Buffer
+		if err := utils.WriteXLSX(&buf, "竞赛学分", services.CreditsTable(credits)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "导出失败"})
+			return
+		}
+		sendFile(c, "credits_"+time.Now().Format("20060102")+".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(credits), "data": credits})
+}
+
+// ListCreditRuleSets 查询全部学分规则版本
+func ListCreditRuleSets(c *gin.Context) {
+	var sets []models.CreditRuleSets
+	var count int64
+	config.DB.Model(&models.CreditRuleSets{}).Count(&count).Preload("Rules").Order("effective_year DESC").Find(&sets)
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": count,
+		"data":  sets,
+	})
+}
+
+// AddCreditRuleSet 新增学分规则版本，从 effective_year 学年起生效
+// 学分按规则实时计算，生效学年早于当前学年会改变已结束学年的学分，必须显式设置 recompute 并记入审计日志
+func AddCreditRuleSet(c *gin.Context) {
+	var req struct {
+		models.CreditRuleSets
+		Recompute bool `json:"recompute"` // 确认按新规则重新计算往年学分
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" || req.EffectiveYear == 0 || req.YearCap < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	data := req.CreditRuleSets
+	current := services.AcademicYear(time.Now())
+	if data.EffectiveYear < current && !req.Recompute {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "生效学年早于当前学年（" + strconv.Itoa(current) + "），会改变往年已计算的学分，确认重新计算请设置 recompute"})
+		return
+	}
+	for i, rule := range data.Rules {
+		switch rule.TeamRole {
+		case "":
+			data.Rules[i].TeamRole = "*"
+		case "*", models.TeamRoleIndividual, models.TeamRoleLeader, models.TeamRoleMember:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "无效的团队角色"})
+			return
+		}
+		if rule.Award == "" || rule.Credits < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "规则参数有误"})
+			return
+		}
+		data.Rules[i].ID = 0
+	}
+
+	var count int64
+	config.DB.Model(&models.CreditRuleSets{}).Where("effective_year = ?", data.EffectiveYear).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "该学年已有规则版本"})
+		return
+	}
+
+	data.ID = 0
+	data.CreateTime = time.Now()
+	if err := config.DB.Create(&data).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加失败"})
+		return
+	}
+	services.AuditTarget(c, "credit_rule_set", strconv.Itoa(data.ID), nil, gin.H{
+		"name":           data.Name,
+		"effective_year": data.EffectiveYear,
+		"recompute":      data.EffectiveYear < current,
+	})
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": data})
+}
+
+// DeleteCreditRuleSet 删除尚未生效的学分规则版本，已生效的版本不能删除
+func DeleteCreditRuleSet(c *gin.Context) {
+	var data []int
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	current := services.AcademicYear(time.Now())
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range data {
+			var set models.CreditRuleSets
+			if err := tx.First(&set, id).Error; err != nil {
+				return err
+			}
+			if set.EffectiveYear <= current {
+				return &config.ValidationError{Message: "规则版本「" + set.Name + "」已生效，不能删除"}
+			}
+			if err := tx.Where("rule_set_id = ?", set.ID).Delete(&models.CreditRules{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&set).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
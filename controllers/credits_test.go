@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/middlewares"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAddCreditRuleSetRequiresRecomputeForPastYears(t *testing.T) {
+	testutil.OpenDB(t)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("authenticatedUser", models.AuthenticatedUser{Account: "admin", Identity: "teacher"})
+	})
+	r.Use(middlewares.AuditMiddleware())
+	r.POST("/credits/rules/add", AddCreditRuleSet)
+
+	current := services.AcademicYear(time.Now())
+	rules := []gin.H{{"level": 3, "award": "一等奖", "credits": 4}}
+
+	if w, _ := postJSON(t, r, "/credits/rules/add", gin.H{"name": "旧规则", "effective_year": current - 1, "rules": rules}); w.Code != 400 {
+		t.Fatalf("生效学年早于当前学年且未确认重新计算应被拒绝，得到 %d", w.Code)
+	}
+	var count int64
+	config.DB.Model(&models.CreditRuleSets{}).Count(&count)
+	if count != 0 {
+		t.Fatal("不应创建规则版本")
+	}
+
+	if w, resp := postJSON(t, r, "/credits/rules/add", gin.H{"name": "本学年规则", "effective_year": current, "rules": rules}); w.Code != 200 {
+		t.Fatalf("当前学年的规则应可直接添加，得到 %d %s", w.Code, resp.Msg)
+	}
+
+	if w, resp := postJSON(t, r, "/credits/rules/add", gin.H{"name": "补录规则", "effective_year": current - 1, "rules": rules, "recompute": true}); w.Code != 200 {
+		t.Fatalf("确认重新计算后应可添加，得到 %d %s", w.Code, resp.Msg)
+	}
+	var log models.AuditLogs
+	if err := config.DB.Where("target_type = ? AND outcome = ?", "credit_rule_set", "success").Order("id DESC").First(&log).Error; err != nil {
+		t.Fatal(err)
+	}
+	if log.Actor != "admin" || !strings.Contains(log.Changes, `"recompute":{"after":true`) {
+		t.Fatalf("重新计算往年学分应记入审计日志: %+v", log)
+	}
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateEmail 设置当前用户的邮箱和邮件语言，邮箱变更后需要重新验证
+func UpdateEmail(c *gin.Context) {
+	var input struct {
+		Email string `json:"email"`
+		Lang  string `json:"lang"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if address, err := mail.ParseAddress(input.Email); err != nil || address.Address != input.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "邮箱格式不正确"})
+		return
+	}
+	if input.Lang != "" && input.Lang != "zh" && input.Lang != "en" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "不支持的邮件语言"})
+		return
+	}
+
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+
+	var model interface{}
+	var key, name, currentEmail, lang string
+	switch authUser.Identity {
+	case "student":
+		var student models.Students
+		if err := config.DB.Where("sid = ?", authUser.Account).First(&student).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "学生信息未找到"})
+			return
+		}
+		model, key, name, currentEmail, lang = &models.Students{}, "sid", student.Name, student.Email, student.MailLang
+	case "teacher":
+		var teacher models.Teachers
+		if err := config.DB.Where("tid = ?", authUser.Account).First(&teacher).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "教师信息未找到"})
+			return
+		}
+		model, key, name, currentEmail, lang = &models.Teachers{}, "tid", teacher.Name, teacher.Email, teacher.MailLang
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "无效的身份类型"})
+		return
+	}
+
+	changed := input.Email != currentEmail
+	send := changed || c.Query("resend") == "1"
+	// 先检查发送间隔，避免邮箱已修改却发不出验证邮件
+	if send {
+		if err := services.CheckEmailVerifyCooldown(authUser.Account, authUser.Identity); err != nil {
+			respondServiceError(c, err, "修改失败")
+			return
+		}
+	}
+
+	updates := map[string]interface{}{"email": input.Email}
+	if input.Lang != "" {
+		updates["mail_lang"] = input.Lang
+		lang = input.Lang
+	}
+	if changed {
+		updates["email_verified"] = false
+	}
+	if err := config.DB.Model(model).Where(key+" = ?", authUser.Account).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
+		return
+	}
+
+	if send {
+		if err := services.SendEmailVerification(authUser.Account, authUser.Identity, name, input.Email, lang); err != nil {
+			respondServiceError(c, err, "验证邮件发送失败")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "验证邮件已发送，请前往邮箱完成验证"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
+
+// VerifyEmail 通过邮件中的链接验证邮箱，无需登录
+func VerifyEmail(c *gin.Context) {
+	if err := services.VerifyEmail(c.Query("token")); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "验证失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "邮箱验证成功"})
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/testutil"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeSMTP 只实现 SendMail 用到的命令，收到的邮件正文按顺序放入 messages
+type fakeSMTP struct {
+	listener net.Listener
+	messages chan string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTP{listener: listener, messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	previous := utils.MailSender
+	utils.MailSender = &utils.SMTPMailer{Addr: listener.Addr().String(), Host: "127.0.0.1", From: "竞赛管理系统 <noreply@example.com>"}
+	t.Cleanup(func() { utils.MailSender = previous })
+	return server
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.messages <- data.String()
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+var verifyTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// deliverVerifyToken 发送队列中的邮件，返回收到的验证邮件中的令牌
+func (s *fakeSMTP) deliverVerifyToken(t *testing.T, to string) string {
+	t.Helper()
+	if err := services.SendQueuedMails(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-s.messages:
+		if !strings.Contains(message, "To: "+to+"\r\n") {
+			t.Fatalf("验证邮件应发送到 %s:\n%s", to, message)
+		}
+		match := verifyTokenPattern.FindStringSubmatch(message)
+		if match == nil {
+			t.Fatalf("邮件中没有验证链接:\n%s", message)
+		}
+		return match[1]
+	default:
+		t.Fatal("没有收到验证邮件")
+		return ""
+	}
+}
+
+func emailRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("authenticatedUser", models.AuthenticatedUser{Account: "20210001", Identity: "student"})
+	})
+	r.PUT("/user/email", UpdateEmail)
+	r.GET("/email/verify", VerifyEmail)
+	return r
+}
+
+func updateEmail(t *testing.T, r *gin.Engine, email string) {
+	t.Helper()
+	raw, _ := json.Marshal(gin.H{"email": email})
+	req := httptest.NewRequest(http.MethodPut, "/user/email", bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("修改邮箱失败: %d %s", w.Code, w.Body)
+	}
+}
+
+func verifyEmail(r *gin.Engine, token string) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/email/verify?token="+url.QueryEscape(token), nil))
+	return w.Code
+}
+
+func emailVerified(t *testing.T) (string, bool) {
+	t.Helper()
+	var student models.Students
+	if err := config.DB.Where("sid = ?", "20210001").First(&student).Error; err != nil {
+		t.Fatal(err)
+	}
+	return student.Email, student.EmailVerified
+}
+
+func TestEmailVerificationTokenIsSingleUseAndBoundToAddress(t *testing.T) {
+	testutil.OpenDB(t)
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "1"})
+	smtp := startFakeSMTP(t)
+	r := emailRouter()
+	previous := config.EmailVerifyCooldown
+	config.EmailVerifyCooldown = 0
+	t.Cleanup(func() { config.EmailVerifyCooldown = previous })
+
+	updateEmail(t, r, "zs@example.com")
+	token := smtp.deliverVerifyToken(t, "zs@example.com")
+	if verifyEmail(r, "forged") != http.StatusBadRequest {
+		t.Fatal("伪造的令牌应被拒绝")
+	}
+	if code := verifyEmail(r, token); code != http.StatusOK {
+		t.Fatalf("验证应成功，得到 %d", code)
+	}
+	if email, verified := emailVerified(t); email != "zs@example.com" || !verified {
+		t.Fatal("邮箱应已验证")
+	}
+	if verifyEmail(r, token) != http.StatusBadRequest {
+		t.Fatal("验证链接只能使用一次")
+	}
+
+	// 修改邮箱后，发往旧邮箱的链接不能验证新邮箱
+	updateEmail(t, r, "old@example.com")
+	oldToken := smtp.deliverVerifyToken(t, "old@example.com")
+	updateEmail(t, r, "new@example.com")
+	newToken := smtp.deliverVerifyToken(t, "new@example.com")
+	if verifyEmail(r, oldToken) != http.StatusBadRequest {
+		t.Fatal("旧邮箱的验证链接应失效")
+	}
+	if _, verified := emailVerified(t); verified {
+		t.Fatal("新邮箱不应被旧链接验证")
+	}
+	if verifyEmail(r, newToken) != http.StatusOK {
+		t.Fatal("新邮箱的验证链接应有效")
+	}
+
+	var pending int64
+	config.DB.Model(&models.EmailVerifications{}).Where("used_time IS NULL").Count(&pending)
+	if pending != 0 {
+		t.Fatalf("验证后不应留下可用的令牌，剩余 %d 个", pending)
+	}
+}
+
+func TestEmailVerificationResendCooldown(t *testing.T) {
+	testutil.OpenDB(t)
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "1"})
+	r := emailRouter()
+
+	updateEmail(t, r, "zs@example.com")
+	// 重新发送和改为其他邮箱都需要发送验证邮件
+	for target, email := range map[string]string{"/user/email?resend=1": "zs@example.com", "/user/email": "other@example.com"} {
+		raw, _ := json.Marshal(gin.H{"email": email})
+		req := httptest.NewRequest(http.MethodPut, target, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: 冷却期内不能再次发送验证邮件，得到 %d", target, w.Code)
+		}
+	}
+
+	var queued int64
+	config.DB.Model(&models.MailQueue{}).Count(&queued)
+	if email, _ := emailVerified(t); queued != 1 || email != "zs@example.com" {
+		t.Fatalf("冷却期内只应发送一封验证邮件且不修改邮箱，得到 %d 封，邮箱 %s", queued, email)
+	}
+}
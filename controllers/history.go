@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+)
+
+// parseHistoryTarget 解析 type/id 参数并检查查看权限，权限规则与附件相同
+func parseHistoryTarget(c *gin.Context) (string, int, bool) {
+	entityType := c.Query("type")
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil || id == 0 || (entityType != models.RevisionEntityRace && entityType != models.RevisionEntityRecord) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return "", 0, false
+	}
+	if ok, msg := checkOwnerAccess(c, entityType, id, false); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": msg})
+		return "", 0, false
+	}
+	return entityType, id, true
+}
+
+// revisionDetail 返回版本信息，快照解析为对象
+func revisionDetail(revision *models.Revisions) gin.H {
+	return gin.H{
+		"version":     revision.Version,
+		"action":      revision.Action,
+		"operator":    revision.Operator,
+		"create_time": revision.CreateTime,
+		"snapshot":    services.RevisionSnapshot(revision),
+	}
+}
+
+// historyError 统一处理版本查询错误
+func historyError(c *gin.Context, err error) {
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+}
+
+// ListHistory 查询比赛或参赛记录的全部历史版本
+func ListHistory(c *gin.Context) {
+	entityType, id, ok := parseHistoryTarget(c)
+	if !ok {
+		return
+	}
+	revisions, err := services.ListRevisions(entityType, id)
+	if err != nil {
+		historyError(c, err)
+		return
+	}
+
+	result := make([]gin.H, 0, len(revisions))
+	for i := range revisions {
+		result = append(result, revisionDetail(&revisions[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": len(result),
+		"data":  result,
+	})
+}
+
+// DiffHistory 比较两个版本，from/to 为版本号
+func DiffHistory(c *gin.Context) {
+	entityType, id, ok := parseHistoryTarget(c)
+	if !ok {
+		return
+	}
+	from, err1 := strconv.Atoi(c.Query("from"))
+	to, err2 := strconv.Atoi(c.Query("to"))
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	diff, err := services.DiffRevisions(entityType, id, from, to)
+	if err != nil {
+		historyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": gin.H{"from": from, "to": to, "changes": diff}})
+}
+
+// ViewHistory 查看某一时刻的数据，at 格式为 yyyy-mm-dd hh:mm:ss 或 yyyy-mm-dd（当天结束时）
+func ViewHistory(c *gin.Context) {
+	entityType, id, ok := parseHistoryTarget(c)
+	if !ok {
+		return
+	}
+	at, err := time.ParseInLocation("2006-01-02 15:04:05", c.Query("at"), time.Local)
+	if err != nil {
+		day, dayErr := time.ParseInLocation("2006-01-02", c.Query("at"), time.Local)
+		if dayErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+			return
+		}
+		at = day.AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	revision, err := services.RevisionAt(entityType, id, at)
+	if err != nil {
+		historyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": revisionDetail(revision)})
+}
+
+// RevertRecord 将参赛记录的获奖等级和审核状态恢复为历史版本
+func RevertRecord(c *gin.Context) {
+	var input struct {
+		RecordID int `json:"record_id"`
+		Version  int `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.RecordID == 0 || input.Version == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	services.AuditTarget(c, models.RevisionEntityRecord, strconv.Itoa(input.RecordID), nil, gin.H{"version": input.Version})
+	if err := services.RevertRecord(c, input.RecordID, input.Version); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "恢复失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "恢复成功"})
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// StartImpersonation 管理员模拟用户登录，用于排查用户反馈的问题
+// 模拟会话限时有效，期间默认只允许查询，响应头带有 X-Impersonated-By 标记，开始和结束由审计中间件各记录一条日志
+func StartImpersonation(c *gin.Context) {
+	var req struct {
+		Account string `json:"account" binding:"required"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	user, _ := c.Get("authenticatedUser")
+	operator := user.(models.AuthenticatedUser)
+
+	session, target, err := services.StartImpersonation(operator, c.GetString("sessionID"), req.Account, c.ClientIP(), c.Request.UserAgent())
+	if err == nil {
+		err = signToken(c, target.Account, target.Identity, session.ID, session.ExpireTime)
+	}
+	services.AuditTarget(c, "user", req.Account, nil, nil)
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "模拟登录失败"})
+		return
+	}
+	c.Header("X-Impersonated-By", operator.Account)
+	c.Header("X-Impersonating", target.Account)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已模拟登录", "data": gin.H{
+		"account":     target.Account,
+		"identity":    target.Identity,
+		"expire_time": session.ExpireTime,
+		"read_only":   config.ImpersonationReadOnly,
+	}})
+}
+
+// StopImpersonation 结束模拟登录，恢复管理员原来的登录状态
+func StopImpersonation(c *gin.Context) {
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+	if authUser.ImpersonatedBy == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "当前不是模拟登录"})
+		return
+	}
+
+	origin, err := services.StopImpersonation(c.GetString("sessionID"))
+	if err == nil {
+		var operator models.User
+		if err = config.DB.Where("account = ?", origin.Account).First(&operator).Error; err == nil {
+			err = signToken(c, operator.Account, operator.Identity, origin.ID, origin.ExpireTime)
+		}
+	}
+	services.AuditTarget(c, "user", authUser.Account, nil, nil)
+	if err != nil {
+		utils.SetCookie(c, "uid", "", -1, true)
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "已结束模拟登录，原会话已失效，请重新登录"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "结束模拟登录失败"})
+		return
+	}
+	c.Writer.Header().Del("X-Impersonated-By")
+	c.Writer.Header().Del("X-Impersonating")
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已结束模拟登录"})
+}
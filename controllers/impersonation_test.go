@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/middlewares"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// impersonationRouter 按 routes.go 的顺序挂载登录检查、审计和权限检查
+func impersonationRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(middlewares.LoginCheckMiddleware(), middlewares.AuditMiddleware(), middlewares.AuthCheckMiddleware())
+	r.POST("/user/impersonate", StartImpersonation)
+	r.POST("/auth/impersonate/stop", StopImpersonation)
+	r.GET("/auth/sessions", ListSessions)
+	r.POST("/auth/sessions/revoke", RevokeSession)
+	r.POST("/auth/sessions/revoke_others", RevokeOtherSessions)
+	r.POST("/race/add", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"code": 200}) })
+	return r
+}
+
+// sessionCookie 为账号创建登录会话并签发对应的登录 Cookie
+func sessionCookie(t *testing.T, account, identity string) *http.Cookie {
+	t.Helper()
+	exp := time.Now().Add(time.Hour)
+	sid, err := services.CreateSession(account, "192.0.2.1", "", exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"account": account, "identity": identity, "sid": sid, "iat": time.Now().Unix(), "exp": exp.Unix(),
+	}).SignedString([]byte(TokenKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: "uid", Value: token}
+}
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func listSessions(t *testing.T, r *gin.Engine, cookie *http.Cookie) []interface{} {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/auth/sessions", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("查询会话失败: %d %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data []interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data
+}
+
+func TestImpersonationLifecycle(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 1, "user:impersonate", "race:query")
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "admin", "teacher", 1, "pw")
+	testutil.CreateUser(t, "20210001", "student", 3, "pw")
+	r := impersonationRouter()
+	admin := sessionCookie(t, "admin", "teacher")
+	target := sessionCookie(t, "20210001", "student")
+
+	w, resp := postJSON(t, r, "/user/impersonate", gin.H{"account": "20210001", "reason": "排查报名问题"}, admin)
+	if resp.Code != 200 || w.Header().Get("X-Impersonated-By") != "admin" {
+		t.Fatalf("模拟登录应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	impersonation := responseCookie(w, "uid")
+	if impersonation == nil {
+		t.Fatal("应签发模拟会话的登录 Cookie")
+	}
+	var session models.UserSessions
+	if err := config.DB.Where("impersonated_by = ?", "admin").First(&session).Error; err != nil || session.Account != "20210001" {
+		t.Fatalf("应创建模拟会话: %+v, %v", session, err)
+	}
+
+	// 模拟期间只读
+	if w, _ := postJSON(t, r, "/race/add", gin.H{"title": "x"}, impersonation); w.Code != http.StatusForbidden {
+		t.Fatalf("模拟登录期间不能修改数据，得到 %d", w.Code)
+	}
+
+	// 被模拟的用户看不到也注销不了管理员的模拟会话
+	if sessions := listSessions(t, r, target); len(sessions) != 1 {
+		t.Fatalf("用户的会话列表不应包含模拟会话，得到 %d 条", len(sessions))
+	}
+	if _, resp := postJSON(t, r, "/auth/sessions/revoke", gin.H{"id": session.ID}, target); resp.Code != 400 {
+		t.Fatalf("用户不能注销模拟会话，得到 %d", resp.Code)
+	}
+	if _, resp := postJSON(t, r, "/auth/sessions/revoke_others", nil, target); resp.Code != 200 || resp.Data["count"] != float64(0) {
+		t.Fatalf("注销其他设备时应保留模拟会话，得到 %d %v", resp.Code, resp.Data)
+	}
+	if sessions := listSessions(t, r, impersonation); len(sessions) != 2 {
+		t.Fatalf("模拟会话应仍然有效且能看到自身，得到 %d 条", len(sessions))
+	}
+
+	w, resp = postJSON(t, r, "/auth/impersonate/stop", nil, impersonation)
+	if resp.Code != 200 || w.Header().Get("X-Impersonated-By") != "" {
+		t.Fatalf("结束模拟登录应成功，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if restored := responseCookie(w, "uid"); restored == nil || len(listSessions(t, r, restored)) != 1 {
+		t.Fatal("应恢复管理员原来的登录状态")
+	}
+	if w, _ := postJSON(t, r, "/race/add", gin.H{"title": "x"}, impersonation); w.Code != http.StatusForbidden {
+		t.Fatalf("结束后模拟会话应失效，得到 %d", w.Code)
+	}
+
+	// 开始和结束各只有一条审计日志，操作人都是管理员
+	var logs []models.AuditLogs
+	config.DB.Where("route IN ?", []string{"/user/impersonate", "/auth/impersonate/stop"}).Order("id ASC").Find(&logs)
+	if len(logs) != 2 {
+		t.Fatalf("开始和结束应各记录一条审计日志，得到 %d 条", len(logs))
+	}
+	start, stop := logs[0], logs[1]
+	if start.Route != "/user/impersonate" || start.Actor != "admin" || start.TargetID != "20210001" || !strings.Contains(start.Detail, "排查报名问题") {
+		t.Fatalf("开始模拟的审计日志有误: %+v", start)
+	}
+	if stop.Route != "/auth/impersonate/stop" || stop.Actor != "admin" || stop.OnBehalfOf != "20210001" || stop.TargetID != "20210001" || stop.Status != http.StatusOK {
+		t.Fatalf("结束模拟的审计日志有误: %+v", stop)
+	}
+}
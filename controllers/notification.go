@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// currentAccount 当前登录用户的账号
+func currentAccount(c *gin.Context) string {
+	user, exists := c.Get("authenticatedUser")
+	if !exists {
+		return ""
+	}
+	return user.(models.AuthenticatedUser).Account
+}
+
+// ListNotifications 查询当前用户的通知，unread=1 只返回未读通知，event 按事件类型筛选
+func ListNotifications(c *gin.Context) {
+	var notifications []models.Notifications
+	var count int64
+	query := config.DB.Model(&models.Notifications{}).Where("recipient = ?", currentAccount(c))
+
+	if c.Query("unread") == "1" {
+		query = query.Where("is_read = ?", false)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "1"))
+	if err := query.Count(&count).Order("id DESC").Limit(limit).Offset(limit * (offset - 1)).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"msg":   "查询成功",
+		"count": count,
+		"data":  notifications,
+	})
+}
+
+// UnreadNotificationCount 当前用户的未读通知数量
+func UnreadNotificationCount(c *gin.Context) {
+	var count int64
+	if err := config.DB.Model(&models.Notifications{}).Where("recipient = ? AND is_read = ?", currentAccount(c), false).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": count})
+}
+
+// MarkNotificationsRead 将通知标记为已读，all 为 true 时标记全部，只能操作自己的通知
+func MarkNotificationsRead(c *gin.Context) {
+	var input struct {
+		IDs []int `json:"ids"`
+		All bool  `json:"all"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || (!input.All && len(input.IDs) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	query := config.DB.Model(&models.Notifications{}).Where("recipient = ? AND is_read = ?", currentAccount(c), false)
+	if !input.All {
+		query = query.Where("id IN ?", input.IDs)
+	}
+	if err := query.Updates(map[string]interface{}{"is_read": true, "read_time": time.Now()}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "操作失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "操作成功"})
+}
+
+// GetNotificationPreferences 查询当前用户各类通知的接收设置，未设置的事件默认接收
+func GetNotificationPreferences(c *gin.Context) {
+	var preferences []models.NotificationPreferences
+	if err := config.DB.Where("account = ?", currentAccount(c)).Find(&preferences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	saved := make(map[string]models.NotificationPreferences)
+	for _, preference := range preferences {
+		saved[preference.Event] = preference
+	}
+
+	events := make([]string, 0, len(models.NotificationEvents))
+	for event := range models.NotificationEvents {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	var result []gin.H
+	for _, event := range events {
+		name := models.NotificationEvents[event]
+		preference, ok := saved[event]
+		if !ok {
+			preference = models.NotificationPreferences{Event: event, InApp: true, Email: true}
+		}
+		result = append(result, gin.H{"event": event, "name": name, "in_app": preference.InApp, "email": preference.Email})
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": result})
+}
+
+// UpdateNotificationPreferences 修改当前用户的通知接收设置，每项需同时提供 in_app 和 email
+func UpdateNotificationPreferences(c *gin.Context) {
+	var input []models.NotificationPreferences
+	if err := c.ShouldBindJSON(&input); err != nil || len(input) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	account := currentAccount(c)
+	for i := range input {
+		if _, ok := models.NotificationEvents[input[i].Event]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "未知的通知类型"})
+			return
+		}
+		input[i].Account = account
+	}
+
+	if err := config.DB.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"in_app", "email"})}).Create(&input).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"competition-server/config"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// OIDCLogin 跳转到统一身份认证登录页，state、nonce 和 PKCE 校验值保存在会话中
+func OIDCLogin(c *gin.Context) {
+	if utils.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未启用统一身份认证"})
+		return
+	}
+	var values [3]string
+	for i := range values {
+		value, err := utils.RandomToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成登录请求失败"})
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	target, err := utils.OIDC.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Error().Err(err).Msg("获取统一身份认证配置失败")
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": "统一身份认证服务不可用"})
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("oidc_state", state)
+	session.Set("oidc_nonce", nonce)
+	session.Set("oidc_verifier", verifier)
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "保存会话失败"})
+		return
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// OIDCCallback 统一身份认证回调：校验 state，换取并校验 ID Token，映射为本地账户后签发登录令牌
+func OIDCCallback(c *gin.Context) {
+	if utils.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未启用统一身份认证"})
+		return
+	}
+
+	// state、nonce 只能使用一次，取出后立即清除
+	session := sessions.Default(c)
+	state, _ := session.Get("oidc_state").(string)
+	nonce, _ := session.Get("oidc_nonce").(string)
+	verifier, _ := session.Get("oidc_verifier").(string)
+	session.Delete("oidc_state")
+	session.Delete("oidc_nonce")
+	session.Delete("oidc_verifier")
+	session.Save()
+
+	if errCode := c.Query("error"); errCode != "" {
+		ssoError(c, "统一身份认证失败: "+errCode)
+		return
+	}
+	if state == "" || c.Query("state") != state {
+		ssoError(c, "登录请求已失效，请重新登录")
+		return
+	}
+
+	rawIDToken, err := utils.OIDC.Exchange(c.Query("code"), verifier)
+	if err != nil {
+		log.Error().Err(err).Msg("统一身份认证换取令牌失败")
+		ssoError(c, "统一身份认证失败")
+		return
+	}
+	claims, err := utils.OIDC.VerifyIDToken(rawIDToken, nonce)
+	if err != nil {
+		log.Warn().Err(err).Msg("统一身份认证令牌校验失败")
+		ssoError(c, "统一身份认证失败")
+		return
+	}
+
+	profile, err := services.OIDCProfile(claims)
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		ssoError(c, validationErr.Message)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("统一身份认证声明映射配置有误")
+		ssoError(c, "统一身份认证配置有误，请联系管理员")
+		return
+	}
+	ssoComplete(c, profile)
+}
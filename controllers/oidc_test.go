@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"competition-server/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// startMockIdP 启动模拟的身份提供方，claims 为令牌端点签发的 ID Token 中除 iss/aud/exp/nonce 外的声明
+// 令牌端点使用授权请求中的 nonce，并校验 PKCE code_verifier
+func startMockIdP(t *testing.T, claims jwt.MapClaims) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server *httptest.Server
+	var nonce, challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		nonce, challenge = r.URL.Query().Get("nonce"), r.URL.Query().Get("code_challenge")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if utils.PKCEChallenge(r.PostForm.Get("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		full := jwt.MapClaims{"iss": server.URL, "aud": "client", "nonce": nonce, "exp": time.Now().Add(time.Minute).Unix()}
+		for k, v := range claims {
+			full[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, full)
+		token.Header["kid"] = "k1"
+		raw, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": raw})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	previous := utils.OIDC
+	utils.OIDC = &utils.OIDCProvider{Issuer: server.URL, ClientID: "client", ClientSecret: "secret", RedirectURL: "http://localhost/cb", Client: server.Client()}
+	t.Cleanup(func() { utils.OIDC = previous })
+}
+
+// oidcLogin 走完一次登录流程：/auth/oidc/login → 身份提供方授权页 → /auth/oidc/callback，返回回调的响应
+func oidcLogin(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test"))))
+	r.GET("/auth/oidc/login", OIDCLogin)
+	r.GET("/auth/oidc/callback", OIDCCallback)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("应跳转到身份提供方，得到 %d %s", w.Code, w.Body)
+	}
+	authorize, _ := url.Parse(w.Header().Get("Location"))
+	resp, err := http.Get(authorize.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=c1&state="+url.QueryEscape(authorize.Query().Get("state")), nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("回调应跳转回前端，得到 %d %s", w.Code, w.Body)
+	}
+	return w
+}
+
+func redirectParams(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	t.Helper()
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query()
+}
+
+func hasCookie(w *httptest.ResponseRecorder, name string) bool {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOIDCLoginProvisionsStudent(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 3, "race:query")
+	startMockIdP(t, jwt.MapClaims{"sub": "20210001", "preferred_username": "admin", "user_type": "student", "name": "张三"})
+
+	w := oidcLogin(t)
+	if params := redirectParams(t, w); params.Get("error") != "" {
+		t.Fatalf("登录应成功，得到 %s", params.Get("error"))
+	}
+	if !hasCookie(w, "uid") {
+		t.Fatal("应签发登录令牌")
+	}
+	var user models.User
+	if err := config.DB.Where("account = ?", "20210001").First(&user).Error; err != nil || user.RoleID != 3 {
+		t.Fatalf("应按 sub 创建学生账户: %+v, %v", user, err)
+	}
+	var count int64
+	config.DB.Model(&models.User{}).Where("account = ?", "admin").Count(&count)
+	if count != 0 {
+		t.Fatal("不应按 preferred_username 创建账户")
+	}
+}
+
+func TestOIDCLoginRejectsAdminAccount(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 1, "role:update")
+	testutil.CreateUser(t, "T001", "teacher", 1, "pw")
+	startMockIdP(t, jwt.MapClaims{"sub": "T001", "user_type": "teacher"})
+
+	w := oidcLogin(t)
+	if params := redirectParams(t, w); params.Get("error") == "" {
+		t.Fatal("超级管理员账户不能通过单点登录登录")
+	}
+	if hasCookie(w, "uid") {
+		t.Fatal("不应签发登录令牌")
+	}
+}
@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OrgTree 查询学院、专业、班级的完整层级
+func OrgTree(c *gin.Context) {
+	var colleges []models.Colleges
+	if err := config.DB.Preload("Majors", func(db *gorm.DB) *gorm.DB { return db.Order("name") }).
+		Preload("Majors.Classes", func(db *gorm.DB) *gorm.DB { return db.Order("grade DESC, name") }).
+		Order("name").Find(&colleges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(colleges), "data": colleges})
+}
+
+// ListColleges 查询学院
+func ListColleges(c *gin.Context) {
+	var colleges []models.Colleges
+	query := config.DB.Model(&models.Colleges{})
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if err := query.Order("name").Find(&colleges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(colleges), "data": colleges})
+}
+
+// AddCollege 添加学院
+func AddCollege(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	var count int64
+	config.DB.Model(&models.Colleges{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学院已存在"})
+		return
+	}
+	college := models.Colleges{Name: req.Name, CreateTime: time.Now(), UpdateTime: time.Now()}
+	if err := config.DB.Create(&college).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": college})
+}
+
+// UpdateCollege 修改学院名称
+func UpdateCollege(c *gin.Context) {
+	var req struct {
+		ID   int    `json:"id" binding:"required"`
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	var before models.Colleges
+	if err := config.DB.Where("id = ?", req.ID).First(&before).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学院不存在"})
+		return
+	}
+	var count int64
+	config.DB.Model(&models.Colleges{}).Where("name = ? AND id <> ?", req.Name, req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学院已存在"})
+		return
+	}
+	services.AuditTarget(c, "college", strconv.Itoa(req.ID), gin.H{"name": before.Name}, gin.H{"name": req.Name})
+	if err := config.DB.Model(&models.Colleges{}).Where("id = ?", req.ID).
+		Updates(map[string]interface{}{"name": req.Name, "update_time": time.Now()}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
+		return
+	}
+	services.ClearStatsCache()
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
+
+// DeleteCollege 删除学院，学院下还有专业或教师时不能删除
+func DeleteCollege(c *gin.Context) {
+	var req struct {
+		ID int `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var count int64
+	config.DB.Model(&models.Majors{}).Where("college_id = ?", req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学院下还有专业，不能删除"})
+		return
+	}
+	config.DB.Unscoped().Model(&models.Teachers{}).Where("college_id = ?", req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学院下还有教师，不能删除"})
+		return
+	}
+	result := config.DB.Where("id = ?", req.ID).Delete(&models.Colleges{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学院不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
+
+// ListMajors 查询专业，可按学院筛选
+func ListMajors(c *gin.Context) {
+	var majors []models.Majors
+	query := config.DB.Model(&models.Majors{})
+	if collegeID := c.Query("college_id"); collegeID != "" {
+		query = query.Where("college_id = ?", collegeID)
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if err := query.Order("college_id, name").Find(&majors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(majors), "data": majors})
+}
+
+// AddMajor 添加专业，同一学院内专业名称不能重复
+func AddMajor(c *gin.Context) {
+	var req struct {
+		CollegeID int    `json:"college_id" binding:"required"`
+		Name      string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if err := services.CheckCollege(req.CollegeID); err != nil {
+		respondServiceError(c, err, "查询学院失败")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	var count int64
+	config.DB.Model(&models.Majors{}).Where("college_id = ? AND name = ?", req.CollegeID, req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业已存在"})
+		return
+	}
+	major := models.Majors{CollegeID: req.CollegeID, Name: req.Name, CreateTime: time.Now(), UpdateTime: time.Now()}
+	if err := config.DB.Create(&major).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": major})
+}
+
+// UpdateMajor 修改专业名称或所属学院
+func UpdateMajor(c *gin.Context) {
+	var req struct {
+		ID        int    `json:"id" binding:"required"`
+		CollegeID int    `json:"college_id"`
+		Name      string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var before models.Majors
+	if err := config.DB.Where("id = ?", req.ID).First(&before).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业不存在"})
+		return
+	}
+	after := before
+	if req.CollegeID != 0 && req.CollegeID != before.CollegeID {
+		if err := services.CheckCollege(req.CollegeID); err != nil {
+			respondServiceError(c, err, "查询学院失败")
+			return
+		}
+		after.CollegeID = req.CollegeID
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		after.Name = name
+	}
+	var count int64
+	config.DB.Model(&models.Majors{}).Where("college_id = ? AND name = ? AND id <> ?", after.CollegeID, after.Name, req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业已存在"})
+		return
+	}
+	services.AuditTarget(c, "major", strconv.Itoa(req.ID), gin.H{"college_id": before.CollegeID, "name": before.Name}, gin.H{"college_id": after.CollegeID, "name": after.Name})
+	if err := config.DB.Model(&models.Majors{}).Where("id = ?", req.ID).Updates(map[string]interface{}{
+		"college_id":  after.CollegeID,
+		"name":        after.Name,
+		"update_time": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
+		return
+	}
+	services.ClearStatsCache()
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
+
+// DeleteMajor 删除专业，专业下还有班级时不能删除
+func DeleteMajor(c *gin.Context) {
+	var req struct {
+		ID int `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var count int64
+	config.DB.Model(&models.Classes{}).Where("major_id = ?", req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业下还有班级，不能删除"})
+		return
+	}
+	result := config.DB.Where("id = ?", req.ID).Delete(&models.Majors{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
+
+// ListClasses 查询班级，可按学院、专业、年级和名称筛选
+func ListClasses(c *gin.Context) {
+	var classes []models.Classes
+	var count int64
+	query := config.DB.Model(&models.Classes{})
+	if collegeID := c.Query("college_id"); collegeID != "" {
+		query = query.Where("major_id IN (?)", config.DB.Model(&models.Majors{}).Select("id").Where("college_id = ?", collegeID))
+	}
+	if majorID := c.Query("major_id"); majorID != "" {
+		query = query.Where("major_id = ?", majorID)
+	}
+	if grade := c.Query("grade"); grade != "" {
+		query = query.Where("grade = ?", grade)
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "1"))
+	if err := query.Count(&count).Order("grade DESC, name").Limit(limit).Offset(limit * (offset - 1)).Find(&classes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": count, "data": classes})
+}
+
+// AddClass 添加班级，班级名称全校唯一
+func AddClass(c *gin.Context) {
+	var req struct {
+		MajorID int    `json:"major_id" binding:"required"`
+		Name    string `json:"name" binding:"required"`
+		Grade   int    `json:"grade"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var count int64
+	config.DB.Model(&models.Majors{}).Where("id = ?", req.MajorID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业不存在"})
+		return
+	}
+	req.Name = services.NormalizeClassName(req.Name)
+	config.DB.Model(&models.Classes{}).Where("name = ?", req.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "班级已存在"})
+		return
+	}
+	class := models.Classes{MajorID: req.MajorID, Name: req.Name, Grade: req.Grade, CreateTime: time.Now(), UpdateTime: time.Now()}
+	if err := config.DB.Create(&class).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": class})
+}
+
+// UpdateClass 修改班级，改名时同步更新学生信息中的班级名称
+func UpdateClass(c *gin.Context) {
+	var req struct {
+		ID      int    `json:"id" binding:"required"`
+		MajorID int    `json:"major_id"`
+		Name    string `json:"name"`
+		Grade   int    `json:"grade"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	before, err := services.LookupClass(req.ID)
+	if err != nil {
+		respondServiceError(c, err, "查询班级失败")
+		return
+	}
+	after := *before
+	var count int64
+	if req.MajorID != 0 && req.MajorID != before.MajorID {
+		config.DB.Model(&models.Majors{}).Where("id = ?", req.MajorID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "专业不存在"})
+			return
+		}
+		after.MajorID = req.MajorID
+	}
+	if name := services.NormalizeClassName(req.Name); name != "" && name != before.Name {
+		config.DB.Model(&models.Classes{}).Where("name = ? AND id <> ?", name, req.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "班级已存在"})
+			return
+		}
+		after.Name = name
+	}
+	if req.Grade != 0 {
+		after.Grade = req.Grade
+	}
+
+	services.AuditTarget(c, "class", strconv.Itoa(req.ID),
+		gin.H{"major_id": before.MajorID, "name": before.Name, "grade": before.Grade},
+		gin.H{"major_id": after.MajorID, "name": after.Name, "grade": after.Grade})
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Classes{}).Where("id = ?", req.ID).Updates(map[string]interface{}{
+			"major_id":    after.MajorID,
+			"name":        after.Name,
+			"grade":       after.Grade,
+			"update_time": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if after.Name == before.Name {
+			return nil
+		}
+		return tx.Unscoped().Model(&models.Students{}).Where("class_id = ?", req.ID).Update("class", after.Name).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
+		return
+	}
+	services.ClearStatsCache()
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
+
+// DeleteClass 删除班级，班级下还有学生（包括回收站中的学生）时不能删除
+func DeleteClass(c *gin.Context) {
+	var req struct {
+		ID int `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var count int64
+	config.DB.Unscoped().Model(&models.Students{}).Where("class_id = ?", req.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "班级下还有学生，不能删除"})
+		return
+	}
+	result := config.DB.Where("id = ?", req.ID).Delete(&models.Classes{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "班级不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
+
+// MigrateClasses 将学生信息中的班级名称迁移为学院、专业和班级，返回迁移结果和冲突报告
+// dry_run 为 true 时只生成报告不写入数据库，建议先试运行确认冲突后再正式执行
+func MigrateClasses(c *gin.Context) {
+	var opts services.OrgMigrationOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	report, err := services.MigrateClasses(opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "迁移失败"})
+		return
+	}
+	if !opts.DryRun {
+		services.ClearStatsCache()
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "迁移完成", "data": report})
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteOrgRefusedWhileReferenced(t *testing.T) {
+	testutil.OpenDB(t)
+	r := gin.New()
+	r.POST("/college/delete", DeleteCollege)
+	r.POST("/class/delete", DeleteClass)
+
+	config.DB.Create(&models.Colleges{ID: 1, Name: "软件学院"})
+	config.DB.Create(&models.Colleges{ID: 2, Name: "信息学院"})
+	config.DB.Create(&models.Majors{ID: 1, CollegeID: 1, Name: "软件工程"})
+	config.DB.Create(&models.Classes{ID: 1, MajorID: 1, Name: "软件工程2101", Grade: 2021})
+	classID, collegeID, sex := 1, 2, 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "软件工程2101", ClassID: &classID})
+	config.DB.Create(&models.Teachers{TID: "T001", Name: "李老师", Password: "x", CollegeID: &collegeID})
+
+	// 回收站中的学生仍引用班级
+	config.DB.Where("sid = ?", "20210001").Delete(&models.Students{})
+	if _, resp := postJSON(t, r, "/class/delete", gin.H{"id": 1}); resp.Code != 400 {
+		t.Fatalf("班级下还有学生时不能删除，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if _, resp := postJSON(t, r, "/college/delete", gin.H{"id": 1}); resp.Code != 400 {
+		t.Fatalf("学院下还有专业时不能删除，得到 %d %s", resp.Code, resp.Msg)
+	}
+	if _, resp := postJSON(t, r, "/college/delete", gin.H{"id": 2}); resp.Code != 400 {
+		t.Fatalf("学院下还有教师时不能删除，得到 %d %s", resp.Code, resp.Msg)
+	}
+	var count int64
+	config.DB.Model(&models.Colleges{}).Count(&count)
+	if count != 2 {
+		t.Fatal("被引用的学院不应删除")
+	}
+
+	// 不再被引用后可以删除
+	config.DB.Unscoped().Where("sid = ?", "20210001").Delete(&models.Students{})
+	if _, resp := postJSON(t, r, "/class/delete", gin.H{"id": 1}); resp.Code != 200 {
+		t.Fatalf("没有学生的班级应能删除，得到 %d %s", resp.Code, resp.Msg)
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestForgotPasswordIgnoresEmailPlantedByOthers(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 1, "user:update")
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "admin", "student", 1, "pw")
+	testutil.CreateUser(t, "20210001", "student", 3, "pw")
+	sex := 1
+	config.DB.Create(&models.Students{SID: "admin", Name: "管理员", Password: "x", Sex: &sex})
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex})
+
+	attacker := models.AuthenticatedUser{Account: "20210001", Identity: "student", Permissions: []string{"race:query"}}
+	r := userRouter(attacker)
+	r.PUT("/user/email", UpdateEmail)
+	r.POST("/auth/password/forgot", ForgotPassword)
+
+	// 其他用户既不能通过 /user/update 也不能通过 /user/email 修改管理员的邮箱
+	planted := gin.H{"type": "student", "data": gin.H{"sid": "admin", "email": "me@evil.example", "emailverified": true}}
+	if w := putJSON(r, "/user/update", planted); w.Code != http.StatusUnauthorized {
+		t.Fatalf("非本人不能修改他人信息，得到 %d", w.Code)
+	}
+	if w := putJSON(r, "/user/email", gin.H{"sid": "admin", "email": "me@evil.example"}); w.Code != http.StatusOK {
+		t.Fatalf("修改本人邮箱应成功，得到 %d %s", w.Code, w.Body)
+	}
+	var admin models.Students
+	config.DB.Where("sid = ?", "admin").First(&admin)
+	if admin.Email != "" || admin.EmailVerified {
+		t.Fatalf("管理员的邮箱不应被修改: %+v", admin)
+	}
+
+	var before int64
+	config.DB.Model(&models.MailQueue{}).Count(&before)
+	if _, resp := postJSON(t, r, "/auth/password/forgot", gin.H{"account": "admin", "email": "me@evil.example"}); resp.Code != 200 {
+		t.Fatalf("找回密码应返回统一的结果，得到 %d", resp.Code)
+	}
+	var after, tokens int64
+	config.DB.Model(&models.MailQueue{}).Count(&after)
+	config.DB.Model(&models.PasswordResetTokens{}).Where("account = ?", "admin").Count(&tokens)
+	if after != before || tokens != 0 {
+		t.Fatalf("不应向他人设置的邮箱发送重置链接，新增 %d 封邮件、%d 个令牌", after-before, tokens)
+	}
+
+	// 对照：本人已验证的邮箱可以收到重置链接
+	config.DB.Model(&models.Students{}).Where("sid = ?", "admin").Updates(map[string]interface{}{"email": "admin@example.com", "email_verified": true})
+	postJSON(t, r, "/auth/password/forgot", gin.H{"account": "admin", "email": "admin@example.com"})
+	var mail models.MailQueue
+	if err := config.DB.Order("id DESC").First(&mail).Error; err != nil || mail.Recipient != "admin@example.com" {
+		t.Fatalf("应向已验证的邮箱发送重置链接: %+v, %v", mail, err)
+	}
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// StudentPortfolio 学生竞赛档案：参赛记录、获奖、证明材料、指导教师、证书及学分
+// format 可选 json/html/pdf，html 适合浏览器打印
+func StudentPortfolio(c *gin.Context) {
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+
+	sid := c.Query("sid")
+	if sid == "" && authUser.Identity == "student" {
+		sid = authUser.Account
+	}
+	if sid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if !canViewStudent(c, authUser, sid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "暂无权限"})
+		return
+	}
+
+	var student models.Students
+	if err := config.DB.Where("sid = ?", sid).First(&student).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "学生信息未找到"})
+		return
+	}
+
+	var records []models.Records
+	config.DB.Preload("Teacher").Preload("Race").Where("sid = ?", sid).Order("create_time DESC").Find(&records)
+
+	var recordIDs []int
+	for _, record := range records {
+		recordIDs = append(recordIDs, record.RecordID)
+	}
+	attachments := loadAttachments(models.AttachmentOwnerRecord, recordIDs)
+	certificates := make(map[int][]map[string]interface{})
+	if len(recordIDs) > 0 {
+		var rows []models.Certificates
+		config.DB.Where("record_id IN ?", recordIDs).Find(&rows)
+		for _, row := range rows {
+			certificates[row.RecordID] = append(certificates[row.RecordID], certificateDetail(row))
+		}
+	}
+
+	credits, err := services.CalculateCredits([]models.Students{student})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "学分计算失败"})
+		return
+	}
+
+	var entries []portfolioEntry
+	advisors := make(map[string]string)
+	awards := 0
+	for _, record := range records {
+		awarded := record.Status == models.RecordStatusApproved && record.Score != ""
+		if awarded {
+			awards++
+		}
+		if record.Teacher.TID != "" {
+			advisors[record.Teacher.TID] = record.Teacher.Name
+		}
+		entries = append(entries, portfolioEntry{
+			RecordID:     record.RecordID,
+			RaceTitle:    record.Race.Title,
+			Level:        record.Race.Level,
+			LevelName:    config.RaceLevelNames[record.Race.Level],
+			Type:         record.Race.Type,
+			Date:         record.Race.Startdate,
+			Award:        record.Score,
+			Awarded:      awarded,
+			Status:       record.Status,
+			TeamRole:     record.TeamRole,
+			Advisor:      record.Teacher.Name,
+			Attachments:  attachments[record.RecordID],
+			Certificates: certificates[record.RecordID],
+		})
+	}
+
+	portfolio := portfolioData{
+		SID:         student.SID,
+		Name:        student.Name,
+		Grade:       student.Grade,
+		Class:       student.Class,
+		RecordCount: len(records),
+		AwardCount:  awards,
+		Advisors:    advisors,
+		Credits:     credits[0],
+		Records:     entries,
+		GeneratedAt: time.Now(),
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": portfolio})
+	case "html":
+		var buf bytes.Buffer
+		if err := portfolioTemplate.Execute(&buf, portfolio); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "导出失败"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+	case "pdf":
+		pages, err := utils.RenderTable(student.Name+"（"+student.SID+"）竞赛档案", portfolioTable(portfolio))
+		if err != nil {
+			respondServiceError(c, err, "导出失败")
+			return
+		}
+		pdf, err := utils.ImageToPDF(pages...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "导出失败"})
+			return
+		}
+		sendFile(c, "portfolio_"+student.SID+".pdf", "application/pdf", pdf)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "不支持的导出格式"})
+	}
+}
+
+// canViewStudent 数据范围：学生只能查看自己，教师可以查看自己指导过的学生，拥有 user:query 权限可查看全部
+func canViewStudent(c *gin.Context, authUser models.AuthenticatedUser, sid string) bool {
+	if checkPermission(c, "user:query") {
+		return true
+	}
+	switch authUser.Identity {
+	case "student":
+		return authUser.Account == sid
+	case "teacher":
+		var count int64
+		config.DB.Model(&models.Records{}).Where("sid = ? AND tid = ?", sid, authUser.Account).Count(&count)
+		return count > 0
+	}
+	return false
+}
+
+type portfolioEntry struct {
+	RecordID     int                      `json:"record_id"`
+	RaceTitle    string                   `json:"race_title"`
+	Level        int                      `json:"level"`
+	LevelName    string                   `json:"level_name"`
+	Type         string                   `json:"type"`
+	Date         time.Time                `json:"date"`
+	Award        string                   `json:"award"`
+	Awarded      bool                     `json:"awarded"`
+	Status       int                      `json:"status"`
+	TeamRole     string                   `json:"team_role"`
+	Advisor      string                   `json:"advisor"`
+	Attachments  []map[string]interface{} `json:"attachments"`
+	Certificates []map[string]interface{} `json:"certificates"`
+}
+
+type portfolioData struct {
+	SID         string                  `json:"sid"`
+	Name        string                  `json:"name"`
+	Grade       int                     `json:"grade"`
+	Class       string                  `json:"class"`
+	RecordCount int                     `json:"record_count"`
+	AwardCount  int                     `json:"award_count"`
+	Advisors    map[string]string       `json:"advisors"`
+	Credits     services.StudentCredits `json:"credits"`
+	Records     []portfolioEntry        `json:"records"`
+	GeneratedAt time.Time               `json:"generated_at"`
+}
+
+// portfolioTable 导出 PDF 用的获奖记录表格
+func portfolioTable(portfolio portfolioData) [][]string {
+	table := [][]string{{"比赛名称", "级别", "比赛日期", "获奖等级", "指导教师"}}
+	for _, entry := range portfolio.Records {
+		if !entry.Awarded {
+			continue
+		}
+		table = append(table, []string{entry.RaceTitle, entry.LevelName, entry.Date.Format("2006-01-02"), entry.Award, entry.Advisor})
+	}
+	table = append(table, []string{"竞赛学分合计", "", "", strconv.FormatFloat(portfolio.Credits.Total, 'f', -1, 64), ""})
+	return table
+}
+
+var portfolioTemplate = template.Must(template.New("portfolio").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}（{{.SID}}）竞赛档案</title>
+<style>
+body { font-family: "Source Han Sans SC", "Microsoft YaHei", sans-serif; margin: 40px; color: #222; }
+h1 { text-align: center; font-size: 24px; }
+table { width: 100%; border-collapse: collapse; margin: 16px 0; }
+th, td { border: 1px solid #999; padding: 6px 10px; font-size: 14px; text-align: left; }
+th { background: #eee; }
+.meta { display: flex; justify-content: space-between; font-size: 14px; }
+.footer { margin-top: 24px; font-size: 12px; color: #666; text-align: right; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>学生竞赛档案</h1>
+<div class="meta">
+<span>姓名：{{.Name}}</span><span>学号：{{.SID}}</span><span>年级：{{.Grade}}</span><span>班级：{{.Class}}</span>
+</div>
+<div class="meta">
+<span>参赛 {{.RecordCount}} 次，获奖 {{.AwardCount}} 次</span><span>竞赛学分：{{.Credits.Total}}</span>
+</div>
+<table>
+<tr><th>比赛名称</th><th>级别</th><th>比赛日期</th><th>获奖等级</th><th>指导教师</th><th>证书编号</th></tr>
+{{range .Records}}{{if .Awarded}}<tr><td>{{.RaceTitle}}</td><td>{{.LevelName}}</td><td>{{date .Date}}</td><td>{{.Award}}</td><td>{{.Advisor}}</td><td>{{range .Certificates}}{{.serial}} {{end}}</td></tr>
+{{end}}{{end}}</table>
+{{if .Credits.Years}}<table>
+<tr><th>学年</th><th>获奖项数</th><th>学分（封顶前）</th><th>实得学分</th></tr>
+{{range .Credits.Years}}<tr><td>{{.AcademicYear}}</td><td>{{len .Items}}</td><td>{{.Raw}}</td><td>{{.Credits}}</td></tr>
+{{end}}</table>{{end}}
+<div class="footer">生成时间：{{date .GeneratedAt}}，证书真伪可凭证书编号及验证码查询</div>
+</body>
+</html>
+`))
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPortfolioPDFPaginatesLongRecordLists(t *testing.T) {
+	testutil.OpenDB(t)
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "欧阳娜娜", Password: "x", Sex: &sex, Grade: 2021, Class: "计科2101"})
+	for i := 1; i <= 75; i++ {
+		config.DB.Create(&models.Races{RaceID: i, Title: fmt.Sprintf("第%d届全国大学生程序设计竞赛", i), Level: 3, Startdate: time.Date(2023, 10, 1, 0, 0, 0, 0, time.Local)})
+		config.DB.Create(&models.Records{RecordID: i, SID: "20210001", RaceID: i, Status: models.RecordStatusApproved, Score: "一等奖", CreateTime: time.Now()})
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("authenticatedUser", models.AuthenticatedUser{Account: "20210001", Identity: "student"})
+	})
+	r.GET("/portfolio", StudentPortfolio)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/portfolio?format=pdf", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("导出 PDF 应成功，得到 %d %s", w.Code, w.Body)
+	}
+
+	// 75 条获奖记录加合计行共 76 行，每页 30 行，应分为 3 页
+	if n := bytes.Count(w.Body.Bytes(), []byte("/Type /Page ")); n != 3 {
+		t.Fatalf("PDF 应有 3 页，得到 %d", n)
+	}
+}
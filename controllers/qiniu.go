@@ -1,23 +1,147 @@
 package controllers
 
 import (
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
 	"competition-server/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 )
 
-// GetUploadToken 获取上传令牌
+// GetUploadToken 获取直传令牌，同时登记文件归属
+// 对象名由服务端按 direct/上传者/随机串_文件名 生成，客户端只能决定文件名部分，不能写入他人的对象
 func GetUploadToken(c *gin.Context) {
-	name := c.Query("name")
-	token := utils.GetToken(name)
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" || len(name) > 200 || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+	if authUser.ImpersonatedBy != "" {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "模拟登录期间不允许上传文件"})
+		return
+	}
+	random, err := utils.RandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成文件名失败"})
+		return
+	}
+	key := path.Join("direct", authUser.Account, random[:16]+"_"+name)
+
+	file := models.Files{ObjectKey: key, Owner: authUser.Account, Purpose: "direct", Name: name, CreateTime: time.Now()}
+	if err := config.DB.Create(&file).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "登记文件失败"})
+		return
+	}
+
+	credential, err := utils.GetToken(key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"msg": "生成上传令牌失败"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
+		"token": credential.Token,
+		"url":   credential.URL,
+		"key":   key,
 	})
 }
 
+// UploadFile 通过服务端代理上传文件，purpose 决定大小上限和允许的文件类型
+func UploadFile(c *gin.Context) {
+	purpose := c.Query("purpose")
+	policy, ok := config.UploadPolicies[purpose]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "未知的上传用途"})
+		return
+	}
+
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+	if authUser.ImpersonatedBy != "" {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "模拟登录期间不允许上传文件"})
+		return
+	}
+
+	// 预留 1MB 给 multipart 的边界和头部
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, policy.MaxSize+1<<20)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	// 逐个读取表单字段，找到 file 字段后直接流式写入
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "文件读取失败"})
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		result, err := utils.SaveUpload(purpose, authUser.Account, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			var validationErr *config.ValidationError
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &validationErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Error()})
+			} else if errors.As(err, &maxBytesErr) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"code": 413, "msg": "文件过大"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "上传失败"})
+			}
+			return
+		}
+
+		file := models.Files{
+			ObjectKey:  result.Key,
+			Owner:      authUser.Account,
+			Purpose:    purpose,
+			Name:       result.Name,
+			Size:       result.Size,
+			Hash:       result.Hash,
+			MimeType:   result.MimeType,
+			CreateTime: time.Now(),
+		}
+		// 对象名包含上传者账号，已有记录却属于他人说明数据异常，不能借此获得他人文件的归属
+		if err := config.DB.Where("object_key = ?", file.ObjectKey).FirstOrCreate(&file).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "登记文件失败"})
+			return
+		}
+		if file.Owner != authUser.Account {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "文件已被他人占用"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "上传成功", "data": result})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "缺少文件"})
+}
+
 // GetFileUrl 获取文件下载链接
 func GetFileUrl(c *gin.Context) {
 	filename := c.Query("filename")
+	if ok, status, msg := checkFileAccess(c, filename, "query"); !ok {
+		c.JSON(status, gin.H{"code": status, "msg": msg})
+		return
+	}
 	url := utils.GetFileUrl(filename)
 	c.JSON(http.StatusOK, gin.H{
 		"url": url,
@@ -27,6 +151,10 @@ func GetFileUrl(c *gin.Context) {
 // RefreshFileUrl 刷新文件 CDN 缓存
 func RefreshFileUrl(c *gin.Context) {
 	name := c.Query("name")
+	if ok, status, msg := checkFileAccess(c, name, "update"); !ok {
+		c.JSON(status, gin.H{"code": status, "msg": msg})
+		return
+	}
 	err := utils.RefreshUrl(name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"msg": "刷新失败"})
@@ -38,6 +166,10 @@ func RefreshFileUrl(c *gin.Context) {
 // GetFileInfo 获取文件信息
 func GetFileInfo(c *gin.Context) {
 	name := c.Query("name")
+	if ok, status, msg := checkFileAccess(c, name, "query"); !ok {
+		c.JSON(status, gin.H{"code": status, "msg": msg})
+		return
+	}
 	info, err := utils.GetFileInfo(name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"msg": "获取文件信息失败"})
@@ -48,17 +180,80 @@ func GetFileInfo(c *gin.Context) {
 	})
 }
 
-// DeleteFile 删除文件
+// DeleteFile 删除文件，同时删除文件元数据和引用该文件的附件
 func DeleteFile(c *gin.Context) {
 	var names []string
 	if err := c.BindJSON(&names); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"msg": "参数错误"})
 		return
 	}
+	for _, name := range names {
+		if ok, status, msg := checkFileAccess(c, name, "delete"); !ok {
+			services.Audit(c, "file:delete", "file", strings.Join(names, ","), gin.H{"keys": names, "reason": msg}, errors.New(msg))
+			c.JSON(status, gin.H{"code": status, "msg": msg})
+			return
+		}
+	}
+
 	err := utils.DeleteFile(names)
+	if err == nil {
+		err = config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("object_key IN ?", names).Delete(&models.Attachments{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("object_key IN ?", names).Delete(&models.Files{}).Error
+		})
+	}
+	services.Audit(c, "file:delete", "file", strings.Join(names, ","), gin.H{"keys": names}, err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"msg": "删除失败"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"msg": "删除成功"})
 }
+
+// checkFileAccess 检查当前用户能否对文件执行 action（query/update/delete）
+// 文件上传者可以操作自己的文件，其他人需要 file:action 权限
+func checkFileAccess(c *gin.Context, key string, action string) (bool, int, string) {
+	if key == "" {
+		return false, http.StatusBadRequest, "参数有误"
+	}
+	if checkPermission(c, "file:"+action) {
+		return true, 0, ""
+	}
+
+	var file models.Files
+	if err := config.DB.Where("object_key = ?", key).First(&file).Error; err != nil {
+		return false, http.StatusNotFound, "文件不存在"
+	}
+	user, _ := c.Get("authenticatedUser")
+	if file.Owner == user.(models.AuthenticatedUser).Account {
+		return true, 0, ""
+	}
+	return false, http.StatusUnauthorized, "暂无权限"
+}
+
+// FileGCReport 生成孤立文件回收报告，不删除任何文件
+func FileGCReport(c *gin.Context) {
+	report, err := services.CollectOrphanFiles(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成报告失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": report})
+}
+
+// RunFileGC 立即执行一次孤立文件回收
+func RunFileGC(c *gin.Context) {
+	report, err := services.CollectOrphanFiles(false)
+	var detail interface{}
+	if report != nil {
+		detail = gin.H{"deleted": len(report.Orphans), "reclaim_bytes": report.ReclaimBytes, "stale_records": report.StaleRecords}
+	}
+	services.Audit(c, "file:gc", "file", "", detail, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "回收失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "回收成功", "data": report})
+}
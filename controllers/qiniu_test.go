@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// fileRouter 以指定用户身份访问文件接口
+func fileRouter(t *testing.T, user models.AuthenticatedUser) *gin.Engine {
+	t.Helper()
+	storage, err := utils.NewLocalStorage(t.TempDir(), "http://localhost/files", "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.FileStorage
+	utils.FileStorage = storage
+	t.Cleanup(func() { utils.FileStorage = previous })
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("authenticatedUser", user) })
+	r.GET("/file/get_upload_token", GetUploadToken)
+	r.POST("/file/upload", UploadFile)
+	return r
+}
+
+func getUploadToken(r *gin.Engine, name string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/file/get_upload_token?name="+url.QueryEscape(name), nil))
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func upload(r *gin.Engine, purpose, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(content)
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/file/upload?purpose="+purpose, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetUploadTokenBuildsKeyFromAccount(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.CreateUser(t, "other", "student", 3, "pw")
+	if err := config.DB.Create(&models.Files{ObjectKey: "report.pdf", Owner: "other", Purpose: "direct"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := fileRouter(t, models.AuthenticatedUser{Account: "20210001", Identity: "student"})
+
+	for _, name := range []string{"", "../report.pdf", "other/report.pdf", `a\b.pdf`, ".."} {
+		if w, _ := getUploadToken(r, name); w.Code != http.StatusBadRequest {
+			t.Fatalf("非法文件名 %q 应被拒绝，得到 %d", name, w.Code)
+		}
+	}
+
+	w, resp := getUploadToken(r, "report.pdf")
+	key, _ := resp["key"].(string)
+	if w.Code != http.StatusOK || !strings.HasPrefix(key, "direct/20210001/") || !strings.HasSuffix(key, "_report.pdf") {
+		t.Fatalf("对象名应由服务端按上传者生成，得到 %d %q", w.Code, key)
+	}
+	var file models.Files
+	if err := config.DB.Where("object_key = ?", key).First(&file).Error; err != nil || file.Owner != "20210001" {
+		t.Fatalf("应登记文件归属: %+v, %v", file, err)
+	}
+	var existing models.Files
+	if err := config.DB.Where("object_key = ?", "report.pdf").First(&existing).Error; err != nil || existing.Owner != "other" {
+		t.Fatal("不应改动他人的同名文件")
+	}
+
+	_, again := getUploadToken(r, "report.pdf")
+	if again["key"] == key {
+		t.Fatal("重复申请同名文件应生成不同的对象名")
+	}
+}
+
+func TestUploadRefusedDuringImpersonation(t *testing.T) {
+	testutil.OpenDB(t)
+	r := fileRouter(t, models.AuthenticatedUser{Account: "20210001", Identity: "student", ImpersonatedBy: "admin"})
+
+	if w, _ := getUploadToken(r, "report.pdf"); w.Code != http.StatusForbidden {
+		t.Fatalf("模拟登录期间不能申请上传令牌，得到 %d", w.Code)
+	}
+	if w := upload(r, "evidence", "a.pdf", []byte("%PDF-1.4 test")); w.Code != http.StatusForbidden {
+		t.Fatalf("模拟登录期间不能上传文件，得到 %d", w.Code)
+	}
+	var count int64
+	config.DB.Model(&models.Files{}).Count(&count)
+	if count != 0 {
+		t.Fatal("不应登记文件")
+	}
+}
+
+func TestUploadRejectsRowOwnedByOthers(t *testing.T) {
+	testutil.OpenDB(t)
+	r := fileRouter(t, models.AuthenticatedUser{Account: "20210001", Identity: "student"})
+	content := []byte("%PDF-1.4 test")
+
+	w := upload(r, "evidence", "a.pdf", content)
+	if w.Code != http.StatusOK {
+		t.Fatalf("上传应成功，得到 %d %s", w.Code, w.Body)
+	}
+
+	// 对象名包含上传者账号，若已有记录属于他人则拒绝，不能借此把他人的文件登记到自己名下或反之
+	sum := sha256.Sum256(content)
+	key := "evidence/20210001/" + hex.EncodeToString(sum[:]) + ".pdf"
+	config.DB.Model(&models.Files{}).Where("object_key = ?", key).Update("owner", "other")
+	if w := upload(r, "evidence", "a.pdf", content); w.Code != http.StatusForbidden {
+		t.Fatalf("已被他人登记的对象应被拒绝，得到 %d %s", w.Code, w.Body)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"competition-server/config"
 	"competition-server/models"
+	"competition-server/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -73,11 +74,13 @@ func AddRace(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "数据库错误", "error": err.Error()})
 		return
 	}
+	services.SaveRevision(c, models.RevisionEntityRace, data.RaceID, models.RevisionActionCreate)
+	services.NotifyRacePublished(data)
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功"})
 }
 
-// DeleteRace handles DELETE requests to delete races
+// DeleteRace 删除比赛，比赛及其参赛记录移入回收站
 func DeleteRace(c *gin.Context) {
 	var data []int
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -85,7 +88,14 @@ func DeleteRace(c *gin.Context) {
 		return
 	}
 
-	config.DB.Delete(&models.Races{}, data)
+	var before []models.Races
+	config.DB.Where("race_id IN ?", data).Find(&before)
+	services.AuditTarget(c, "race", intsToString(data), before, nil)
+
+	if err := services.DeleteRaces(data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
 }
 
@@ -102,6 +112,15 @@ func UpdateRace(c *gin.Context) {
 		return
 	}
 
+	var current models.Races
+	config.DB.Where("race_id = ?", data.RaceID).First(&current)
+
+	services.BeginRevision(models.RevisionEntityRace, data.RaceID)
 	config.DB.Model(&models.Races{}).Where("race_id = ?", data.RaceID).Updates(data)
+	// 截止日期修改后需要重新提醒
+	if !data.Enddate.IsZero() && !data.Enddate.Equal(current.Enddate) {
+		config.DB.Model(&models.Races{}).Where("race_id = ?", data.RaceID).Update("deadline_reminded", false)
+	}
+	services.SaveRevision(c, models.RevisionEntityRace, data.RaceID, models.RevisionActionUpdate)
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
 }
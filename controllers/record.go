@@ -3,6 +3,7 @@ package controllers
 import (
 	"competition-server/config"
 	"competition-server/models"
+	"competition-server/services"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
@@ -37,6 +38,12 @@ func ListRecords(c *gin.Context) {
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "1"))
 	query.Count(&count).Limit(limit).Offset(limit * (offset - 1)).Find(&records).Order("create_time DESC")
 
+	var recordIDs []int
+	for _, record := range records {
+		recordIDs = append(recordIDs, record.RecordID)
+	}
+	attachments := loadAttachments(models.AttachmentOwnerRecord, recordIDs)
+
 	var result []map[string]interface{}
 	for _, record := range records {
 		result = append(result, map[string]interface{}{
@@ -49,6 +56,7 @@ func ListRecords(c *gin.Context) {
 			"create_time": record.CreateTime,
 			"update_time": record.UpdateTime,
 			"description": record.Description,
+			"attachments": attachments[record.RecordID],
 		})
 	}
 
@@ -63,10 +71,11 @@ func ListRecords(c *gin.Context) {
 // AddRecord 处理 POST 请求以添加新记录
 func AddRecord(c *gin.Context) {
 	var input struct {
-		RaceID int    `json:"race_id"`
-		SID    string `json:"sid"`
-		Score  string `json:"score"`
-		TID    string `json:"tid"`
+		RaceID   int    `json:"race_id"`
+		SID      string `json:"sid"`
+		Score    string `json:"score"`
+		TID      string `json:"tid"`
+		TeamRole string `json:"team_role"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -74,13 +83,16 @@ func AddRecord(c *gin.Context) {
 		return
 	}
 	//指导老师和成绩为可选字段
-	var TID string
-	var Score string
-	if input.TID == "" {
-		TID = ""
-	}
-	if input.Score == "" {
-		Score = ""
+	TID := input.TID
+	Score := input.Score
+	teamRole := input.TeamRole
+	switch teamRole {
+	case "":
+		teamRole = models.TeamRoleIndividual
+	case models.TeamRoleIndividual, models.TeamRoleLeader, models.TeamRoleMember:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "无效的团队角色"})
+		return
 	}
 	// 创建记录数据
 	data := models.Records{
@@ -88,6 +100,7 @@ func AddRecord(c *gin.Context) {
 		SID:         input.SID,
 		TID:         TID,
 		Score:       Score,
+		TeamRole:    teamRole,
 		Status:      0,  // 默认值
 		Description: "", // 默认值
 		CreateTime:  time.Now(),
@@ -99,11 +112,22 @@ func AddRecord(c *gin.Context) {
 		return
 	}
 
-	config.DB.Create(&data)
+	if err := config.DB.Create(&data).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "创建失败"})
+		return
+	}
+
+	// 选择了指导教师时通知教师，记录 ID 由数据库生成需要重新查询
+	if data.TID != "" {
+		var created models.Records
+		if err := config.DB.Preload("Race").Preload("Student").Where("race_id = ? AND sid = ?", data.RaceID, data.SID).First(&created).Error; err == nil {
+			services.NotifyAdvisorRequest(created, created.Race.Title, created.Student.Name)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "创建成功"})
 }
 
-// DeleteRecord 处理 DELETE 请求以删除记录
+// DeleteRecord 处理 DELETE 请求以删除记录，删除的记录移入回收站
 func DeleteRecord(c *gin.Context) {
 	var data []int
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -111,7 +135,10 @@ func DeleteRecord(c *gin.Context) {
 		return
 	}
 
-	config.DB.Delete(&models.Records{}, data)
+	if err := services.DeleteRecords(data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
 }
 
@@ -128,6 +155,7 @@ func UpdateRecord(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "记录不存在"})
 		return
 	}
+	services.AuditTarget(c, "record", strconv.Itoa(record.RecordID), gin.H{"score": record.Score}, gin.H{"score": data.Score})
 
 	var student models.Students
 	if err := config.DB.Model(&record).Association("Student").Find(&student); err != nil {
@@ -136,22 +164,71 @@ func UpdateRecord(c *gin.Context) {
 	}
 
 	if checkPermission(c, "record:update") {
+		services.BeginRevision(models.RevisionEntityRecord, record.RecordID)
 		if err := config.DB.Model(&models.Records{}).Where("record_id = ?", data.RecordID).Update("score", data.Score).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
 			return
 		}
+		services.SaveRevision(c, models.RevisionEntityRecord, record.RecordID, models.RevisionActionUpdate)
 		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
 	} else if student.SID == c.GetString("account") && c.GetString("identity") == "student" {
+		services.BeginRevision(models.RevisionEntityRecord, record.RecordID)
 		if err := config.DB.Model(&record).Update("score", data.Score).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
 			return
 		}
+		services.SaveRevision(c, models.RevisionEntityRecord, record.RecordID, models.RevisionActionUpdate)
 		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
 	} else {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "暂无权限"})
 	}
 }
 
+// UpdateRecordStatus 处理 PATCH 请求以审核参赛记录
+func UpdateRecordStatus(c *gin.Context) {
+	var input struct {
+		RecordID    int    `json:"record_id"`
+		Status      int    `json:"status"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if input.Status != models.RecordStatusPending && input.Status != models.RecordStatusApproved && input.Status != models.RecordStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "无效的审核状态"})
+		return
+	}
+
+	var record models.Records
+	if err := config.DB.Preload("Race").Where("record_id = ?", input.RecordID).First(&record).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "记录不存在"})
+		return
+	}
+
+	if err := services.CheckRecordEvidence(record, input.Status); err != nil {
+		respondServiceError(c, err, "审核失败")
+		return
+	}
+
+	services.BeginRevision(models.RevisionEntityRecord, record.RecordID)
+	if err := config.DB.Model(&models.Records{}).Where("record_id = ?", record.RecordID).Updates(map[string]interface{}{
+		"status":      input.Status,
+		"description": input.Description,
+		"update_time": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "审核失败"})
+		return
+	}
+	services.SaveRevision(c, models.RevisionEntityRecord, record.RecordID, models.RevisionActionStatus)
+
+	record.Status = input.Status
+	record.Description = input.Description
+	services.NotifyRecordStatus(record, record.Race.Title)
+
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "审核成功"})
+}
+
 // validateRecord 验证记录数据
 func validateRecord(data models.Records) string {
 	if data.RaceID == 0 || data.SID == "" {
@@ -174,7 +251,7 @@ func validateRecord(data models.Records) string {
 	}
 
 	if data.TID != "" {
-		if err := config.DB.First(&models.Teachers{}, data.TID).Error; err != nil {
+		if err := config.DB.Where("tid = ?", data.TID).First(&models.Teachers{}).Error; err != nil {
 			return "教师信息不存在"
 		}
 	}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recyclePermissions 回收站数据类型对应的权限，能删除的人才能查看和恢复
+var recyclePermissions = map[string]string{
+	"race":    "race:delete",
+	"record":  "record:delete",
+	"student": "user:delete",
+	"teacher": "user:delete",
+}
+
+// ListRecycleBin 查询回收站中的比赛、参赛记录、学生或教师，按删除时间倒序
+func ListRecycleBin(c *gin.Context) {
+	recycleType := c.Query("type")
+	permission, ok := recyclePermissions[recycleType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "未知的类型"})
+		return
+	}
+	if !checkPermission(c, permission) {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "暂无权限"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "1"))
+	query := config.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC")
+
+	var count int64
+	var data interface{}
+	var err error
+	switch recycleType {
+	case "race":
+		var races []models.Races
+		err = query.Model(&models.Races{}).Count(&count).Limit(limit).Offset(limit * (offset - 1)).Find(&races).Error
+		data = races
+	case "record":
+		var records []models.Records
+		err = query.Model(&models.Records{}).Preload("Race", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+			Preload("Student", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Omit("password") }).
+			Count(&count).Limit(limit).Offset(limit * (offset - 1)).Find(&records).Error
+		data = records
+	case "student":
+		var students []models.Students
+		err = query.Model(&models.Students{}).Omit("password").Count(&count).Limit(limit).Offset(limit * (offset - 1)).Find(&students).Error
+		data = students
+	case "teacher":
+		var teachers []models.Teachers
+		err = query.Model(&models.Teachers{}).Omit("password").Count(&count).Limit(limit).Offset(limit * (offset - 1)).Find(&teachers).Error
+		data = teachers
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":      200,
+		"msg":       "查询成功",
+		"count":     count,
+		"retention": config.RecycleRetention.String(),
+		"data":      data,
+	})
+}
+
+// RestoreRace 从回收站恢复比赛，与比赛一同删除的参赛记录一并恢复
+func RestoreRace(c *gin.Context) {
+	var data []int
+	if err := c.ShouldBindJSON(&data); err != nil || len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	services.AuditTarget(c, "race", intsToString(data), nil, nil)
+	restoreResponse(c, services.RestoreRaces(data))
+}
+
+// RestoreRecord 从回收站恢复参赛记录
+func RestoreRecord(c *gin.Context) {
+	var data []int
+	if err := c.ShouldBindJSON(&data); err != nil || len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	services.AuditTarget(c, "record", intsToString(data), nil, nil)
+	restoreResponse(c, services.RestoreRecords(data))
+}
+
+// RestoreUsers 从回收站恢复学生/教师及其账户，请求格式与 DeleteUsers 相同
+func RestoreUsers(c *gin.Context) {
+	var requestData struct {
+		Type string `json:"type"`
+		Data struct {
+			IDs []string `json:"ids"`
+		} `json:"data"`
+	}
+	if err := c.ShouldBindJSON(&requestData); err != nil || len(requestData.Data.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	services.AuditTarget(c, requestData.Type, strings.Join(requestData.Data.IDs, ","), nil, nil)
+	switch requestData.Type {
+	case "student":
+		restoreResponse(c, services.RestoreStudents(requestData.Data.IDs))
+	case "teacher":
+		restoreResponse(c, services.RestoreTeachers(requestData.Data.IDs))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "未知的类型"})
+	}
+}
+
+func restoreResponse(c *gin.Context, err error) {
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "恢复失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "恢复成功"})
+}
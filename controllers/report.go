@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// TeacherReport 教师指导工作量及获奖报表，format 可选 json/xlsx/pdf
+// 拥有 user:export 权限可查看全部教师，教师本人只能查看自己的报表
+func TeacherReport(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+
+	tid := c.Query("tid")
+	if !checkPermission(c, "user:export") {
+		user, _ := c.Get("authenticatedUser")
+		authUser := user.(models.AuthenticatedUser)
+		if authUser.Identity != "teacher" || (tid != "" && tid != authUser.Account) {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "暂无权限"})
+			return
+		}
+		tid = authUser.Account
+	}
+
+	reports, err := services.BuildTeacherReport(filter, tid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成报表失败"})
+		return
+	}
+
+	title := "教师指导竞赛工作量统计"
+	if filter.Start != nil || filter.End != nil {
+		title += "（" + formatRange(filter) + "）"
+	}
+	filename := "teacher_report_" + time.Now().Format("20060102")
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(reports), "data": reports})
+	case "xlsx":
+		var buf bytes.Buffer
+		if err := utils.WriteXLSX(&buf, "教师报表", services.TeacherReportTable(reports)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "导出失败"})
+			return
+		}
+		sendFile(c, filename+".xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+	case "pdf":
+		pages, err := utils.RenderTable(title, services.TeacherReportTable(reports))
+		if err != nil {
+			respondServiceError(c, err, "导出失败")
+			return
+		}
+		pdf, err := utils.ImageToPDF(pages...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "导出失败"})
+			return
+		}
+		sendFile(c, filename+".pdf", "application/pdf", pdf)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "不支持的导出格式"})
+	}
+}
+
+// sendFile 以附件形式返回文件
+func sendFile(c *gin.Context, filename, contentType string, data []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// formatRange 格式化统计的时间范围
+func formatRange(filter services.StatsFilter) string {
+	start, end := "", ""
+	if filter.Start != nil {
+		start = filter.Start.Format("2006-01-02")
+	}
+	if filter.End != nil {
+		end = filter.End.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	return start + " ~ " + end
+}
@@ -7,6 +7,7 @@ import (
 
 	"competition-server/config"
 	"competition-server/models"
+	"competition-server/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -202,6 +203,10 @@ func GrantRole(c *gin.Context) {
 		return
 	}
 
+	var before models.User
+	config.DB.Where("account = ?", data.Account).First(&before)
+	services.AuditTarget(c, data.Type, data.Account, gin.H{"role_id": before.RoleID}, gin.H{"role_id": data.RoleID})
+
 	// 更新用户表中的角色 ID
 	if err := config.DB.Model(&models.User{}).Where("account = ?", data.Account).Update("role_id", data.RoleID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新用户表中的角色 ID 失败"})
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+)
+
+// checkRoleGrantable 只能为服务账号分配操作人自身权限范围内的角色，避免借服务账号提升权限
+func checkRoleGrantable(c *gin.Context, roleID int) error {
+	user, _ := c.Get("authenticatedUser")
+	return services.CheckRoleGrantable(user.(models.AuthenticatedUser).Permissions, roleID)
+}
+
+// respondServiceError 校验失败返回 400，其它错误返回 500
+func respondServiceError(c *gin.Context, err error, msg string) {
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": msg})
+}
+
+// ListServiceAccounts 查询服务账号
+func ListServiceAccounts(c *gin.Context) {
+	var accounts []models.ServiceAccounts
+	var count int64
+	query := config.DB.Model(&models.ServiceAccounts{})
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("account LIKE ? OR name LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "1"))
+	if err := query.Count(&count).Order("create_time DESC").Limit(limit).Offset(limit * (offset - 1)).Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": count, "data": accounts})
+}
+
+// AddServiceAccount 添加服务账号，账号不能与学生、教师账号重复
+func AddServiceAccount(c *gin.Context) {
+	var req struct {
+		Account     string `json:"account" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		RoleID      int    `json:"role_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if err := checkRoleGrantable(c, req.RoleID); err != nil {
+		respondServiceError(c, err, "查询角色失败")
+		return
+	}
+
+	var count int64
+	config.DB.Unscoped().Model(&models.User{}).Where("account = ?", req.Account).Count(&count)
+	if count == 0 {
+		config.DB.Model(&models.ServiceAccounts{}).Where("account = ?", req.Account).Count(&count)
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "账号已存在"})
+		return
+	}
+
+	now := time.Now()
+	account := models.ServiceAccounts{
+		Account:     req.Account,
+		Name:        req.Name,
+		Description: req.Description,
+		RoleID:      req.RoleID,
+		CreatedBy:   currentAccount(c),
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	if err := config.DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "添加失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "添加成功", "data": account})
+}
+
+// UpdateServiceAccount 修改服务账号的名称、描述、角色或停用状态
+func UpdateServiceAccount(c *gin.Context) {
+	var req struct {
+		Account     string `json:"account" binding:"required"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		RoleID      int    `json:"role_id"`
+		Disabled    *bool  `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var before models.ServiceAccounts
+	if err := config.DB.Where("account = ?", req.Account).First(&before).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "服务账号不存在"})
+		return
+	}
+
+	updates := map[string]interface{}{"update_time": time.Now()}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+	if req.RoleID != 0 && req.RoleID != before.RoleID {
+		if err := checkRoleGrantable(c, req.RoleID); err != nil {
+			respondServiceError(c, err, "查询角色失败")
+			return
+		}
+		updates["role_id"] = req.RoleID
+	}
+	if req.Disabled != nil {
+		updates["disabled"] = *req.Disabled
+	}
+	services.AuditTarget(c, "service_account", req.Account, gin.H{"role_id": before.RoleID, "disabled": before.Disabled}, updates)
+	if err := config.DB.Model(&models.ServiceAccounts{}).Where("account = ?", req.Account).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "修改失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "修改成功"})
+}
+
+// DeleteServiceAccount 删除服务账号并撤销其全部 API 密钥
+func DeleteServiceAccount(c *gin.Context) {
+	var req struct {
+		Account string `json:"account" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	now := time.Now()
+	if err := config.DB.Model(&models.APIKeys{}).Where("service_account = ? AND revoked_time IS NULL", req.Account).
+		Update("revoked_time", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "撤销密钥失败"})
+		return
+	}
+	result := config.DB.Where("account = ?", req.Account).Delete(&models.ServiceAccounts{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "服务账号不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "删除成功"})
+}
+
+// ListAPIKeys 查询服务账号的 API 密钥，只返回前缀，不返回完整密钥
+func ListAPIKeys(c *gin.Context) {
+	var keys []models.APIKeys
+	query := config.DB.Model(&models.APIKeys{})
+	if account := c.Query("account"); account != "" {
+		query = query.Where("service_account = ?", account)
+	}
+	if err := query.Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(keys), "data": keys})
+}
+
+// CreateAPIKey 为服务账号创建 API 密钥，完整密钥只在本次响应中返回
+// scopes 为权限列表（type:action），为空表示使用服务账号角色的全部权限；expires_in 为有效天数，0 表示不过期
+// 服务账号的角色和 scopes 都不能超出操作人自身的权限
+func CreateAPIKey(c *gin.Context) {
+	var req struct {
+		Account   string   `json:"account" binding:"required"`
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn int      `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if req.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "有效天数不能为负数"})
+		return
+	}
+	var expires *time.Time
+	if req.ExpiresIn > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresIn)
+		expires = &t
+	}
+
+	user, _ := c.Get("authenticatedUser")
+	key, record, err := services.CreateAPIKey(req.Account, req.Name, req.Scopes, expires, user.(models.AuthenticatedUser))
+	if err != nil {
+		respondServiceError(c, err, "创建失败")
+		return
+	}
+	services.AuditTarget(c, "api_key", strconv.Itoa(record.ID), nil, record)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "创建成功，密钥只显示这一次，请妥善保存", "data": gin.H{"key": key, "api_key": record}})
+}
+
+// RevokeAPIKey 撤销 API 密钥，撤销后立即失效
+func RevokeAPIKey(c *gin.Context) {
+	var req struct {
+		ID int `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	result := config.DB.Model(&models.APIKeys{}).Where("id = ? AND revoked_time IS NULL", req.ID).Update("revoked_time", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "撤销失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "密钥不存在或已撤销"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "撤销成功"})
+}
+
+// APIKeyUsage 查询 API 密钥的每日调用次数，start/end 格式为 2006-01-02，默认最近 30 天
+func APIKeyUsage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	end := time.Now()
+	start := end.AddDate(0, 0, -30)
+	if value := c.Query("start"); value != "" {
+		if start, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "开始日期格式有误"})
+			return
+		}
+	}
+	if value := c.Query("end"); value != "" {
+		if end, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "结束日期格式有误"})
+			return
+		}
+	}
+
+	var usage []models.APIKeyUsage
+	if err := config.DB.Where("key_id = ? AND date BETWEEN ? AND ?", id, start.Format("2006-01-02"), end.Format("2006-01-02")).
+		Order("date ASC").Find(&usage).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	var total int64
+	for _, day := range usage {
+		total += day.Count
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(usage), "data": gin.H{"total": total, "days": usage}})
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// sessionView 会话列表项，Current 标记发起请求的会话
+type sessionView struct {
+	models.UserSessions
+	Current bool `json:"current"`
+}
+
+// ListSessions 查询当前用户已登录的设备
+func ListSessions(c *gin.Context) {
+	sessions, err := services.ListSessions(currentAccount(c), c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	current := c.GetString("sessionID")
+	data := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, sessionView{UserSessions: session, Current: session.ID == current})
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(data), "data": data})
+}
+
+// RevokeSession 注销当前用户的某个会话，注销当前会话时同时清除登录 Cookie
+func RevokeSession(c *gin.Context) {
+	var req struct {
+		ID string `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	if err := services.RevokeSession(currentAccount(c), req.ID, c.GetString("sessionID")); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "注销失败"})
+		return
+	}
+	if req.ID == c.GetString("sessionID") {
+		utils.SetCookie(c, "uid", "", -1, true)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "注销成功"})
+}
+
+// RevokeOtherSessions 注销当前用户除本设备外的全部会话
+func RevokeOtherSessions(c *gin.Context) {
+	count, err := services.RevokeSessions(config.DB, currentAccount(c), c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "注销失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "注销成功", "data": gin.H{"count": count}})
+}
+
+// Logout 退出登录，注销当前会话并清除登录 Cookie
+func Logout(c *gin.Context) {
+	if sid := c.GetString("sessionID"); sid != "" {
+		if err := services.RevokeSession(currentAccount(c), sid, sid); err != nil {
+			var validationErr *config.ValidationError
+			if !errors.As(err, &validationErr) {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "退出登录失败"})
+				return
+			}
+		}
+	}
+	utils.SetCookie(c, "uid", "", -1, true)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已退出登录"})
+}
+
+// TerminateSessions 管理员强制账户下线，注销该账户的全部会话
+func TerminateSessions(c *gin.Context) {
+	var req struct {
+		Account string `json:"account" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	var count int64
+	config.DB.Model(&models.User{}).Where("account = ?", req.Account).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "用户不存在"})
+		return
+	}
+
+	revoked, err := services.RevokeSessions(config.DB, req.Account, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "强制下线失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已强制下线", "data": gin.H{"count": revoked}})
+}
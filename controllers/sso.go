@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// ssoRedirect 单点登录结束后跳转回前端，结果通过查询参数传递
+func ssoRedirect(c *gin.Context, params url.Values) {
+	target := config.SSOPostLoginURL
+	if strings.Contains(target, "?") {
+		target += "&" + params.Encode()
+	} else {
+		target += "?" + params.Encode()
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// ssoError 单点登录失败时跳转回前端并附带错误信息
+func ssoError(c *gin.Context, msg string) {
+	ssoRedirect(c, url.Values{"error": {msg}})
+}
+
+// ssoComplete 身份提供方认证通过后，映射为本地账户并签发登录令牌
+// 首次登录的用户按配置自动创建档案；已启用两步验证的用户跳转回前端时附带临时令牌，继续提交验证码
+func ssoComplete(c *gin.Context, profile *services.SSOProfile) {
+	user, err := services.ProvisionUser(profile)
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		ssoError(c, validationErr.Message)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("account", profile.Account).Msg("单点登录账户处理失败")
+		ssoError(c, "登录失败")
+		return
+	}
+
+	enabled, err := services.TwoFactorEnabled(user.Account)
+	if err != nil {
+		ssoError(c, "查询两步验证失败")
+		return
+	}
+	if enabled {
+		challenge, err := twoFactorChallenge(*user)
+		if err != nil {
+			ssoError(c, "生成令牌失败")
+			return
+		}
+		ssoRedirect(c, url.Values{"challenge": {challenge}})
+		return
+	}
+	ssoLogin(c, *user)
+}
+
+// ssoLogin 签发登录令牌后跳转回前端，附带登录后需要完成的操作
+func ssoLogin(c *gin.Context, user models.User) {
+	data, err := loginResult(c, user, false)
+	if err != nil {
+		ssoError(c, "生成令牌失败")
+		return
+	}
+	params := url.Values{}
+	for key, value := range data {
+		if flag, ok := value.(bool); ok {
+			params.Set(key, strconv.FormatBool(flag))
+		}
+	}
+	ssoRedirect(c, params)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+)
+
+// parseStatsFilter 解析统计筛选条件：year 或 start/end（yyyy-mm-dd，含起止日期），比赛级别和类型，以及学生所属学院
+func parseStatsFilter(c *gin.Context) (services.StatsFilter, bool) {
+	var filter services.StatsFilter
+	if year := c.Query("year"); year != "" {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return filter, false
+		}
+		start := time.Date(y, 1, 1, 0, 0, 0, 0, time.Local)
+		end := start.AddDate(1, 0, 0)
+		filter.Start, filter.End = &start, &end
+	}
+	if start := c.Query("start"); start != "" {
+		t, err := time.ParseInLocation("2006-01-02", start, time.Local)
+		if err != nil {
+			return filter, false
+		}
+		filter.Start = &t
+	}
+	if end := c.Query("end"); end != "" {
+		t, err := time.ParseInLocation("2006-01-02", end, time.Local)
+		if err != nil {
+			return filter, false
+		}
+		t = t.AddDate(0, 0, 1)
+		filter.End = &t
+	}
+	if level := c.Query("level"); level != "" {
+		l, err := strconv.Atoi(level)
+		if err != nil {
+			return filter, false
+		}
+		filter.Level = &l
+	}
+	filter.Type = c.Query("type")
+	if college := c.Query("college_id"); college != "" {
+		id, err := strconv.Atoi(college)
+		if err != nil {
+			return filter, false
+		}
+		filter.CollegeID = &id
+	}
+
+	if c.Query("refresh") == "1" {
+		services.ClearStatsCache()
+	}
+	return filter, true
+}
+
+// StatsSummary 总体统计
+func StatsSummary(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	summary, err := services.GetStatsSummary(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "统计失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": summary})
+}
+
+// StatsGroup 按比赛级别、类型、年级、班级、学院、专业、指导教师、年份或获奖等级分组统计
+func StatsGroup(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	rows, err := services.GetStatsGroup(c.Query("by"), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(rows), "data": rows})
+}
+
+// StatsTrend 按月或按年的趋势统计
+func StatsTrend(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	rows, err := services.GetStatsTrend(c.Query("interval"), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(rows), "data": rows})
+}
+
+// StatsTop 学生、教师、比赛、班级或学院的获奖排行榜
+func StatsTop(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+		return
+	}
+	n, _ := strconv.Atoi(c.DefaultQuery("n", "10"))
+	rows, err := services.GetStatsTop(c.Query("by"), n, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "count": len(rows), "data": rows})
+}
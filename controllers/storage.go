@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// localStorage 获取本地存储后端，未启用本地存储时返回 nil
+func localStorage() *utils.LocalStorage {
+	local, _ := utils.FileStorage.(*utils.LocalStorage)
+	return local
+}
+
+// LocalUpload 处理本地存储的签名上传，支持 PUT 请求体或 POST 表单的 file 字段
+func LocalUpload(c *gin.Context) {
+	local := localStorage()
+	if local == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未启用本地存储"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.Verify("upload", key, c.Query("expires"), c.Query("sign")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": err.Error()})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if c.Request.Method == http.MethodPost {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数有误"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	if err := local.Save(key, body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "上传失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "上传成功", "key": key})
+}
+
+// LocalDownload 处理本地存储的签名下载
+func LocalDownload(c *gin.Context) {
+	local := localStorage()
+	if local == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "未启用本地存储"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.Verify("download", key, c.Query("expires"), c.Query("sign")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": err.Error()})
+		return
+	}
+
+	file, err := local.Open(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "文件不存在"})
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "文件不存在"})
+		return
+	}
+	http.ServeContent(c.Writer, c.Request, stat.Name(), stat.ModTime(), file)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func localStorageRouter(t *testing.T) (*gin.Engine, *utils.LocalStorage, string) {
+	t.Helper()
+	dir := t.TempDir()
+	local, err := utils.NewLocalStorage(filepath.Join(dir, "files"), "/storage/local", "sign-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.FileStorage
+	utils.FileStorage = local
+	t.Cleanup(func() { utils.FileStorage = previous })
+
+	r := gin.New()
+	r.GET("/storage/local/*key", LocalDownload)
+	r.PUT("/storage/local/*key", LocalUpload)
+	r.POST("/storage/local/*key", LocalUpload)
+	return r, local, dir
+}
+
+func serveLocal(r *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestLocalStorageSignedUploadAndDownload(t *testing.T) {
+	r, local, _ := localStorageRouter(t)
+
+	credential, err := local.PresignUpload("direct/20210001/a.txt", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serveLocal(r, http.MethodPut, credential.URL, "hello"); w.Code != http.StatusOK {
+		t.Fatalf("签名上传应成功，得到 %d %s", w.Code, w.Body)
+	}
+
+	download := local.PresignDownload("direct/20210001/a.txt", time.Minute)
+	if w := serveLocal(r, http.MethodGet, download, ""); w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("签名下载应返回文件内容，得到 %d %s", w.Code, w.Body)
+	}
+	if w := serveLocal(r, http.MethodGet, credential.URL, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("上传链接不能用于下载，得到 %d", w.Code)
+	}
+	if w := serveLocal(r, http.MethodGet, "/storage/local/direct/20210001/a.txt", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("没有签名的下载应被拒绝，得到 %d", w.Code)
+	}
+
+	// 签名只对原对象名有效，不能换成其他对象
+	u, _ := url.Parse(download)
+	u.Path = "/storage/local/direct/other/a.txt"
+	if w := serveLocal(r, http.MethodGet, u.String(), ""); w.Code != http.StatusForbidden {
+		t.Fatalf("篡改对象名应被拒绝，得到 %d", w.Code)
+	}
+
+	expired := local.PresignDownload("direct/20210001/a.txt", -time.Second)
+	if w := serveLocal(r, http.MethodGet, expired, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("过期的链接应被拒绝，得到 %d", w.Code)
+	}
+}
+
+func TestLocalStorageRejectsTraversalEvenWithValidSignature(t *testing.T) {
+	r, local, dir := localStorageRouter(t)
+	secret := filepath.Join(dir, "secret")
+	os.WriteFile(secret, []byte("secret"), 0o644)
+
+	// 即使签名有效，.. 越界的对象名也不能读取根目录之外的文件
+	download := local.PresignDownload("../secret", time.Minute)
+	if w := serveLocal(r, http.MethodGet, download, ""); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "secret") {
+		t.Fatalf("越界下载应被拒绝，得到 %d %s", w.Code, w.Body)
+	}
+	if _, err := local.PresignUpload("../secret", time.Hour); err == nil {
+		t.Fatal("越界的对象名不应生成上传链接")
+	}
+	if w := serveLocal(r, http.MethodPut, download, "overwritten"); w.Code != http.StatusForbidden {
+		t.Fatalf("下载链接不能用于上传，得到 %d", w.Code)
+	}
+	if data, _ := os.ReadFile(secret); string(data) != "secret" {
+		t.Fatal("根目录之外的文件不应被覆盖")
+	}
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// twoFactorChallenge 签发登录第二步使用的临时令牌，使用单独的密钥签名，不能当作登录令牌使用
+func twoFactorChallenge(user models.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"account":  user.Account,
+		"identity": user.Identity,
+		"purpose":  "login_2fa",
+		"exp":      time.Now().Add(config.TwoFactorChallengeTTL).Unix(),
+	})
+	return token.SignedString([]byte(config.TwoFactorKey))
+}
+
+// parseTwoFactorChallenge 校验临时令牌，返回账号
+func parseTwoFactorChallenge(challenge string) (string, error) {
+	token, err := jwt.Parse(challenge, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("非法的签名方法: %v", token.Header["alg"])
+		}
+		return []byte(config.TwoFactorKey), nil
+	})
+	if err != nil || !token.Valid {
+		return "", &config.ValidationError{Message: "验证已过期，请重新登录"}
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	account, _ := claims["account"].(string)
+	if claims["purpose"] != "login_2fa" || account == "" {
+		return "", &config.ValidationError{Message: "验证已过期，请重新登录"}
+	}
+	return account, nil
+}
+
+// respondTwoFactorError 校验失败返回 400，其它错误返回 500
+func respondTwoFactorError(c *gin.Context, err error, msg string) {
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": msg})
+}
+
+// LoginTwoFactor 登录第二步，提交验证器生成的验证码或恢复码
+func LoginTwoFactor(c *gin.Context) {
+	var req struct {
+		Challenge string `json:"challenge" binding:"required"`
+		Code      string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	account, err := parseTwoFactorChallenge(req.Challenge)
+	if err != nil {
+		respondTwoFactorError(c, err, "验证失败")
+		return
+	}
+	if err := services.VerifyTwoFactor(account, req.Code); err != nil {
+		respondTwoFactorError(c, err, "验证失败")
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("account = ?", account).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 1, "msg": "用户不存在"})
+		return
+	}
+	loginSuccess(c, user, true)
+}
+
+// TwoFactorStatus 查询当前用户的两步验证状态
+func TwoFactorStatus(c *gin.Context) {
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+	enabled, err := services.TwoFactorEnabled(authUser.Account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	remaining, err := services.RemainingRecoveryCodes(authUser.Account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "查询成功", "data": gin.H{
+		"enabled":                  enabled,
+		"required":                 services.TwoFactorRequired(authUser.Permissions),
+		"recovery_codes_remaining": remaining,
+	}})
+}
+
+// SetupTwoFactor 生成 TOTP 密钥，返回密钥和验证器配置地址（otpauth://），前端渲染为二维码供扫描
+func SetupTwoFactor(c *gin.Context) {
+	secret, uri, err := services.SetupTwoFactor(currentAccount(c))
+	if err != nil {
+		respondTwoFactorError(c, err, "生成密钥失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "请使用验证器扫描二维码", "data": gin.H{"secret": secret, "uri": uri}})
+}
+
+// EnableTwoFactor 提交验证器生成的验证码启用两步验证，返回恢复码，恢复码只显示这一次
+func EnableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	codes, err := services.EnableTwoFactor(currentAccount(c), req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err, "启用失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "两步验证已启用，请妥善保存恢复码", "data": gin.H{"recovery_codes": codes}})
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，原有恢复码作废
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	account := currentAccount(c)
+	if err := services.VerifyTwoFactor(account, req.Code); err != nil {
+		respondTwoFactorError(c, err, "验证失败")
+		return
+	}
+	codes, err := services.RegenerateRecoveryCodes(account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成恢复码失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "恢复码已更新，请妥善保存", "data": gin.H{"recovery_codes": codes}})
+}
+
+// DisableTwoFactor 校验密码和验证码后停用两步验证，角色要求两步验证时不能停用
+func DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	user, _ := c.Get("authenticatedUser")
+	authUser := user.(models.AuthenticatedUser)
+	if services.TwoFactorRequired(authUser.Permissions) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "当前角色要求启用两步验证，不能停用"})
+		return
+	}
+
+	var account models.User
+	if err := config.DB.Where("account = ?", authUser.Account).First(&account).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "用户不存在"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "密码错误"})
+		return
+	}
+	if err := services.VerifyTwoFactor(authUser.Account, req.Code); err != nil {
+		respondTwoFactorError(c, err, "验证失败")
+		return
+	}
+	if err := services.DisableTwoFactor(authUser.Account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "停用失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "两步验证已停用"})
+}
+
+// ResetTwoFactor 管理员重置用户的两步验证，用于用户丢失验证器和恢复码的情况
+// 管理员需提交自己的两步验证码，且不能重置权限超出自身的用户；角色要求两步验证的用户下次登录后需要重新启用
+func ResetTwoFactor(c *gin.Context) {
+	var req struct {
+		Account string `json:"account" binding:"required"`
+		Code    string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "参数错误"})
+		return
+	}
+	user, _ := c.Get("authenticatedUser")
+	if err := services.AuthorizeAccountReset(user.(models.AuthenticatedUser), req.Account, req.Code); err != nil {
+		respondTwoFactorError(c, err, "验证失败")
+		return
+	}
+	enabled, err := services.TwoFactorEnabled(req.Account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询失败"})
+		return
+	}
+	services.AuditTarget(c, "user", req.Account, gin.H{"two_factor": enabled}, gin.H{"two_factor": false})
+	if err := services.DisableTwoFactor(req.Account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "重置失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "两步验证已重置"})
+}
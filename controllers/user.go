@@ -3,10 +3,13 @@ package controllers
 import (
 	"competition-server/config"
 	"competition-server/models"
+	"competition-server/services"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/mitchellh/mapstructure"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -41,12 +44,15 @@ func InitUser(c *gin.Context) {
 		}
 		// 5-31 23:00 修改返回信息account为sid/tid
 		userDetails = map[string]interface{}{
-			"sid":     student.SID,
-			"name":    student.Name,
-			"sex":     student.Sex,
-			"grade":   student.Grade,
-			"class":   student.Class,
-			"role_id": student.RoleID,
+			"sid":            student.SID,
+			"name":           student.Name,
+			"sex":            student.Sex,
+			"grade":          student.Grade,
+			"class":          student.Class,
+			"role_id":        student.RoleID,
+			"email":          student.Email,
+			"email_verified": student.EmailVerified,
+			"mail_lang":      student.MailLang,
 		}
 	} else if identity == "teacher" {
 		var teacher models.Teachers
@@ -55,11 +61,14 @@ func InitUser(c *gin.Context) {
 			return
 		}
 		userDetails = map[string]interface{}{
-			"tid":         teacher.TID,
-			"name":        teacher.Name,
-			"rank":        teacher.Rank,
-			"description": teacher.Description,
-			"role_id":     teacher.RoleID,
+			"tid":            teacher.TID,
+			"name":           teacher.Name,
+			"rank":           teacher.Rank,
+			"description":    teacher.Description,
+			"role_id":        teacher.RoleID,
+			"email":          teacher.Email,
+			"email_verified": teacher.EmailVerified,
+			"mail_lang":      teacher.MailLang,
 		}
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "无效的身份类型"})
@@ -70,6 +79,7 @@ func InitUser(c *gin.Context) {
 	userDetails["identity"] = authUser.Identity
 	userDetails["role"] = authUser.Role
 	userDetails["permissions"] = authUser.Permissions
+	userDetails["impersonated_by"] = authUser.ImpersonatedBy
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "获取成功", "data": userDetails})
 }
@@ -77,16 +87,18 @@ func InitUser(c *gin.Context) {
 // ListUsers 用于学生/教师用户查询
 func ListUsers(c *gin.Context) {
 	type QueryParams struct {
-		Type   string `form:"type"`
-		Offset int    `form:"offset"`
-		Limit  int    `form:"limit"`
-		Name   string `form:"name"`
-		Class  string `form:"class"`
-		Rank   *int   `form:"rank"` // 使用指针类型来区分零值和未提供的值
-		SID    string `form:"sid"`
-		Sex    *int   `form:"sex"` // 使用指针类型来区分零值和未提供的值
-		Grade  int    `form:"grade"`
-		TID    string `form:"tid"`
+		Type      string `form:"type"`
+		Offset    int    `form:"offset"`
+		Limit     int    `form:"limit"`
+		Name      string `form:"name"`
+		Class     string `form:"class"`
+		ClassID   int    `form:"class_id"`
+		CollegeID int    `form:"college_id"`
+		Rank      *int   `form:"rank"` // 使用指针类型来区分零值和未提供的值
+		SID       string `form:"sid"`
+		Sex       *int   `form:"sex"` // 使用指针类型来区分零值和未提供的值
+		Grade     int    `form:"grade"`
+		TID       string `form:"tid"`
 	}
 
 	var queryParams QueryParams
@@ -109,6 +121,13 @@ func ListUsers(c *gin.Context) {
 		if queryParams.Class != "" {
 			query = query.Where("class LIKE ?", "%"+queryParams.Class+"%")
 		}
+		if queryParams.ClassID != 0 {
+			query = query.Where("class_id = ?", queryParams.ClassID)
+		}
+		if queryParams.CollegeID != 0 {
+			query = query.Where("class_id IN (?)", config.DB.Table("classes").Select("classes.id").
+				Joins("JOIN majors ON majors.id = classes.major_id").Where("majors.college_id = ?", queryParams.CollegeID))
+		}
 		if queryParams.SID != "" {
 			query = query.Where("sid = ?", queryParams.SID)
 		}
@@ -149,6 +168,9 @@ func ListUsers(c *gin.Context) {
 		if queryParams.TID != "" {
 			query = query.Where("tid = ?", queryParams.TID)
 		}
+		if queryParams.CollegeID != 0 {
+			query = query.Where("college_id = ?", queryParams.CollegeID)
+		}
 
 		limit := queryParams.Limit
 		offset := queryParams.Offset
@@ -174,6 +196,26 @@ func ListUsers(c *gin.Context) {
 	}
 }
 
+// studentUpdate 添加或修改学生时允许填写的字段
+// 邮箱只能由本人通过 UpdateEmail 修改并验证，密码、角色等有单独的接口，不能在这里填写
+type studentUpdate struct {
+	SID     string
+	Name    string
+	Sex     *int
+	Grade   int
+	Class   string
+	ClassID *int `mapstructure:"class_id"`
+}
+
+// teacherUpdate 添加或修改教师时允许填写的字段，限制同 studentUpdate
+type teacherUpdate struct {
+	TID         string
+	Name        string
+	Rank        int
+	Description string
+	CollegeID   *int `mapstructure:"college_id"`
+}
+
 // UpdateUser 更行用户信息
 func UpdateUser(c *gin.Context) {
 	var requestData struct {
@@ -186,24 +228,36 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	if requestData.Type == "student" {
-		var studentData models.Students
-		if err := mapstructure.Decode(requestData.Data, &studentData); err != nil {
+		var input studentUpdate
+		if err := mapstructure.Decode(requestData.Data, &input); err != nil || input.SID == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学生数据解析失败"})
 			return
 		}
+		studentData := models.Students{SID: input.SID, Name: input.Name, Sex: input.Sex, Grade: input.Grade, Class: input.Class, ClassID: input.ClassID}
+		if !resolveStudentClass(c, &studentData) {
+			return
+		}
 
 		if err := config.DB.Model(&models.Students{}).Where("sid = ?", studentData.SID).Updates(studentData).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "学生更新失败"})
 			return
 		}
+		// 改为没有对应班级的名称时取消原来的班级关联
+		if studentData.Class != "" && studentData.ClassID == nil {
+			config.DB.Model(&models.Students{}).Where("sid = ?", studentData.SID).Update("class_id", nil)
+		}
 
 		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "学生信息修改成功"})
 	} else if requestData.Type == "teacher" {
-		var teacherData models.Teachers
-		if err := mapstructure.Decode(requestData.Data, &teacherData); err != nil {
+		var input teacherUpdate
+		if err := mapstructure.Decode(requestData.Data, &input); err != nil || input.TID == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "教师数据解析失败"})
 			return
 		}
+		teacherData := models.Teachers{TID: input.TID, Name: input.Name, Rank: input.Rank, Description: input.Description, CollegeID: input.CollegeID}
+		if !checkTeacherCollege(c, &teacherData) {
+			return
+		}
 
 		if err := config.DB.Model(&models.Teachers{}).Where("tid = ?", teacherData.TID).Updates(teacherData).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "教师更新失败"})
@@ -248,59 +302,37 @@ func UpdatePassword(c *gin.Context) {
 		return
 	}
 
-	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewVal), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "密码加密失败"})
+	if bcrypt.CompareHashAndPassword([]byte(userAccount.Password), []byte(req.NewVal)) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "新密码不能与旧密码相同"})
 		return
 	}
 
-	userAccount.Password = string(hashedNewPassword)
+	if err := services.ValidatePassword(user.Account, req.NewVal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
 
-	if err := config.DB.Save(&userAccount).Error; err != nil {
+	// 同时更新学生或教师表中的密码，并清除必须修改密码的标记
+	if err := services.SetPassword(config.DB, user.Account, req.NewVal, false); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新用户密码失败"})
 		return
 	}
 
-	// 更新学生或教师密码
-	if req.Identity == "student" {
-		var student models.Students
-		if err := config.DB.Where("sid = ?", user.Account).First(&student).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "学生不存在"})
-			return
-		}
-
-		student.Password = string(hashedNewPassword)
-
-		if err := config.DB.Save(&student).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新学生密码失败"})
-			return
-		}
-	} else if req.Identity == "teacher" {
-		var teacher models.Teachers
-		if err := config.DB.Where("tid = ?", user.Account).First(&teacher).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "教师不存在"})
-			return
-		}
-
-		teacher.Password = string(hashedNewPassword)
-
-		if err := config.DB.Save(&teacher).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新教师密码失败"})
-			return
-		}
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "身份不合法"})
+	// 其它会话随修改密码失效，为当前会话重新签发令牌
+	if err := issueToken(c, user.Account, user.Identity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成令牌失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "密码更新成功"})
 }
 
-// ResetPassword 处理密码重置请求
+// ResetPassword 处理密码重置请求，管理员需提交自己的两步验证码，且不能重置权限超出自身的用户
 func ResetPassword(c *gin.Context) {
 	var req struct {
 		Type    string `json:"type"`
 		Account string `json:"account"`
+		Code    string `json:"code"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -308,52 +340,65 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "密码加密失败"})
+	var count int64
+	config.DB.Model(&models.User{}).Where("account = ? AND identity = ?", req.Account, req.Type).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "用户账户不存在"})
 		return
 	}
-
-	if req.Type == "student" {
-		var student models.Students
-		if err := config.DB.Where("sid = ?", req.Account).First(&student).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "学生账户不存在"})
-			return
-		}
-		student.Password = string(hashedPassword)
-		if err := config.DB.Save(&student).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新学生密码失败"})
+	user, _ := c.Get("authenticatedUser")
+	if err := services.AuthorizeAccountReset(user.(models.AuthenticatedUser), req.Account, req.Code); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": validationErr.Message})
 			return
 		}
-	} else if req.Type == "teacher" {
-		var teacher models.Teachers
-		if err := config.DB.Where("tid = ?", req.Account).First(&teacher).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "教师账户不存在"})
-			return
-		}
-		teacher.Password = string(hashedPassword)
-		if err := config.DB.Save(&teacher).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新教师密码失败"})
-			return
-		}
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "身份不合法"})
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "验证失败"})
 		return
 	}
 
-	// 更新 User 表中的密码
-	var user models.User
-	if err := config.DB.Where("account = ?", req.Account).First(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "用户账户不存在"})
+	// 密码字段在审计日志中会被隐藏，只记录发生了重置
+	services.AuditTarget(c, req.Type, req.Account, gin.H{"password": ""}, gin.H{"password": "reset"})
+
+	// 生成随机临时密码，用户下次登录后必须修改密码
+	password, err := services.TempPassword()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成临时密码失败"})
 		return
 	}
-	user.Password = string(hashedPassword)
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := services.SetPassword(config.DB, req.Account, password, true); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "更新用户密码失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "密码重置成功"})
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "密码重置成功", "data": gin.H{"password": password}})
+}
+
+// resolveStudentClass 指定 class_id 时校验班级并同步班级名称，只填写班级名称时按名称关联已有班级
+func resolveStudentClass(c *gin.Context, student *models.Students) bool {
+	if student.ClassID == nil {
+		student.ClassID = services.ClassIDByName(student.Class)
+		return true
+	}
+	class, err := services.LookupClass(*student.ClassID)
+	if err != nil {
+		respondServiceError(c, err, "查询班级失败")
+		return false
+	}
+	student.Class = class.Name
+	return true
+}
+
+// checkTeacherCollege 指定 college_id 时校验学院存在
+func checkTeacherCollege(c *gin.Context, teacher *models.Teachers) bool {
+	if teacher.CollegeID == nil {
+		return true
+	}
+	if err := services.CheckCollege(*teacher.CollegeID); err != nil {
+		respondServiceError(c, err, "查询学院失败")
+		return false
+	}
+	return true
 }
 
 // AddUsers 添加用户
@@ -367,22 +412,26 @@ func AddUsers(c *gin.Context) {
 		return
 	}
 
-	// 处理初始密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.DefaultCost)
+	// 处理初始密码，用户首次登录后必须修改
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(config.InitialPassword), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "密码加密失败"})
 		return
 	}
 
 	if requestData.Type == "student" {
-		var studentData models.Students
-		if err := mapstructure.Decode(requestData.Data, &studentData); err != nil {
+		var input studentUpdate
+		if err := mapstructure.Decode(requestData.Data, &input); err != nil || input.SID == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "学生数据解析失败"})
 			return
 		}
+		studentData := models.Students{SID: input.SID, Name: input.Name, Sex: input.Sex, Grade: input.Grade, Class: input.Class, ClassID: input.ClassID}
 
 		studentData.Password = string(hashedPassword)
 		studentData.RoleID = 3 // 设置学生的默认 role_id 为 3
+		if !resolveStudentClass(c, &studentData) {
+			return
+		}
 
 		// 添加学生到数据库
 		if err := config.DB.Create(&studentData).Error; err != nil {
@@ -392,10 +441,11 @@ func AddUsers(c *gin.Context) {
 
 		// 添加用户到User表
 		user := models.User{
-			Account:  studentData.SID,
-			Password: studentData.Password,
-			Identity: "student",
-			RoleID:   3, // 对应的用户表角色ID
+			Account:            studentData.SID,
+			Password:           studentData.Password,
+			Identity:           "student",
+			RoleID:             3, // 对应的用户表角色ID
+			MustChangePassword: true,
 		}
 		if err := config.DB.Create(&user).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "用户创建失败"})
@@ -404,14 +454,18 @@ func AddUsers(c *gin.Context) {
 
 		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "学生创建成功"})
 	} else if requestData.Type == "teacher" {
-		var teacherData models.Teachers
-		if err := mapstructure.Decode(requestData.Data, &teacherData); err != nil {
+		var input teacherUpdate
+		if err := mapstructure.Decode(requestData.Data, &input); err != nil || input.TID == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "教师数据解析失败"})
 			return
 		}
+		teacherData := models.Teachers{TID: input.TID, Name: input.Name, Rank: input.Rank, Description: input.Description, CollegeID: input.CollegeID}
 
 		teacherData.Password = string(hashedPassword)
 		teacherData.RoleID = 4 // 设置教师的默认 role_id 为 4
+		if !checkTeacherCollege(c, &teacherData) {
+			return
+		}
 
 		// 添加教师到数据库
 		if err := config.DB.Create(&teacherData).Error; err != nil {
@@ -421,10 +475,11 @@ func AddUsers(c *gin.Context) {
 
 		// 添加用户到User表
 		user := models.User{
-			Account:  teacherData.TID,
-			Password: teacherData.Password,
-			Identity: "teacher",
-			RoleID:   4, // 对应的用户表角色ID
+			Account:            teacherData.TID,
+			Password:           teacherData.Password,
+			Identity:           "teacher",
+			RoleID:             4, // 对应的用户表角色ID
+			MustChangePassword: true,
 		}
 		if err := config.DB.Create(&user).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "用户创建失败"})
@@ -449,7 +504,8 @@ func AddImport(c *gin.Context) {
 		return
 	}
 
-	initialPassword, _ := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.DefaultCost)
+	// 初始密码，用户首次登录后必须修改
+	initialPassword, _ := bcrypt.GenerateFromPassword([]byte(config.InitialPassword), bcrypt.DefaultCost)
 
 	switch requestData.Type {
 	case "student":
@@ -467,6 +523,7 @@ func AddImport(c *gin.Context) {
 				Sex:        &sex,
 				Grade:      grade,
 				Class:      class,
+				ClassID:    services.ClassIDByName(class),
 				RoleID:     3,
 				CreateTime: time.Now(),
 				UpdateTime: time.Now(),
@@ -474,10 +531,11 @@ func AddImport(c *gin.Context) {
 			config.DB.Create(&newStudent)
 
 			newUser := models.User{
-				Account:  sid,
-				Password: string(initialPassword),
-				Identity: "student",
-				RoleID:   3,
+				Account:            sid,
+				Password:           string(initialPassword),
+				Identity:           "student",
+				RoleID:             3,
+				MustChangePassword: true,
 			}
 			config.DB.Create(&newUser)
 		}
@@ -501,10 +559,11 @@ func AddImport(c *gin.Context) {
 			config.DB.Create(&newTeacher)
 
 			newUser := models.User{
-				Account:  tid,
-				Password: string(initialPassword),
-				Identity: "teacher",
-				RoleID:   4,
+				Account:            tid,
+				Password:           string(initialPassword),
+				Identity:           "teacher",
+				RoleID:             4,
+				MustChangePassword: true,
 			}
 			config.DB.Create(&newUser)
 		}
@@ -516,7 +575,7 @@ func AddImport(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "导入成功"})
 }
 
-// DeleteUsers 批量删除学生/教师并且清除账户
+// DeleteUsers 批量删除学生/教师及其账户，删除的数据移入回收站
 func DeleteUsers(c *gin.Context) {
 	var requestData struct {
 		Type string `json:"type"`
@@ -532,18 +591,20 @@ func DeleteUsers(c *gin.Context) {
 
 	switch requestData.Type {
 	case "student":
-		for _, sid := range requestData.Data.IDs {
-			// 删除 users 表中的记录
-			config.DB.Where("account = ?", sid).Delete(&models.User{})
-			// 删除 students 表中的记录
-			config.DB.Where("sid = ?", sid).Delete(&models.Students{})
+		var before []models.Students
+		config.DB.Where("sid IN ?", requestData.Data.IDs).Find(&before)
+		services.AuditTarget(c, "student", strings.Join(requestData.Data.IDs, ","), before, nil)
+		if err := services.DeleteStudents(requestData.Data.IDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+			return
 		}
 	case "teacher":
-		for _, tid := range requestData.Data.IDs {
-			// 删除 users 表中的记录
-			config.DB.Where("account = ?", tid).Delete(&models.User{})
-			// 删除 teachers 表中的记录
-			config.DB.Where("tid = ?", tid).Delete(&models.Teachers{})
+		var before []models.Teachers
+		config.DB.Where("tid IN ?", requestData.Data.IDs).Find(&before)
+		services.AuditTarget(c, "teacher", strings.Join(requestData.Data.IDs, ","), before, nil)
+		if err := services.DeleteTeachers(requestData.Data.IDs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "删除失败"})
+			return
 		}
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "未知的类型"})
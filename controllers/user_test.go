@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/middlewares"
+	"competition-server/models"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// userRouter 以指定用户身份访问用户管理接口，经过权限检查
+func userRouter(user models.AuthenticatedUser) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("authenticatedUser", user) }, middlewares.AuthCheckMiddleware())
+	r.PUT("/user/update", UpdateUser)
+	return r
+}
+
+func putJSON(r *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	raw, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, path, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateUserCannotChangeEmail(t *testing.T) {
+	testutil.OpenDB(t)
+	sex := 1
+	config.DB.Create(&models.Students{SID: "admin", Name: "管理员", Password: "x", Sex: &sex})
+	body := gin.H{"type": "student", "data": gin.H{
+		"sid": "admin", "name": "新名字", "email": "me@evil.example", "emailverified": true, "email_verified": true, "maillang": "en", "password": "x", "roleid": 1,
+	}}
+
+	// 普通用户没有 user:update 权限
+	student := models.AuthenticatedUser{Account: "20210001", Identity: "student", Permissions: []string{"race:query"}}
+	if w := putJSON(userRouter(student), "/user/update", body); w.Code != http.StatusUnauthorized {
+		t.Fatalf("没有 user:update 权限应被拒绝，得到 %d", w.Code)
+	}
+
+	// 有权限的管理员也只能修改白名单内的字段
+	operator := models.AuthenticatedUser{Account: "root", Identity: "teacher", Permissions: []string{"user:update"}}
+	if w := putJSON(userRouter(operator), "/user/update", body); w.Code != http.StatusOK {
+		t.Fatalf("修改应成功，得到 %d %s", w.Code, w.Body)
+	}
+	var saved models.Students
+	config.DB.Where("sid = ?", "admin").First(&saved)
+	if saved.Name != "新名字" {
+		t.Fatalf("应修改姓名，得到 %q", saved.Name)
+	}
+	if saved.Email != "" || saved.EmailVerified || saved.MailLang != "" || saved.Password != "x" || saved.RoleID != 0 {
+		t.Fatalf("不应修改邮箱、验证状态、密码或角色: %+v", saved)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"competition-server/config"
+	"competition-server/middlewares"
 	"competition-server/routes"
+	"competition-server/services"
+	"competition-server/utils"
 	"errors"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
@@ -19,6 +22,25 @@ func main() {
 	// 初始化数据库
 	config.InitDB()
 
+	// 初始化文件存储
+	if err := utils.InitStorage(); err != nil {
+		log.Fatal().Err(err).Msg("Storage init failed")
+	}
+	if err := utils.InitMailer(); err != nil {
+		log.Fatal().Err(err).Msg("Mailer init failed")
+	}
+	utils.InitOIDC()
+	if err := utils.InitFont(); err != nil {
+		log.Fatal().Err(err).Msg("Font init failed")
+	}
+
+	// 后台任务
+	services.StartFileGC()
+	services.StartRecyclePurge()
+	services.StartDeadlineReminder()
+	services.StartMailQueue()
+	services.StartSessionPurge()
+
 	// 创建Gin路由
 	r := gin.Default()
 
@@ -31,16 +53,28 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:8080"}, // 前端服务器地址
 		AllowMethods:     []string{"GET", "PUT", "POST", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", config.CSRFHeaderName},
+		ExposeHeaders:    []string{"X-Impersonated-By", "X-Impersonating"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// 设置会话
 	store := cookie.NewStore([]byte(config.CookieKey))
+	store.Options(sessions.Options{
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		MaxAge:   86400 * 30,
+		Secure:   config.CookieSecure,
+		HttpOnly: true,
+		SameSite: config.CookieSameSite,
+	})
 	r.Use(sessions.Sessions("mysession", store))
 
+	// 安全响应头及 CSRF 校验
+	r.Use(middlewares.SecurityHeadersMiddleware())
+	r.Use(middlewares.CSRFMiddleware())
+
 	// 请求速率限制
 	r.Use(rateLimitMiddleware(5, time.Second))
 
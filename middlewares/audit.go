@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"competition-server/services"
+	"github.com/gin-gonic/gin"
+)
+
+// auditBodyLimit 审计日志中保留的请求体最大长度，超出部分不记录
+const auditBodyLimit = 64 << 10
+
+// AuditMiddleware 记录所有修改类请求（POST/PUT/PATCH/DELETE）的操作人、路由、操作对象和结果
+// 需放在登录检查之后、权限检查之前，这样被拒绝的越权操作也会留下记录
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 处理函数通过 services.Audit 登记的日志在响应结束后写入，以记录最终的状态码
+		services.BeginAudit(c)
+		defer services.FinishAudit(c)
+
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		// 只保留 JSON 请求体，文件上传等内容不记录
+		// 最多预读 auditBodyLimit+1 字节，已读部分与剩余内容拼接后交给处理函数，避免大请求体占满内存
+		var body []byte
+		if strings.HasPrefix(c.ContentType(), "application/json") && c.Request.Body != nil {
+			data, err := io.ReadAll(io.LimitReader(c.Request.Body, auditBodyLimit+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(data), c.Request.Body), c.Request.Body}
+			if err == nil && len(data) <= auditBodyLimit {
+				body = data
+			}
+		}
+
+		c.Next()
+		services.AuditRequest(c, body)
+	}
+}
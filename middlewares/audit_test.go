@@ -0,0 +1,117 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func auditRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("authenticatedUser", models.AuthenticatedUser{Account: "admin", Identity: "teacher"})
+	})
+	r.Use(AuditMiddleware())
+	// 显式登记审计日志后才决定响应状态
+	r.POST("/file/delete", func(c *gin.Context) {
+		services.Audit(c, "file:delete", "file", "a.pdf", gin.H{"keys": []string{"a.pdf"}}, nil)
+		c.JSON(http.StatusForbidden, gin.H{"code": 403})
+	})
+	r.GET("/file/gc", func(c *gin.Context) {
+		services.Audit(c, "file:gc", "file", "", nil, errors.New("failed"))
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500})
+	})
+	r.POST("/user/update", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"code": 200})
+	})
+	return r
+}
+
+func auditLogs(t *testing.T) []models.AuditLogs {
+	t.Helper()
+	var logs []models.AuditLogs
+	if err := config.DB.Order("id ASC").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+func TestAuditRecordsFinalStatus(t *testing.T) {
+	testutil.OpenDB(t)
+	r := auditRouter()
+
+	serve(r, httptest.NewRequest(http.MethodPost, "/file/delete", strings.NewReader(`{}`)))
+	logs := auditLogs(t)
+	if len(logs) != 1 {
+		t.Fatalf("每个请求只应记录一条审计日志，得到 %d", len(logs))
+	}
+	if logs[0].Action != "file:delete" || logs[0].Status != http.StatusForbidden || logs[0].Outcome != "failure" {
+		t.Fatalf("审计日志应记录最终的响应状态: %+v", logs[0])
+	}
+
+	serve(r, httptest.NewRequest(http.MethodGet, "/file/gc", nil))
+	logs = auditLogs(t)
+	if len(logs) != 2 || logs[1].Status != http.StatusInternalServerError || logs[1].Outcome != "failure" {
+		t.Fatalf("查询类请求显式登记的日志也应记录最终状态: %+v", logs)
+	}
+}
+
+func TestAuditRedactsSensitiveFields(t *testing.T) {
+	testutil.OpenDB(t)
+	r := auditRouter()
+
+	body := `{"account":"20210001","password":"s-1","oldVal":"s-2","newVal":"s-3","code":"s-4",
+		"recovery_codes":["s-5","s-6"],"answer":"s-7","key":"s-8","api_key":"s-9","client_secret":"s-10",
+		"nested":{"refreshToken":"s-11","name":"张三"}}`
+	req := httptest.NewRequest(http.MethodPost, "/user/update", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	serve(r, req)
+
+	logs := auditLogs(t)
+	if len(logs) != 1 {
+		t.Fatalf("应记录一条审计日志，得到 %d", len(logs))
+	}
+	detail := logs[0].Detail
+	for i := 1; i <= 11; i++ {
+		if secret := fmt.Sprintf(`"s-%d"`, i); strings.Contains(detail, secret) {
+			t.Errorf("审计日志不应包含敏感值 %s: %s", secret, detail)
+		}
+	}
+	if !strings.Contains(detail, "20210001") || !strings.Contains(detail, "张三") {
+		t.Fatalf("普通字段应保留: %s", detail)
+	}
+}
+
+func TestAuditPassesLargeBodyThrough(t *testing.T) {
+	testutil.OpenDB(t)
+	r := auditRouter()
+	var received string
+	r.POST("/race/import", func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		received = string(data)
+		c.JSON(http.StatusOK, gin.H{"code": 200})
+	})
+
+	body := `{"note":"` + strings.Repeat("a", auditBodyLimit) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/race/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	serve(r, req)
+
+	if received != body {
+		t.Fatalf("处理函数应收到完整的请求体，得到 %d 字节", len(received))
+	}
+	logs := auditLogs(t)
+	if len(logs) != 1 || strings.Contains(logs[0].Detail, "aaaa") {
+		t.Fatalf("超出长度的请求体不应写入审计日志: %+v", logs)
+	}
+}
@@ -32,26 +32,66 @@ func CheckPermission(permission string) gin.HandlerFunc {
 }
 
 var Strategy = map[string]gin.HandlerFunc{
-	"/user/add":          CheckPermission("user:add"),
-	"/user/delete":       CheckPermission("user:delete"),
-	"/user/reset":        CheckPermission("user:update"),
-	"/user/list":         CheckPermission("user:query"),
-	"/race/add":          CheckPermission("race:add"),
-	"/race/delete":       CheckPermission("race:delete"),
-	"/race/list":         CheckPermission("race:query"),
-	"/race/update":       CheckPermission("race:update"),
-	"/record/add":        CheckPermission("record:add"),
-	"/record/delete":     CheckPermission("record:delete"),
-	"/record/list":       CheckPermission("record:query"),
-	"/permission/list":   CheckPermission("permission:query"),
-	"/permission/add":    CheckPermission("permission:add"),
-	"/permission/delete": CheckPermission("permission:delete"),
-	"/permission/update": CheckPermission("permission:update"),
-	"/role/list":         CheckPermission("role:query"),
-	"/role/add":          CheckPermission("role:add"),
-	"/role/delete":       CheckPermission("role:delete"),
-	"/role/update":       CheckPermission("role:update"),
-	"/role/grant":        CheckPermission("role:update"),
+	"/user/add":             CheckPermission("user:add"),
+	"/user/delete":          CheckPermission("user:delete"),
+	"/user/restore":         CheckPermission("user:delete"),
+	"/user/reset":           CheckPermission("user:update"),
+	"/user/2fa/reset":       CheckPermission("user:update"),
+	"/user/sessions":        CheckPermission("user:update"),
+	"/user/impersonate":     CheckPermission("user:impersonate"),
+	"/user/list":            CheckPermission("user:query"),
+	"/user/update":          CheckPermission("user:update"),
+	"/race/add":             CheckPermission("race:add"),
+	"/race/delete":          CheckPermission("race:delete"),
+	"/race/restore":         CheckPermission("race:delete"),
+	"/race/list":            CheckPermission("race:query"),
+	"/race/update":          CheckPermission("race:update"),
+	"/record/add":           CheckPermission("record:add"),
+	"/record/delete":        CheckPermission("record:delete"),
+	"/record/restore":       CheckPermission("record:delete"),
+	"/record/list":          CheckPermission("record:query"),
+	"/record/status":        CheckPermission("record:update"),
+	"/record/revert":        CheckPermission("record:update"),
+	"/permission/list":      CheckPermission("permission:query"),
+	"/permission/add":       CheckPermission("permission:add"),
+	"/permission/delete":    CheckPermission("permission:delete"),
+	"/permission/update":    CheckPermission("permission:update"),
+	"/role/list":            CheckPermission("role:query"),
+	"/role/add":             CheckPermission("role:add"),
+	"/role/delete":          CheckPermission("role:delete"),
+	"/role/update":          CheckPermission("role:update"),
+	"/role/grant":           CheckPermission("role:update"),
+	"/file/gc":              CheckPermission("file:delete"),
+	"/certificate/generate": CheckPermission("record:update"),
+	"/certificate/template": CheckPermission("record:update"),
+	"/stats":                CheckPermission("stats:query"),
+	"/credits/report":       CheckPermission("credit:query"),
+	"/credits/rules/list":   CheckPermission("credit:query"),
+	"/credits/rules/add":    CheckPermission("credit:add"),
+	"/credits/rules/delete": CheckPermission("credit:delete"),
+	"/audit":                CheckPermission("audit:query"),
+	"/org/tree":             CheckPermission("org:query"),
+	"/org/college/list":     CheckPermission("org:query"),
+	"/org/college/add":      CheckPermission("org:add"),
+	"/org/college/update":   CheckPermission("org:update"),
+	"/org/college/delete":   CheckPermission("org:delete"),
+	"/org/major/list":       CheckPermission("org:query"),
+	"/org/major/add":        CheckPermission("org:add"),
+	"/org/major/update":     CheckPermission("org:update"),
+	"/org/major/delete":     CheckPermission("org:delete"),
+	"/org/class/list":       CheckPermission("org:query"),
+	"/org/class/add":        CheckPermission("org:add"),
+	"/org/class/update":     CheckPermission("org:update"),
+	"/org/class/delete":     CheckPermission("org:delete"),
+	"/org/migrate":          CheckPermission("org:import"),
+	"/service/list":         CheckPermission("service:query"),
+	"/service/add":          CheckPermission("service:add"),
+	"/service/update":       CheckPermission("service:update"),
+	"/service/delete":       CheckPermission("service:delete"),
+	"/service/key/list":     CheckPermission("service:query"),
+	"/service/key/create":   CheckPermission("service:update"),
+	"/service/key/revoke":   CheckPermission("service:update"),
+	"/service/key/usage":    CheckPermission("service:query"),
 }
 
 func AuthCheckMiddleware() gin.HandlerFunc {
@@ -1,12 +1,15 @@
 package middlewares
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"competition-server/config"
 	"competition-server/models"
+	"competition-server/services"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -14,9 +17,56 @@ import (
 // TokenKey 是用于签名和验证 JWT 令牌的密钥
 var TokenKey = "token-ncu_university-competition_server"
 
+// mustChangePasswordAllowed 必须修改密码的会话可以访问的接口
+var mustChangePasswordAllowed = map[string]bool{
+	"PATCH /user/password": true,
+	"POST /auth/logout":    true,
+}
+
+// twoFactorSetupAllowed 角色要求两步验证但尚未启用的会话可以访问的接口
+var twoFactorSetupAllowed = map[string]bool{
+	"GET /auth/2fa/status":  true,
+	"POST /auth/2fa/setup":  true,
+	"POST /auth/2fa/enable": true,
+	"PATCH /user/password":  true,
+	"POST /auth/logout":     true,
+}
+
+// readOnlyMethods 只读的请求方法，模拟登录期间允许访问，也不做 CSRF 校验
+var readOnlyMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// impersonationAllowed 模拟登录期间允许访问的修改类接口
+var impersonationAllowed = map[string]bool{
+	"POST /auth/impersonate/stop": true,
+	"POST /auth/logout":           true,
+}
+
 // LoginCheckMiddleware 是一个中间件函数，用于检查用户的登录状态和权限
 func LoginCheckMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 集成脚本使用服务账号的 API 密钥：Authorization: Bearer <key>
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			serviceUser, err := services.AuthenticateAPIKey(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), c.ClientIP())
+			var validationErr *config.ValidationError
+			if errors.As(err, &validationErr) {
+				c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": validationErr.Message})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "校验 API 密钥失败"})
+				c.Abort()
+				return
+			}
+			c.Set("authenticatedUser", *serviceUser)
+			c.Next()
+			return
+		}
+
 		// 从 Cookie 中获取令牌
 		token, err := c.Cookie("uid")
 		if err != nil {
@@ -41,6 +91,39 @@ func LoginCheckMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 令牌对应的服务端会话必须有效，会话被注销或管理员强制下线后令牌立即失效
+		sid, _ := payload["sid"].(string)
+		account, _ := payload["account"].(string)
+		if sid == "" {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "请重新登录"})
+			c.Abort()
+			return
+		}
+		session, err := services.ValidateSession(sid, account, c.ClientIP())
+		if err != nil {
+			var validationErr *config.ValidationError
+			if errors.As(err, &validationErr) {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": validationErr.Message})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询会话失败"})
+			}
+			c.Abort()
+			return
+		}
+		c.Set("sessionID", sid)
+
+		// 管理员模拟登录：每个响应都带上模拟标记，默认只允许查询
+		impersonating := session.ImpersonatedBy != ""
+		if impersonating {
+			c.Header("X-Impersonated-By", session.ImpersonatedBy)
+			c.Header("X-Impersonating", session.Account)
+			if config.ImpersonationReadOnly && !readOnlyMethods[c.Request.Method] && !impersonationAllowed[c.Request.Method+" "+c.FullPath()] {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "模拟登录期间不允许修改数据"})
+				c.Abort()
+				return
+			}
+		}
+
 		// 从数据库中获取用户信息
 		var user models.User
 		if err := config.DB.Where("account = ?", payload["account"]).First(&user).Error; err != nil {
@@ -49,6 +132,23 @@ func LoginCheckMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 修改密码之前签发的令牌失效
+		if user.PasswordChangedAt != nil {
+			iat, _ := payload["iat"].(float64)
+			if int64(iat) < user.PasswordChangedAt.Unix() {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "密码已修改，请重新登录"})
+				c.Abort()
+				return
+			}
+		}
+
+		// 初始密码或管理员重置的临时密码登录后，只允许访问修改密码接口；模拟登录不受限制
+		if !impersonating && user.MustChangePassword && !mustChangePasswordAllowed[c.Request.Method+" "+c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"code": 4, "msg": "请先修改密码"})
+			c.Abort()
+			return
+		}
+
 		//// 测试代码：返回查询出的用户信息 -- 获取成功
 		//c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "用户查询成功", "data": user})
 		//c.Abort()
@@ -105,12 +205,28 @@ func LoginCheckMiddleware() gin.HandlerFunc {
 		//})
 		//return
 
+		// 拥有敏感权限的角色必须启用两步验证，启用前只允许访问启用两步验证的接口
+		if !impersonating && services.TwoFactorRequired(userPermissions) && !twoFactorSetupAllowed[c.Request.Method+" "+c.FullPath()] {
+			enabled, err := services.TwoFactorEnabled(user.Account)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "查询两步验证失败"})
+				c.Abort()
+				return
+			}
+			if !enabled {
+				c.JSON(http.StatusForbidden, gin.H{"code": 6, "msg": "请先启用两步验证"})
+				c.Abort()
+				return
+			}
+		}
+
 		// 将用户信息和权限添加到 Gin 的上下文中
 		c.Set("authenticatedUser", models.AuthenticatedUser{
-			Account:     user.Account,
-			Identity:    user.Identity,
-			Role:        role,
-			Permissions: userPermissions,
+			Account:        user.Account,
+			Identity:       user.Identity,
+			Role:           role,
+			Permissions:    userPermissions,
+			ImpersonatedBy: session.ImpersonatedBy,
 		})
 		c.Next()
 	}
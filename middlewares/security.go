@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"competition-server/config"
+	"competition-server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityHeadersMiddleware 为所有响应添加 CSP、HSTS、X-Frame-Options 等安全响应头
+func SecurityHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		if config.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+		if config.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(config.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		if config.FrameOptions != "" {
+			header.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		c.Next()
+	}
+}
+
+// CSRFMiddleware 双重提交 CSRF 校验：没有 CSRF Cookie 时签发一个，修改类请求的请求头必须与 Cookie 一致
+// 使用 API 密钥的请求和 config.CSRFExemptPaths 中的路径不校验
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+			c.Next()
+			return
+		}
+		for _, prefix := range config.CSRFExemptPaths {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		token, err := c.Cookie(config.CSRFCookieName)
+		if err != nil || token == "" {
+			if token, err = utils.IssueCSRFToken(c); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "生成 CSRF 令牌失败"})
+				c.Abort()
+				return
+			}
+		}
+
+		if !readOnlyMethods[c.Request.Method] {
+			header := c.GetHeader(config.CSRFHeaderName)
+			if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "CSRF 校验失败，请刷新页面后重试"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
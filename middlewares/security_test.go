@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/services"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// csrfRouter 按 main.go 的顺序在登录检查之前挂载安全响应头和 CSRF 校验
+func csrfRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(SecurityHeadersMiddleware(), CSRFMiddleware())
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"code": 200}) }
+	r.GET("/ping", ok)
+	r.POST("/auth/login", ok)
+	r.PUT("/storage/local/*key", ok)
+	api := r.Group("", LoginCheckMiddleware(), AuthCheckMiddleware())
+	api.POST("/race/add", ok)
+	return r
+}
+
+func csrfRequest(method, path, cookie, header string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: config.CSRFCookieName, Value: cookie})
+	}
+	if header != "" {
+		req.Header.Set(config.CSRFHeaderName, header)
+	}
+	return req
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	r := csrfRouter()
+
+	// 首次访问签发 CSRF Cookie，前端脚本可以读取
+	w := serve(r, csrfRequest(http.MethodGet, "/ping", "", ""))
+	var token string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == config.CSRFCookieName {
+			token = cookie.Value
+			if cookie.HttpOnly {
+				t.Fatal("CSRF Cookie 不能设置 HttpOnly")
+			}
+		}
+	}
+	if w.Code != http.StatusOK || token == "" {
+		t.Fatalf("查询请求应通过并签发 CSRF Cookie，得到 %d", w.Code)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatal("应添加安全响应头")
+	}
+
+	cases := []struct {
+		name           string
+		cookie, header string
+		want           int
+	}{
+		{"缺少请求头", token, "", http.StatusForbidden},
+		{"请求头与 Cookie 不一致", token, "forged", http.StatusForbidden},
+		{"缺少 Cookie", "", token, http.StatusForbidden},
+		{"请求头与 Cookie 一致", token, token, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if w := serve(r, csrfRequest(http.MethodPost, "/auth/login", tc.cookie, tc.header)); w.Code != tc.want {
+			t.Fatalf("%s: 期望 %d，得到 %d", tc.name, tc.want, w.Code)
+		}
+	}
+
+	// 本地存储凭签名鉴权，不做 CSRF 校验
+	if w := serve(r, csrfRequest(http.MethodPut, "/storage/local/a.pdf", "", "")); w.Code != http.StatusOK {
+		t.Fatalf("免校验路径应通过，得到 %d", w.Code)
+	}
+}
+
+func TestCSRFSkippedOnlyForAPIKeys(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 10, "race:add")
+	if err := config.DB.Create(&models.ServiceAccounts{Account: "sync", RoleID: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+	operator := models.AuthenticatedUser{Account: "root", Permissions: []string{"race:add"}}
+	key, _, err := services.CreateAPIKey("sync", "k", []string{"race:add"}, nil, operator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := csrfRouter()
+
+	req := csrfRequest(http.MethodPost, "/race/add", "", "")
+	req.Header.Set("Authorization", "Bearer "+key)
+	if w := serve(r, req); w.Code != http.StatusOK {
+		t.Fatalf("使用 API 密钥的请求不需要 CSRF 令牌，得到 %d %s", w.Code, w.Body)
+	}
+
+	// 伪造的 Authorization 头跳过了 CSRF 校验，但登录检查只认 API 密钥，不会使用浏览器带上的登录 Cookie
+	req = csrfRequest(http.MethodPost, "/race/add", "", "")
+	req.Header.Set("Authorization", "Bearer forged")
+	req.AddCookie(&http.Cookie{Name: "uid", Value: "session"})
+	if w := serve(r, req); w.Code != http.StatusUnauthorized {
+		t.Fatalf("伪造的 API 密钥应被拒绝，得到 %d", w.Code)
+	}
+}
@@ -9,10 +9,11 @@ type RoleDTO struct {
 }
 
 type AuthenticatedUser struct {
-	Account     string   // 账号
-	Identity    string   // 身份
-	Role        Roles    // 角色
-	Permissions []string // 权限
+	Account        string   // 账号
+	Identity       string   // 身份
+	Role           Roles    // 角色
+	Permissions    []string // 权限
+	ImpersonatedBy string   // 管理员模拟登录时为管理员账号，本人登录时为空
 }
 
 type UserData struct {
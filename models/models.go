@@ -18,8 +18,8 @@ type Roles struct {
 type Permissions struct {
 	ID     int    `gorm:"primaryKey" json:"id"`
 	Label  string `gorm:"size:255;unique" json:"label"`
-	Action string `gorm:"type:enum('add','delete','update','query','import','export')" json:"action"`
-	Type   string `gorm:"type:enum('user','role','race','record','permission')" json:"type"`
+	Action string `gorm:"type:enum('add','delete','update','query','import','export','impersonate')" json:"action"`
+	Type   string `gorm:"type:enum('user','role','race','record','permission','file','stats','credit','audit','service','org')" json:"type"`
 }
 
 // Rolepermission 定义角色与权限对应关系的结构体
@@ -31,13 +31,15 @@ type Rolepermission struct {
 }
 
 type User struct {
-	Account   string         `gorm:"column:account;primaryKey;type:varchar(255);not null" json:"account"`
-	Password  string         `gorm:"type:varchar(255);not null" json:"password"`
-	Identity  string         `gorm:"type:varchar(255);not null;check:identity IN ('student', 'teacher')" json:"identity"`
-	RoleID    int            `gorm:"index" json:"role_id"`
-	CreatedAt time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedat"`
+	Account            string         `gorm:"column:account;primaryKey;type:varchar(255);not null" json:"account"`
+	Password           string         `gorm:"type:varchar(255);not null" json:"password"`
+	Identity           string         `gorm:"type:varchar(255);not null;check:identity IN ('student', 'teacher')" json:"identity"`
+	RoleID             int            `gorm:"index" json:"role_id"`
+	MustChangePassword bool           `gorm:"column:must_change_password;default:false" json:"must_change_password"` // 管理员重置密码后需要修改密码
+	PasswordChangedAt  *time.Time     `gorm:"column:password_changed_at" json:"password_changed_at"`                 // 此前签发的登录令牌全部失效
+	CreatedAt          time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt          time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deletedat"`
 }
 
 // Races 改动新添加开始/截止日期
@@ -51,9 +53,13 @@ type Races struct {
 	Startdate   time.Time `json:"startdate" json:"startdate"`
 	Enddate     time.Time `json:"enddate" json:"enddate"`
 	Description string    `gorm:"size:255" json:"description"`
-	Records     []Records `gorm:"foreignKey:RaceID;references:RaceID" json:"records"`
-	CreateTime  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
-	UpdateTime  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+	// RequireEvidence 为 true 时参赛记录必须上传证明材料才能审核通过
+	RequireEvidence  bool           `gorm:"column:require_evidence;default:false" json:"require_evidence"`
+	DeadlineReminded bool           `gorm:"column:deadline_reminded;default:false" json:"deadline_reminded"` // 是否已发送截止提醒
+	Records          []Records      `gorm:"foreignKey:RaceID;references:RaceID" json:"records"`
+	CreateTime       time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	UpdateTime       time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 //type Races struct {
@@ -71,45 +77,382 @@ type Races struct {
 //}
 
 type Students struct {
-	SID        string    `gorm:"column:sid;primaryKey" json:"sid"`
-	Name       string    `gorm:"size:255;not null" json:"name"`
-	Password   string    `gorm:"size:255;not null" json:"password"`
-	Sex        *int      `gorm:"not null" json:"sex"` // 因为0代表女生故设为指针类型
-	Grade      int       `gorm:"not null" json:"grade"`
-	Class      string    `gorm:"size:255;not null" json:"class"`
-	RoleID     int       `gorm:"index" json:"role_id"`
-	Records    []Records `gorm:"foreignKey:SID;references:SID" json:"records"`
+	SID           string         `gorm:"column:sid;primaryKey" json:"sid"`
+	Name          string         `gorm:"size:255;not null" json:"name"`
+	Password      string         `gorm:"size:255;not null" json:"password"`
+	Sex           *int           `gorm:"not null" json:"sex"` // 因为0代表女生故设为指针类型
+	Grade         int            `gorm:"not null" json:"grade"`
+	Class         string         `gorm:"size:255;not null" json:"class"`
+	ClassID       *int           `gorm:"column:class_id;index" json:"class_id" mapstructure:"class_id"` // 所属班级，Class 为班级名称
+	RoleID        int            `gorm:"index" json:"role_id"`
+	Email         string         `gorm:"size:255" json:"email"` // 验证后才会接收邮件通知
+	EmailVerified bool           `gorm:"column:email_verified" json:"email_verified"`
+	MailLang      string         `gorm:"column:mail_lang;size:8" json:"mail_lang"` // 邮件语言 zh/en
+	Records       []Records      `gorm:"foreignKey:SID;references:SID" json:"records"`
+	CreateTime    time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	UpdateTime    time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+type Teachers struct {
+	TID           string         `gorm:"column:tid;primaryKey" json:"tid"`
+	Name          string         `gorm:"size:255;not null" json:"name"`
+	Password      string         `gorm:"size:255;not null" json:"password"`
+	Rank          int            `gorm:"not null;default:0" json:"rank"`
+	Description   string         `gorm:"size:255" json:"description"`
+	CollegeID     *int           `gorm:"column:college_id;index" json:"college_id" mapstructure:"college_id"` // 所属学院
+	CreateTime    time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	UpdateTime    time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+	RoleID        int            `gorm:"index" json:"role_id"`
+	Email         string         `gorm:"size:255" json:"email"` // 验证后才会接收邮件通知
+	EmailVerified bool           `gorm:"column:email_verified" json:"email_verified"`
+	MailLang      string         `gorm:"column:mail_lang;size:8" json:"mail_lang"` // 邮件语言 zh/en
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// Colleges 学院
+type Colleges struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:255;uniqueIndex" json:"name"`
+	Majors     []Majors  `gorm:"foreignKey:CollegeID" json:"majors,omitempty"`
 	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
 	UpdateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
 }
 
-type Teachers struct {
-	TID         string    `gorm:"column:tid;primaryKey" json:"tid"`
-	Name        string    `gorm:"size:255;not null" json:"name"`
-	Password    string    `gorm:"size:255;not null" json:"password"`
-	Rank        int       `gorm:"not null;default:0" json:"rank"`
+// Majors 专业，属于一个学院，同一学院内名称不能重复
+type Majors struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	CollegeID  int       `gorm:"column:college_id;uniqueIndex:idx_majors_college_name" json:"college_id"`
+	Name       string    `gorm:"size:255;uniqueIndex:idx_majors_college_name" json:"name"`
+	Classes    []Classes `gorm:"foreignKey:MajorID" json:"classes,omitempty"`
+	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	UpdateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+}
+
+// Classes 班级，属于一个专业，名称全校唯一，与 Students.Class 对应
+type Classes struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	MajorID    int       `gorm:"column:major_id;index" json:"major_id"`
+	Name       string    `gorm:"size:255;uniqueIndex" json:"name"`
+	Grade      int       `json:"grade"` // 年级，与 Students.Grade 一致
+	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	UpdateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+}
+
+// Records 数据库表的结构体定义
+type Records struct {
+	RecordID    int            `gorm:"column:record_id" json:"record_id"`
+	Status      int            `gorm:"column:status;default:0" json:"status"`
+	Score       string         `gorm:"column:score;type:varchar(255)" json:"score"`
+	Description string         `gorm:"column:description;type:varchar(255)" json:"description"`
+	SID         string         `gorm:"column:sid;type:varchar(255)" json:"sid"`
+	TID         string         `gorm:"column:tid;type:varchar(255);default:null" json:"tid"`
+	TeamRole    string         `gorm:"column:team_role;type:varchar(32);default:individual" json:"team_role"`
+	RaceID      int            `gorm:"column:race_id;index" json:"race_id"`
+	CreateTime  time.Time      `gorm:"column:create_time" json:"create_time"`
+	UpdateTime  time.Time      `gorm:"column:update_time" json:"update_time"`
+	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;index" json:"deleted_at"`
+	Student     Students       `gorm:"foreignKey:SID;references:SID" json:"student"`
+	Teacher     Teachers       `gorm:"foreignKey:TID;references:TID" json:"teacher"`
+	Race        Races          `gorm:"foreignKey:RaceID;references:RaceID" json:"race"`
+}
+
+// 参赛记录审核状态
+const (
+	RecordStatusPending  = 0 // 待审核
+	RecordStatusApproved = 1 // 审核通过
+	RecordStatusRejected = 2 // 审核驳回
+)
+
+// 参赛记录中的团队角色
+const (
+	TeamRoleIndividual = "individual" // 个人参赛
+	TeamRoleLeader     = "leader"     // 团队负责人
+	TeamRoleMember     = "member"     // 团队成员
+)
+
+// 附件所属实体类型
+const (
+	AttachmentOwnerRecord = "record"
+	AttachmentOwnerRace   = "race"
+)
+
+// Attachments 证明材料附件，关联到参赛记录或比赛，文件本体保存在对象存储中
+type Attachments struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	OwnerType  string    `gorm:"column:owner_type;type:enum('record','race');not null;index:idx_owner" json:"owner_type"`
+	OwnerID    int       `gorm:"column:owner_id;not null;index:idx_owner" json:"owner_id"`
+	ObjectKey  string    `gorm:"column:object_key;size:255;not null" json:"object_key"`
+	Name       string    `gorm:"size:255" json:"name"`
+	Size       int64     `gorm:"not null;default:0" json:"size"`
+	Hash       string    `gorm:"size:255" json:"hash"`
+	MimeType   string    `gorm:"column:mime_type;size:255" json:"mime_type"`
+	Uploader   string    `gorm:"size:255;not null" json:"uploader"`
+	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// Files 对象存储中文件的元数据，记录上传者以及关联的实体，用于权限校验和垃圾回收
+type Files struct {
+	ObjectKey  string    `gorm:"column:object_key;primaryKey;size:255" json:"object_key"`
+	Owner      string    `gorm:"size:255;not null;index" json:"owner"`
+	Purpose    string    `gorm:"size:255" json:"purpose"`
+	Name       string    `gorm:"size:255" json:"name"`
+	Size       int64     `gorm:"not null;default:0" json:"size"`
+	Hash       string    `gorm:"size:255" json:"hash"`
+	MimeType   string    `gorm:"column:mime_type;size:255" json:"mime_type"`
+	LinkedType string    `gorm:"column:linked_type;size:255;index:idx_linked" json:"linked_type"`
+	LinkedID   int       `gorm:"column:linked_id;index:idx_linked" json:"linked_id"`
+	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// AuditLogs 审计日志
+type AuditLogs struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	Actor      string    `gorm:"size:255;index" json:"actor"`
+	Identity   string    `gorm:"size:255" json:"identity"`
+	IP         string    `gorm:"column:ip;size:64" json:"ip"`
+	Method     string    `gorm:"size:16" json:"method"`
+	Route      string    `gorm:"size:255" json:"route"`
+	Status     int       `json:"status"` // 响应状态码
+	Action     string    `gorm:"size:255;index" json:"action"`
+	TargetType string    `gorm:"column:target_type;size:255" json:"target_type"`
+	TargetID   string    `gorm:"column:target_id;size:255" json:"target_id"`
+	Detail     string    `gorm:"type:text" json:"detail"`
+	Changes    string    `gorm:"type:text" json:"changes"` // 修改前后的字段差异，JSON 格式
+	Outcome    string    `gorm:"type:enum('success','failure')" json:"outcome"`
+	OnBehalfOf string    `gorm:"column:on_behalf_of;size:255" json:"on_behalf_of"` // 模拟登录期间被模拟的账号，Actor 为管理员
+	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP;index" json:"create_time"`
+}
+
+// CertificateTemplates 证书模板，Fields 为 JSON 格式的文字位置配置
+type CertificateTemplates struct {
+	ID            int       `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"size:255;not null" json:"name"`
+	BackgroundKey string    `gorm:"column:background_key;size:255;not null" json:"background_key"`
+	Fields        string    `gorm:"type:text" json:"fields"`
+	CreateTime    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	UpdateTime    time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
+}
+
+// Certificates 为获奖记录生成的证书，VerifyCode 用于公开验证真伪
+type Certificates struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	RecordID    int       `gorm:"column:record_id;index" json:"record_id"`
+	TemplateID  int       `gorm:"column:template_id" json:"template_id"`
+	Serial      string    `gorm:"size:64;unique" json:"serial"`
+	VerifyCode  string    `gorm:"column:verify_code;size:64;unique" json:"verify_code"`
+	StudentName string    `gorm:"column:student_name;size:255" json:"student_name"`
+	RaceTitle   string    `gorm:"column:race_title;size:255" json:"race_title"`
+	Award       string    `gorm:"size:255" json:"award"`
+	IssueDate   time.Time `gorm:"column:issue_date" json:"issue_date"`
+	ImageKey    string    `gorm:"column:image_key;size:255" json:"image_key"`
+	PdfKey      string    `gorm:"column:pdf_key;size:255" json:"pdf_key"`
+	CreateTime  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// CreditRuleSets 学分规则版本，从 EffectiveYear 学年起生效，直到有更新的版本生效
+// 已生效的版本不可修改，调整规则需新增版本，保证往年学分按当年规则计算
+type CreditRuleSets struct {
+	ID            int           `gorm:"primaryKey" json:"id"`
+	Name          string        `gorm:"size:255;not null" json:"name"`
+	EffectiveYear int           `gorm:"column:effective_year;not null;unique" json:"effective_year"` // 生效学年，如 2024 表示 2024-2025 学年
+	YearCap       float64       `gorm:"column:year_cap;not null;default:0" json:"year_cap"`          // 每学年学分上限，0 表示不限
+	Rules         []CreditRules `gorm:"foreignKey:RuleSetID;references:ID" json:"rules"`
+	CreateTime    time.Time     `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// CreditRules 比赛级别 × 获奖等级 × 团队角色 对应的学分，TeamRole 为 * 时匹配任意角色
+type CreditRules struct {
+	ID        int     `gorm:"primaryKey" json:"id"`
+	RuleSetID int     `gorm:"column:rule_set_id;index" json:"rule_set_id"`
+	Level     int     `gorm:"not null" json:"level"`
+	Award     string  `gorm:"size:255;not null" json:"award"`
+	TeamRole  string  `gorm:"column:team_role;size:32;not null;default:*" json:"team_role"`
+	Credits   float64 `gorm:"not null" json:"credits"`
+}
+
+// TwoFactors 用户的两步验证设置，Secret 为加密后的 TOTP 密钥，Enabled 为 false 表示已生成密钥但尚未验证启用
+type TwoFactors struct {
+	Account        string     `gorm:"primaryKey;size:255" json:"account"`
+	Secret         string     `gorm:"size:255" json:"-"`
+	Enabled        bool       `json:"enabled"`
+	LastStep       int64      `gorm:"column:last_step" json:"-"`
+	FailedAttempts int        `gorm:"column:failed_attempts" json:"-"`
+	LockedUntil    *time.Time `gorm:"column:locked_until" json:"locked_until"`
+	EnabledTime    *time.Time `gorm:"column:enabled_time" json:"enabled_time"`
+	CreateTime     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// TwoFactorRecoveryCodes 两步验证的恢复码，只保存摘要，每个恢复码只能使用一次
+type TwoFactorRecoveryCodes struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	Account    string     `gorm:"size:255;index" json:"account"`
+	CodeHash   string     `gorm:"column:code_hash;size:64" json:"-"`
+	UsedTime   *time.Time `gorm:"column:used_time" json:"used_time"`
+	CreateTime time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// ServiceAccounts 供外部系统集成使用的服务账号，权限由角色决定，通过 API 密钥访问接口
+type ServiceAccounts struct {
+	Account     string    `gorm:"primaryKey;size:64" json:"account"`
+	Name        string    `gorm:"size:255" json:"name"`
 	Description string    `gorm:"size:255" json:"description"`
+	RoleID      int       `gorm:"index" json:"role_id"`
+	Disabled    bool      `json:"disabled"`
+	CreatedBy   string    `gorm:"column:created_by;size:255" json:"created_by"`
 	CreateTime  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
 	UpdateTime  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"update_time"`
-	RoleID      int       `gorm:"index" json:"role_id"`
 }
 
-// Records 数据库表的结构体定义
-type Records struct {
-	RecordID    int       `gorm:"column:record_id" json:"record_id"`
-	Status      int       `gorm:"column:status;default:0" json:"status"`
-	Score       string    `gorm:"column:score;type:varchar(255)" json:"score"`
-	Description string    `gorm:"column:description;type:varchar(255)" json:"description"`
-	SID         string    `gorm:"column:sid;type:varchar(255)" json:"sid"`
-	TID         string    `gorm:"column:tid;type:varchar(255);default:null" json:"tid"`
-	RaceID      int       `gorm:"column:race_id;index" json:"race_id"`
-	CreateTime  time.Time `gorm:"column:create_time" json:"create_time"`
-	UpdateTime  time.Time `gorm:"column:update_time" json:"update_time"`
-	Student     Students  `gorm:"foreignKey:SID;references:SID" json:"student"`
-	Teacher     Teachers  `gorm:"foreignKey:TID;references:TID" json:"teacher"`
-	Race        Races     `gorm:"foreignKey:RaceID;references:RaceID" json:"race"`
+// APIKeys 服务账号的 API 密钥，只保存 SHA-256 摘要，Prefix 为可公开显示的前缀
+// Scopes 为逗号分隔的权限（type:action），为空时拥有服务账号角色的全部权限
+type APIKeys struct {
+	ID             int        `gorm:"primaryKey" json:"id"`
+	ServiceAccount string     `gorm:"column:service_account;size:64;index" json:"service_account"`
+	Name           string     `gorm:"size:255" json:"name"`
+	Prefix         string     `gorm:"size:32;uniqueIndex" json:"prefix"`
+	KeyHash        string     `gorm:"column:key_hash;size:64" json:"-"`
+	Scopes         string     `gorm:"type:text" json:"scopes"`
+	ExpireTime     *time.Time `gorm:"column:expire_time" json:"expire_time"`
+	RevokedTime    *time.Time `gorm:"column:revoked_time" json:"revoked_time"`
+	LastUsedTime   *time.Time `gorm:"column:last_used_time" json:"last_used_time"`
+	LastUsedIP     string     `gorm:"column:last_used_ip;size:64" json:"last_used_ip"`
+	UsageCount     int64      `gorm:"column:usage_count" json:"usage_count"`
+	CreatedBy      string     `gorm:"column:created_by;size:255" json:"created_by"`
+	CreateTime     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// APIKeyUsage API 密钥每日调用次数
+type APIKeyUsage struct {
+	KeyID int       `gorm:"column:key_id;primaryKey" json:"key_id"`
+	Date  time.Time `gorm:"primaryKey;type:date" json:"date"`
+	Count int64     `json:"count"`
+}
+
+// UserSessions 服务端记录的登录会话，登录令牌中的 sid 对应 ID，注销后令牌立即失效
+// ImpersonatedBy 不为空表示管理员模拟该用户登录的会话
+type UserSessions struct {
+	ID             string     `gorm:"primaryKey;size:64" json:"id"`
+	Account        string     `gorm:"size:255;index" json:"account"`
+	Device         string     `gorm:"size:255" json:"device"`
+	IP             string     `gorm:"column:ip;size:64" json:"ip"`
+	UserAgent      string     `gorm:"column:user_agent;size:512" json:"user_agent"`
+	CreateTime     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+	LastSeenTime   time.Time  `gorm:"column:last_seen_time" json:"last_seen_time"`
+	ExpireTime     time.Time  `gorm:"column:expire_time" json:"expire_time"`
+	RevokedTime    *time.Time `gorm:"column:revoked_time" json:"revoked_time"`
+	ImpersonatedBy string     `gorm:"column:impersonated_by;size:255" json:"impersonated_by"` // 模拟登录会话的管理员账号
+	OriginSession  string     `gorm:"column:origin_session;size:64" json:"-"`                 // 管理员的原会话，失效时模拟会话随之失效
+}
+
+// PasswordResetTokens 找回密码的一次性令牌，只保存令牌的 SHA-256 摘要
+type PasswordResetTokens struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	Account    string     `gorm:"size:255;index" json:"account"`
+	TokenHash  string     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"`
+	ExpireTime time.Time  `gorm:"column:expire_time" json:"expire_time"`
+	UsedTime   *time.Time `gorm:"column:used_time" json:"used_time"`
+	CreateTime time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// EmailVerifications 邮箱验证链接，令牌绑定发出时待验证的邮箱，只保存摘要且只能使用一次
+type EmailVerifications struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	Account    string     `gorm:"size:255;index:idx_email_verifications_account" json:"account"`
+	Identity   string     `gorm:"size:32;index:idx_email_verifications_account" json:"identity"`
+	Email      string     `gorm:"size:255" json:"email"`
+	TokenHash  string     `gorm:"column:token_hash;size:64;uniqueIndex" json:"-"`
+	ExpireTime time.Time  `gorm:"column:expire_time" json:"expire_time"`
+	UsedTime   *time.Time `gorm:"column:used_time" json:"used_time"`
+	CreateTime time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
 }
 
+// Revisions 比赛和参赛记录的历史版本，Snapshot 为该版本完整数据的 JSON
+type Revisions struct {
+	ID         int       `gorm:"primaryKey" json:"id"`
+	EntityType string    `gorm:"column:entity_type;size:32;uniqueIndex:idx_revision" json:"entity_type"`
+	EntityID   int       `gorm:"column:entity_id;uniqueIndex:idx_revision" json:"entity_id"`
+	Version    int       `gorm:"uniqueIndex:idx_revision" json:"version"`
+	Action     string    `gorm:"size:32" json:"action"`
+	Operator   string    `gorm:"size:255" json:"operator"`
+	Snapshot   string    `gorm:"type:text" json:"snapshot"`
+	CreateTime time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// 历史版本的实体类型
+const (
+	RevisionEntityRace   = "race"
+	RevisionEntityRecord = "record"
+)
+
+// 历史版本的产生原因
+const (
+	RevisionActionBaseline = "baseline" // 启用版本记录前的原始数据
+	RevisionActionCreate   = "create"
+	RevisionActionUpdate   = "update"
+	RevisionActionStatus   = "status" // 审核
+	RevisionActionRevert   = "revert"
+)
+
+// Notifications 站内通知，Recipient 为接收人账号
+type Notifications struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	Recipient  string     `gorm:"size:255;index:idx_notification_recipient" json:"recipient"`
+	Event      string     `gorm:"size:64" json:"event"`
+	Title      string     `gorm:"size:255" json:"title"`
+	Content    string     `gorm:"size:1024" json:"content"`
+	TargetType string     `gorm:"column:target_type;size:32" json:"target_type"`
+	TargetID   int        `gorm:"column:target_id" json:"target_id"`
+	IsRead     bool       `gorm:"column:is_read;default:false;index:idx_notification_recipient" json:"is_read"`
+	ReadTime   *time.Time `gorm:"column:read_time" json:"read_time"`
+	CreateTime time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// 通知事件类型
+const (
+	NotifyRecordStatus     = "record_status"     // 参赛记录审核结果
+	NotifyAdvisorRequest   = "advisor_request"   // 学生报名时选择了指导教师
+	NotifyRacePublished    = "race_published"    // 发布新比赛
+	NotifyDeadlineReminder = "deadline_reminder" // 比赛即将截止
+)
+
+// NotificationEvents 全部通知事件及名称
+var NotificationEvents = map[string]string{
+	NotifyRecordStatus:     "审核结果",
+	NotifyAdvisorRequest:   "指导申请",
+	NotifyRacePublished:    "新比赛发布",
+	NotifyDeadlineReminder: "截止提醒",
+}
+
+// NotificationPreferences 用户的通知偏好，没有记录时站内通知和邮件都默认接收
+type NotificationPreferences struct {
+	Account string `gorm:"primaryKey;size:255" json:"account"`
+	Event   string `gorm:"primaryKey;size:64" json:"event"`
+	InApp   bool   `gorm:"column:in_app" json:"in_app"`
+	Email   bool   `gorm:"column:email" json:"email"`
+}
+
+// MailQueue 待发送的邮件，发送失败按退避时间重试
+type MailQueue struct {
+	ID          int        `gorm:"primaryKey" json:"id"`
+	Recipient   string     `gorm:"size:255" json:"recipient"`
+	Subject     string     `gorm:"size:255" json:"subject"`
+	Body        string     `gorm:"type:text" json:"body"`
+	Status      string     `gorm:"type:enum('pending','sent','failed');index:idx_mail_queue_status" json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `gorm:"column:last_error;size:1024" json:"last_error"`
+	NextAttempt time.Time  `gorm:"column:next_attempt;index:idx_mail_queue_status" json:"next_attempt"`
+	SentTime    *time.Time `gorm:"column:sent_time" json:"sent_time"`
+	CreateTime  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"create_time"`
+}
+
+// 邮件发送状态
+const (
+	MailStatusPending = "pending"
+	MailStatusSent    = "sent"
+	MailStatusFailed  = "failed"
+)
+
 func (s *Students) SetPassword(password string) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -15,10 +15,82 @@ func SetupRouter(r *gin.Engine) *gin.Engine {
 		auth.GET("/code", controllers.GenerateCaptcha)
 		//登录
 		auth.POST("/login", controllers.Login)
+		//找回密码：申请重置链接、通过链接重置密码
+		auth.POST("/password/forgot", controllers.ForgotPassword)
+		auth.POST("/password/reset", controllers.ResetPasswordByToken)
+		//登录第二步：提交两步验证码
+		auth.POST("/login/2fa", controllers.LoginTwoFactor)
+		//统一身份认证（OIDC）单点登录
+		auth.GET("/oidc/login", controllers.OIDCLogin)
+		auth.GET("/oidc/callback", controllers.OIDCCallback)
+		//CAS 统一认证
+		auth.GET("/cas/login", controllers.CASLogin)
+		auth.GET("/cas/callback", controllers.CASCallback)
+	}
+	// 邮箱验证链接，对外公开
+	r.GET("/email/verify", controllers.VerifyEmail)
+	// 证书真伪验证，对外公开
+	r.GET("/certificate/verify/:code", controllers.VerifyCertificate)
+
+	// 本地存储的签名上传下载，凭签名鉴权无需登录
+	local := r.Group("/storage/local")
+	{
+		local.GET("/*key", controllers.LocalDownload)
+		local.PUT("/*key", controllers.LocalUpload)
+		local.POST("/*key", controllers.LocalUpload)
 	}
 	// 应用登录检查和权限检查中间件
 	r.Use(middlewares.LoginCheckMiddleware())
+	r.Use(middlewares.AuditMiddleware())
 	r.Use(middlewares.AuthCheckMiddleware())
+	// 两步验证设置
+	twoFactor := r.Group("/auth/2fa")
+	{
+		twoFactor.GET("/status", controllers.TwoFactorStatus)
+		twoFactor.POST("/setup", controllers.SetupTwoFactor)
+		twoFactor.POST("/enable", controllers.EnableTwoFactor)
+		twoFactor.POST("/disable", controllers.DisableTwoFactor)
+		twoFactor.POST("/recovery_codes", controllers.RegenerateRecoveryCodes)
+	}
+	// 已登录设备管理及退出登录
+	r.POST("/auth/logout", controllers.Logout)
+	r.POST("/auth/impersonate/stop", controllers.StopImpersonation)
+	sessions := r.Group("/auth/sessions")
+	{
+		sessions.GET("", controllers.ListSessions)
+		sessions.POST("/revoke", controllers.RevokeSession)
+		sessions.POST("/revoke_others", controllers.RevokeOtherSessions)
+	}
+	// 服务账号及 API 密钥
+	service := r.Group("/service")
+	{
+		service.GET("/list", controllers.ListServiceAccounts)
+		service.POST("/add", controllers.AddServiceAccount)
+		service.PUT("/update", controllers.UpdateServiceAccount)
+		service.DELETE("/delete", controllers.DeleteServiceAccount)
+		service.GET("/key/list", controllers.ListAPIKeys)
+		service.POST("/key/create", controllers.CreateAPIKey)
+		service.POST("/key/revoke", controllers.RevokeAPIKey)
+		service.GET("/key/usage", controllers.APIKeyUsage)
+	}
+	// 学院、专业、班级
+	org := r.Group("/org")
+	{
+		org.GET("/tree", controllers.OrgTree)
+		org.GET("/college/list", controllers.ListColleges)
+		org.POST("/college/add", controllers.AddCollege)
+		org.PUT("/college/update", controllers.UpdateCollege)
+		org.DELETE("/college/delete", controllers.DeleteCollege)
+		org.GET("/major/list", controllers.ListMajors)
+		org.POST("/major/add", controllers.AddMajor)
+		org.PUT("/major/update", controllers.UpdateMajor)
+		org.DELETE("/major/delete", controllers.DeleteMajor)
+		org.GET("/class/list", controllers.ListClasses)
+		org.POST("/class/add", controllers.AddClass)
+		org.PUT("/class/update", controllers.UpdateClass)
+		org.DELETE("/class/delete", controllers.DeleteClass)
+		org.POST("/migrate", controllers.MigrateClasses)
+	}
 	//获取用户数据--初始化+权限
 	r.GET("/get_user", controllers.InitUser)
 	// 权限相关路由
@@ -41,6 +113,11 @@ func SetupRouter(r *gin.Engine) *gin.Engine {
 		users.POST("/add", controllers.AddUsers)
 		users.POST("/import", controllers.AddImport)
 		users.DELETE("/delete", controllers.DeleteUsers)
+		users.POST("/restore", controllers.RestoreUsers)
+		users.POST("/2fa/reset", controllers.ResetTwoFactor)
+		users.POST("/sessions/terminate", controllers.TerminateSessions)
+		users.POST("/impersonate", controllers.StartImpersonation)
+		users.PUT("/email", controllers.UpdateEmail)
 	}
 
 	// 角色相关路由 -- 即超级管理员 管理员 学生等权限的管理
@@ -58,6 +135,7 @@ func SetupRouter(r *gin.Engine) *gin.Engine {
 		race.GET("/list", controllers.ListRaces)
 		race.POST("/add", controllers.AddRace)
 		race.DELETE("/delete", controllers.DeleteRace)
+		race.POST("/restore", controllers.RestoreRace)
 		race.PUT("/update", controllers.UpdateRace)
 	}
 
@@ -66,18 +144,95 @@ func SetupRouter(r *gin.Engine) *gin.Engine {
 	{
 		record.POST("/add", controllers.AddRecord)
 		record.DELETE("/delete", controllers.DeleteRecord)
+		record.POST("/restore", controllers.RestoreRecord)
+		record.POST("/revert", controllers.RevertRecord)
 		record.PATCH("/update", controllers.UpdateRecord)
+		record.PATCH("/status", controllers.UpdateRecordStatus)
 		record.GET("/list", controllers.ListRecords)
 	}
 
+	// 证明材料附件
+	attachment := r.Group("/attachment")
+	{
+		attachment.GET("/list", controllers.ListAttachments)
+		attachment.POST("/add", controllers.AddAttachment)
+		attachment.DELETE("/delete", controllers.DeleteAttachments)
+	}
+
+	// 获奖证书
+	certificate := r.Group("/certificate")
+	{
+		certificate.GET("/list", controllers.ListCertificates)
+		certificate.POST("/generate", controllers.GenerateCertificate)
+		certificate.GET("/template/list", controllers.ListCertificateTemplates)
+		certificate.POST("/template/add", controllers.AddCertificateTemplate)
+		certificate.PUT("/template/update", controllers.UpdateCertificateTemplate)
+		certificate.DELETE("/template/delete", controllers.DeleteCertificateTemplate)
+	}
+
+	// 统计分析
+	stats := r.Group("/stats")
+	{
+		stats.GET("/summary", controllers.StatsSummary)
+		stats.GET("/group", controllers.StatsGroup)
+		stats.GET("/trend", controllers.StatsTrend)
+		stats.GET("/top", controllers.StatsTop)
+	}
+
+	// 学生竞赛档案
+	r.GET("/student/portfolio", controllers.StudentPortfolio)
+
+	// 竞赛学分
+	r.GET("/student/credits", controllers.MyCredits)
+	credits := r.Group("/credits")
+	{
+		credits.GET("/report", controllers.CreditsReport)
+		credits.GET("/rules/list", controllers.ListCreditRuleSets)
+		credits.POST("/rules/add", controllers.AddCreditRuleSet)
+		credits.DELETE("/rules/delete", controllers.DeleteCreditRuleSet)
+	}
+
+	// 站内通知，只能查看和操作自己的通知
+	notification := r.Group("/notification")
+	{
+		notification.GET("/list", controllers.ListNotifications)
+		notification.GET("/unread_count", controllers.UnreadNotificationCount)
+		notification.POST("/read", controllers.MarkNotificationsRead)
+		notification.GET("/preferences", controllers.GetNotificationPreferences)
+		notification.PUT("/preferences", controllers.UpdateNotificationPreferences)
+	}
+
+	// 比赛和参赛记录的历史版本
+	history := r.Group("/history")
+	{
+		history.GET("/list", controllers.ListHistory)
+		history.GET("/diff", controllers.DiffHistory)
+		history.GET("/view", controllers.ViewHistory)
+	}
+
+	// 回收站
+	r.GET("/recycle/list", controllers.ListRecycleBin)
+
+	// 审计日志
+	r.GET("/audit/list", controllers.ListAuditLogs)
+
+	// 报表
+	report := r.Group("/report")
+	{
+		report.GET("/teacher", controllers.TeacherReport)
+	}
+
 	// 文件上传下载管理
 	file := r.Group("/file")
 	{
 		file.GET("/get_upload_token", controllers.GetUploadToken)
+		file.POST("/upload", controllers.UploadFile)
 		file.GET("/get_file_url", controllers.GetFileUrl)
 		file.POST("/refresh_file_url", controllers.RefreshFileUrl)
 		file.GET("/get_file_info", controllers.GetFileInfo)
 		file.POST("/delete_file", controllers.DeleteFile)
+		file.GET("/gc/report", controllers.FileGCReport)
+		file.POST("/gc/run", controllers.RunFileGC)
 	}
 
 	//// 使用 SetUser 中间件
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// API 密钥格式为 csk_<8 位十六进制前缀>_<随机串>，前缀用于查找和显示，完整密钥只在创建时返回一次
+const apiKeyTag = "csk_"
+
+// IdentityService 服务账号在 AuthenticatedUser 中的身份类型
+const IdentityService = "service"
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey 生成新的 API 密钥，返回完整密钥、前缀和摘要
+func GenerateAPIKey() (string, string, string, error) {
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	visible := apiKeyTag + hex.EncodeToString(prefix)
+	key := visible + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, visible, hashAPIKey(key), nil
+}
+
+// ParseScopes 解析逗号分隔的权限列表
+func ParseScopes(scopes string) []string {
+	var result []string
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+// MissingPermissions 返回 required 中不在 granted 里的权限
+func MissingPermissions(required, granted []string) []string {
+	owned := make(map[string]bool, len(granted))
+	for _, p := range granted {
+		owned[p] = true
+	}
+	var missing []string
+	for _, p := range required {
+		if !owned[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// CheckRoleGrantable 校验角色的权限都在 granted 范围内，避免借服务账号提升权限
+func CheckRoleGrantable(granted []string, roleID int) error {
+	var count int64
+	if err := config.DB.Model(&models.Roles{}).Where("id = ?", roleID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &config.ValidationError{Message: "角色不存在"}
+	}
+	permissions, err := RolePermissions(roleID)
+	if err != nil {
+		return err
+	}
+	if missing := MissingPermissions(permissions, granted); len(missing) > 0 {
+		return &config.ValidationError{Message: "不能分配超出自身权限的角色，缺少: " + strings.Join(missing, ", ")}
+	}
+	return nil
+}
+
+// CreateAPIKey 为服务账号创建 API 密钥，scopes 必须是服务账号角色权限的子集，为空表示使用角色的全部权限
+// 服务账号的角色和 scopes 都不能超出操作人自身的权限
+func CreateAPIKey(account, name string, scopes []string, expires *time.Time, operator models.AuthenticatedUser) (string, *models.APIKeys, error) {
+	var service models.ServiceAccounts
+	if err := config.DB.Where("account = ?", account).First(&service).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, &config.ValidationError{Message: "服务账号不存在"}
+		}
+		return "", nil, err
+	}
+	if err := CheckRoleGrantable(operator.Permissions, service.RoleID); err != nil {
+		return "", nil, err
+	}
+	permissions, err := RolePermissions(service.RoleID)
+	if err != nil {
+		return "", nil, err
+	}
+	if missing := MissingPermissions(scopes, permissions); len(missing) > 0 {
+		return "", nil, &config.ValidationError{Message: "服务账号的角色不包含权限: " + strings.Join(missing, ", ")}
+	}
+	if missing := MissingPermissions(scopes, operator.Permissions); len(missing) > 0 {
+		return "", nil, &config.ValidationError{Message: "不能授予超出自身权限的范围，缺少: " + strings.Join(missing, ", ")}
+	}
+	if expires != nil && !expires.After(time.Now()) {
+		return "", nil, &config.ValidationError{Message: "过期时间必须晚于当前时间"}
+	}
+
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.APIKeys{
+		ServiceAccount: account,
+		Name:           name,
+		Prefix:         prefix,
+		KeyHash:        hash,
+		Scopes:         strings.Join(scopes, ","),
+		ExpireTime:     expires,
+		CreatedBy:      operator.Account,
+		CreateTime:     time.Now(),
+	}
+	if err := config.DB.Create(record).Error; err != nil {
+		return "", nil, err
+	}
+	return key, record, nil
+}
+
+// AuthenticateAPIKey 校验 API 密钥，返回服务账号的身份和权限（角色权限与密钥权限范围的交集），并记录调用次数
+func AuthenticateAPIKey(key, ip string) (*models.AuthenticatedUser, error) {
+	invalid := &config.ValidationError{Message: "API 密钥无效"}
+	visible, _, ok := strings.Cut(strings.TrimPrefix(key, apiKeyTag), "_")
+	if !strings.HasPrefix(key, apiKeyTag) || !ok || len(visible) != 8 {
+		return nil, invalid
+	}
+	var record models.APIKeys
+	if err := config.DB.Where("prefix = ?", apiKeyTag+visible).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(record.KeyHash), []byte(hashAPIKey(key))) != 1 {
+		return nil, invalid
+	}
+	now := time.Now()
+	if record.RevokedTime != nil {
+		return nil, &config.ValidationError{Message: "API 密钥已撤销"}
+	}
+	if record.ExpireTime != nil && now.After(*record.ExpireTime) {
+		return nil, &config.ValidationError{Message: "API 密钥已过期"}
+	}
+
+	var service models.ServiceAccounts
+	if err := config.DB.Where("account = ?", record.ServiceAccount).First(&service).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if service.Disabled {
+		return nil, &config.ValidationError{Message: "服务账号已停用"}
+	}
+	var role models.Roles
+	if err := config.DB.Where("id = ?", service.RoleID).First(&role).Error; err != nil {
+		return nil, err
+	}
+	permissions, err := RolePermissions(service.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	if scopes := ParseScopes(record.Scopes); len(scopes) > 0 {
+		// 角色权限在密钥创建后可能被收回，只保留两者都有的权限
+		var granted []string
+		missing := MissingPermissions(scopes, permissions)
+		for _, scope := range scopes {
+			if !containsString(missing, scope) {
+				granted = append(granted, scope)
+			}
+		}
+		permissions = granted
+	}
+
+	recordAPIKeyUsage(record.ID, ip, now)
+	return &models.AuthenticatedUser{
+		Account:     service.Account,
+		Identity:    IdentityService,
+		Role:        role,
+		Permissions: permissions,
+	}, nil
+}
+
+// recordAPIKeyUsage 更新密钥的最近使用信息和当日调用次数，失败只记录日志
+func recordAPIKeyUsage(id int, ip string, now time.Time) {
+	if err := config.DB.Model(&models.APIKeys{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"usage_count":    gorm.Expr("usage_count + 1"),
+		"last_used_time": now,
+		"last_used_ip":   ip,
+	}).Error; err != nil {
+		log.Error().Err(err).Int("id", id).Msg("更新 API 密钥使用信息失败")
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := config.DB.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("count + 1")}),
+	}).Create(&models.APIKeyUsage{KeyID: id, Date: day, Count: 1}).Error; err != nil {
+		log.Error().Err(err).Int("id", id).Msg("记录 API 密钥调用次数失败")
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+// seedServiceAccount 创建角色 10（race:query、record:query）及使用该角色的服务账号 sync
+func seedServiceAccount(t *testing.T) {
+	t.Helper()
+	testutil.SeedRole(t, 10, "race:query", "record:query")
+	if err := config.DB.Create(&models.ServiceAccounts{Account: "sync", Name: "同步", RoleID: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+var apiKeyOperator = models.AuthenticatedUser{Account: "root", Permissions: []string{"service:add", "race:query", "record:query"}}
+
+func TestCreateAPIKeyRejectsEscalation(t *testing.T) {
+	testutil.OpenDB(t)
+	seedServiceAccount(t)
+
+	limited := models.AuthenticatedUser{Account: "ops", Permissions: []string{"service:add", "race:query"}}
+	if _, _, err := CreateAPIKey("sync", "k", nil, nil, limited); !isValidationError(err) {
+		t.Fatalf("服务账号角色超出操作人权限时应被拒绝，得到 %v", err)
+	}
+	if _, _, err := CreateAPIKey("sync", "k", []string{"race:query"}, nil, limited); !isValidationError(err) {
+		t.Fatalf("即使 scopes 在范围内，角色超出操作人权限时也应被拒绝，得到 %v", err)
+	}
+	if _, _, err := CreateAPIKey("sync", "k", []string{"user:delete"}, nil, apiKeyOperator); !isValidationError(err) {
+		t.Fatalf("scopes 超出角色权限时应被拒绝，得到 %v", err)
+	}
+	if _, _, err := CreateAPIKey("sync", "k", []string{"race:query"}, nil, apiKeyOperator); err != nil {
+		t.Fatalf("权限范围内应创建成功: %v", err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	testutil.OpenDB(t)
+	seedServiceAccount(t)
+
+	key, record, err := CreateAPIKey("sync", "k", []string{"race:query"}, nil, apiKeyOperator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, record.Prefix+"_") || record.KeyHash == key {
+		t.Fatalf("密钥格式有误: key=%s prefix=%s", key, record.Prefix)
+	}
+
+	user, err := AuthenticateAPIKey(key, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if user.Account != "sync" || user.Identity != IdentityService {
+		t.Fatalf("身份有误: %+v", user)
+	}
+	if len(user.Permissions) != 1 || user.Permissions[0] != "race:query" {
+		t.Fatalf("权限应为角色与 scopes 的交集，得到 %v", user.Permissions)
+	}
+	if _, err := AuthenticateAPIKey(key+"x", ""); !isValidationError(err) {
+		t.Fatalf("错误的密钥应被拒绝，得到 %v", err)
+	}
+	if _, err := AuthenticateAPIKey("csk_zz", ""); !isValidationError(err) {
+		t.Fatalf("格式错误的密钥应被拒绝，得到 %v", err)
+	}
+
+	var stored models.APIKeys
+	config.DB.First(&stored, record.ID)
+	if stored.UsageCount != 1 || stored.LastUsedIP != "10.0.0.1" {
+		t.Fatalf("应记录使用信息，得到 count=%d ip=%s", stored.UsageCount, stored.LastUsedIP)
+	}
+	AuthenticateAPIKey(key, "10.0.0.1")
+	var usage models.APIKeyUsage
+	if err := config.DB.Where("key_id = ?", record.ID).First(&usage).Error; err != nil || usage.Count != 2 {
+		t.Fatalf("当日调用次数应为 2，得到 %d, %v", usage.Count, err)
+	}
+}
+
+func TestAuthenticateAPIKeyRejectsRevokedExpiredAndDisabled(t *testing.T) {
+	testutil.OpenDB(t)
+	seedServiceAccount(t)
+
+	revoked, record, _ := CreateAPIKey("sync", "revoked", nil, nil, apiKeyOperator)
+	config.DB.Model(&models.APIKeys{}).Where("id = ?", record.ID).Update("revoked_time", time.Now())
+	if _, err := AuthenticateAPIKey(revoked, ""); !isValidationError(err) {
+		t.Fatalf("已撤销的密钥应被拒绝，得到 %v", err)
+	}
+
+	expired, record, _ := CreateAPIKey("sync", "expired", nil, nil, apiKeyOperator)
+	config.DB.Model(&models.APIKeys{}).Where("id = ?", record.ID).Update("expire_time", time.Now().Add(-time.Minute))
+	if _, err := AuthenticateAPIKey(expired, ""); !isValidationError(err) {
+		t.Fatalf("已过期的密钥应被拒绝，得到 %v", err)
+	}
+
+	key, _, _ := CreateAPIKey("sync", "active", nil, nil, apiKeyOperator)
+	config.DB.Model(&models.ServiceAccounts{}).Where("account = ?", "sync").Update("disabled", true)
+	if _, err := AuthenticateAPIKey(key, ""); !isValidationError(err) {
+		t.Fatalf("服务账号停用后密钥应失效，得到 %v", err)
+	}
+}
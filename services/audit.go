@@ -0,0 +1,233 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	auditedKey     = "auditWritten"
+	auditTargetKey = "auditTarget"
+	auditActiveKey = "auditActive"
+	auditEntryKey  = "auditEntry"
+)
+
+// auditTarget 由业务处理函数登记的操作对象及修改前后的数据
+type auditTarget struct {
+	Type   string
+	ID     string
+	Before interface{}
+	After  interface{}
+}
+
+// Audit 登记一条审计日志，操作人和 IP 取自请求上下文，err 不为空时记为失败
+// 经过审计中间件的请求在响应结束后才写入，状态码为最终的响应状态；否则立即写入
+// 审计日志写入失败不影响业务请求，只记录错误日志
+func Audit(c *gin.Context, action, targetType, targetID string, detail interface{}, err error) {
+	entry := newAuditEntry(c)
+	entry.Action = action
+	entry.TargetType = targetType
+	entry.TargetID = targetID
+	if detail != nil {
+		entry.Detail = marshalAudit(redact(toAuditValue(detail)))
+	}
+	if err != nil {
+		entry.Outcome = "failure"
+	}
+	if c.GetBool(auditActiveKey) {
+		c.Set(auditEntryKey, entry)
+		return
+	}
+	writeAudit(c, entry)
+}
+
+// BeginAudit 由审计中间件在请求开始时调用，之后 Audit 登记的日志由 FinishAudit 写入
+func BeginAudit(c *gin.Context) {
+	c.Set(auditActiveKey, true)
+}
+
+// FinishAudit 由审计中间件在请求结束后调用，写入 Audit 登记的日志并补上最终的响应状态码
+func FinishAudit(c *gin.Context) {
+	value, exists := c.Get(auditEntryKey)
+	if !exists {
+		return
+	}
+	entry := value.(models.AuditLogs)
+	entry.Status = c.Writer.Status()
+	if entry.Status >= 400 {
+		entry.Outcome = "failure"
+	}
+	writeAudit(c, entry)
+}
+
+// AuditTarget 登记本次请求操作的对象和修改前后的数据，由审计中间件在请求结束后写入日志
+// before/after 为 nil 分别表示新增和删除
+func AuditTarget(c *gin.Context, targetType, targetID string, before, after interface{}) {
+	c.Set(auditTargetKey, auditTarget{Type: targetType, ID: targetID, Before: before, After: after})
+}
+
+// AuditRequest 记录一次修改类请求，已经通过 Audit 显式登记过的请求不再重复记录
+// 操作名取自路由，如 /role/grant 记为 role:grant；结果以响应状态码判断
+func AuditRequest(c *gin.Context, body []byte) {
+	if _, exists := c.Get(auditEntryKey); exists || c.GetBool(auditedKey) {
+		return
+	}
+
+	entry := newAuditEntry(c)
+	entry.Action = strings.ReplaceAll(strings.Trim(entry.Route, "/"), "/", ":")
+	if entry.Status >= 400 {
+		entry.Outcome = "failure"
+	}
+	if value, exists := c.Get(auditTargetKey); exists {
+		target := value.(auditTarget)
+		entry.TargetType = target.Type
+		entry.TargetID = target.ID
+		if target.Before != nil || target.After != nil {
+			entry.Changes = marshalAudit(AuditDiff(target.Before, target.After))
+		}
+	}
+	if len(body) > 0 {
+		var request interface{}
+		if err := json.Unmarshal(body, &request); err == nil {
+			entry.Detail = marshalAudit(gin.H{"request": redact(request)})
+		}
+	}
+	writeAudit(c, entry)
+}
+
+// AuditDiff 比较修改前后的数据，只保留发生变化的字段，密码类字段不记录明文
+// 任一方不是对象（如批量删除的列表）时整体记录修改前后的数据
+func AuditDiff(before, after interface{}) map[string]interface{} {
+	beforeMap, okBefore := toAuditMap(before)
+	afterMap, okAfter := toAuditMap(after)
+	if !okBefore || !okAfter {
+		return map[string]interface{}{"before": redact(toAuditValue(before)), "after": redact(toAuditValue(after))}
+	}
+
+	diff := make(map[string]interface{})
+	for key, value := range beforeMap {
+		if !reflect.DeepEqual(value, afterMap[key]) {
+			diff[key] = map[string]interface{}{"before": value, "after": afterMap[key]}
+		}
+	}
+	for key, value := range afterMap {
+		if _, exists := beforeMap[key]; !exists {
+			diff[key] = map[string]interface{}{"before": nil, "after": value}
+		}
+	}
+	return redact(diff).(map[string]interface{})
+}
+
+func newAuditEntry(c *gin.Context) models.AuditLogs {
+	entry := models.AuditLogs{
+		IP:         c.ClientIP(),
+		Method:     c.Request.Method,
+		Route:      c.FullPath(),
+		Status:     c.Writer.Status(),
+		Outcome:    "success",
+		CreateTime: time.Now(),
+	}
+	if entry.Route == "" {
+		entry.Route = c.Request.URL.Path
+	}
+	if user, exists := c.Get("authenticatedUser"); exists {
+		authUser := user.(models.AuthenticatedUser)
+		entry.Actor = authUser.Account
+		entry.Identity = authUser.Identity
+		// 模拟登录期间操作人记为管理员，被模拟的账号单独记录
+		if authUser.ImpersonatedBy != "" {
+			entry.Actor = authUser.ImpersonatedBy
+			entry.OnBehalfOf = authUser.Account
+		}
+	}
+	return entry
+}
+
+func writeAudit(c *gin.Context, entry models.AuditLogs) {
+	c.Set(auditedKey, true)
+	if e := config.DB.Create(&entry).Error; e != nil {
+		log.Error().Err(e).Str("action", entry.Action).Msg("写入审计日志失败")
+	}
+}
+
+func marshalAudit(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// toAuditValue 将结构体转换为 JSON 通用结构，便于统一比较和脱敏
+func toAuditValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var result interface{}
+	json.Unmarshal(data, &result)
+	return result
+}
+
+func toAuditMap(value interface{}) (map[string]interface{}, bool) {
+	if value == nil {
+		return map[string]interface{}{}, true
+	}
+	result, ok := toAuditValue(value).(map[string]interface{})
+	return result, ok
+}
+
+// sensitiveFields 需要隐藏的字段名（小写并去掉 _ 和 -），如 API 密钥、验证码、恢复码、修改密码时的新旧密码
+var sensitiveFields = map[string]bool{
+	"key": true, "apikey": true, "privatekey": true, "code": true, "recoverycode": true, "recoverycodes": true,
+	"answer": true, "captcha": true, "challenge": true, "sign": true, "signature": true, "otp": true, "totp": true,
+	"oldval": true, "newval": true, "authorization": true, "cookie": true, "credential": true, "credentials": true,
+}
+
+// sensitiveField 判断字段是否需要隐藏，名称含 password、secret、token 的字段一律隐藏
+func sensitiveField(key string) bool {
+	name := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	if sensitiveFields[name] {
+		return true
+	}
+	for _, word := range []string{"password", "passwd", "secret", "token"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact 隐藏密码、令牌、验证码等敏感字段
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if sensitiveField(key) {
+				result[key] = "******"
+				continue
+			}
+			result[key] = redact(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = redact(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
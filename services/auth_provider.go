@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials 账号或密码错误、票据无效
+var ErrInvalidCredentials = errors.New("账号或密码错误")
+
+// Credentials 登录凭据，账号密码方式使用 Account/Password，CAS 使用 Ticket/Service
+type Credentials struct {
+	Identity string
+	Account  string
+	Password string
+	Ticket   string
+	Service  string
+}
+
+// AuthProvider 身份认证方式，按身份类型在 config.AuthProviders 中选择
+type AuthProvider interface {
+	// PasswordLogin 是否通过登录接口提交账号密码认证，为 false 时需要跳转到外部登录页
+	PasswordLogin() bool
+	// Authenticate 校验凭据，返回认证通过的用户信息，凭据错误时返回 ErrInvalidCredentials
+	Authenticate(cred Credentials) (*SSOProfile, error)
+}
+
+// AuthProviderFor 返回身份类型对应的认证方式，未配置时使用本地密码
+func AuthProviderFor(identity string) (AuthProvider, error) {
+	switch name := config.AuthProviders[identity]; name {
+	case "", "local":
+		return LocalProvider{}, nil
+	case "ldap":
+		return &LDAPProvider{
+			Binder: &utils.LDAPBinder{URL: config.LDAPURL, StartTLS: config.LDAPStartTLS, CAFile: config.LDAPCAFile, Timeout: config.LDAPTimeout},
+			UserDN: config.LDAPUserDN[identity],
+		}, nil
+	case "cas":
+		return &CASProvider{
+			Client: &utils.CASClient{ServerURL: config.CASServerURL, Client: &http.Client{Timeout: 10 * time.Second}},
+		}, nil
+	case "oidc":
+		return OIDCOnlyProvider{}, nil
+	default:
+		return nil, fmt.Errorf("未知的认证方式: %s", name)
+	}
+}
+
+// LoginProviderFor 返回账户登录时使用的认证方式，SSODeniedRoles 中的角色（默认超级管理员）始终使用本地密码，
+// 不交给 LDAP 或单点登录认证；账户不存在时 roleID 传 0
+func LoginProviderFor(identity string, roleID int) (AuthProvider, error) {
+	if ssoRoleDenied(roleID) {
+		return LocalProvider{}, nil
+	}
+	return AuthProviderFor(identity)
+}
+
+// CASService 身份类型对应的 CAS 回调地址，登录和校验票据时必须一致
+func CASService(identity string) string {
+	return config.CASServiceURL + "?identity=" + url.QueryEscape(identity)
+}
+
+// LocalProvider 本地密码认证，校验 users 表中的 bcrypt 密码
+type LocalProvider struct{}
+
+func (LocalProvider) PasswordLogin() bool { return true }
+
+func (LocalProvider) Authenticate(cred Credentials) (*SSOProfile, error) {
+	var user models.User
+	if err := config.DB.Where("account = ? AND identity = ?", cred.Account, cred.Identity).First(&user).Error; err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(cred.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &SSOProfile{Account: user.Account, Identity: user.Identity}, nil
+}
+
+// LDAPProvider LDAP 简单绑定认证，UserDN 为用户 DN 模板，%s 替换为转义后的账号
+type LDAPProvider struct {
+	Binder *utils.LDAPBinder
+	UserDN string
+}
+
+func (*LDAPProvider) PasswordLogin() bool { return true }
+
+func (p *LDAPProvider) Authenticate(cred Credentials) (*SSOProfile, error) {
+	if p.UserDN == "" {
+		return nil, fmt.Errorf("未配置 %s 的 LDAP 用户 DN", cred.Identity)
+	}
+	err := p.Binder.Bind(fmt.Sprintf(p.UserDN, utils.EscapeLDAPDN(cred.Account)), cred.Password)
+	if errors.Is(err, utils.ErrLDAPInvalidCredentials) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SSOProfile{Account: cred.Account, Identity: cred.Identity}, nil
+}
+
+// OIDCOnlyProvider 禁用账号密码登录，只能通过 OIDC 单点登录，认证在 OIDC 回调中完成
+type OIDCOnlyProvider struct{}
+
+func (OIDCOnlyProvider) PasswordLogin() bool { return false }
+
+func (OIDCOnlyProvider) Authenticate(cred Credentials) (*SSOProfile, error) {
+	return nil, ErrInvalidCredentials
+}
+
+// CASProvider CAS 票据认证，用户在 CAS 登录页登录后带票据跳转回本系统
+type CASProvider struct {
+	Client *utils.CASClient
+}
+
+func (*CASProvider) PasswordLogin() bool { return false }
+
+// LoginURL 跳转到 CAS 登录页的地址
+func (p *CASProvider) LoginURL(identity string) string {
+	return p.Client.LoginURL(CASService(identity))
+}
+
+func (p *CASProvider) Authenticate(cred Credentials) (*SSOProfile, error) {
+	user, attributes, err := p.Client.ValidateTicket(cred.Service, cred.Ticket)
+	if errors.Is(err, utils.ErrCASInvalidTicket) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &SSOProfile{
+		Account:  user,
+		Identity: cred.Identity,
+		Name:     attributes[config.CASNameAttribute],
+		Email:    attributes[config.CASEmailAttribute],
+	}
+	if profile.Name == "" {
+		profile.Name = user
+	}
+	// CAS 给出用户类型时必须与登录入口的身份类型一致，否则只允许已存在的账户登录
+	if config.CASIdentityAttribute != "" {
+		if value := attributes[config.CASIdentityAttribute]; value != "" {
+			if config.SSOIdentityValues[value] != cred.Identity {
+				return nil, &config.ValidationError{Message: "账户身份与统一身份认证不一致"}
+			}
+			profile.IdentityVerified = true
+		}
+	}
+	return profile, nil
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// testCertificate 为 127.0.0.1 生成自签名证书，返回服务端 TLS 配置和 CA 证书文件路径
+func testCertificate(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake-directory"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, caFile
+}
+
+// fakeDirectory 进程内的模拟 LDAP 目录，支持简单绑定、StartTLS 和 Unbind
+type fakeDirectory struct {
+	users     map[string]string // DN 对应的密码
+	startTLS  *tls.Config       // 为 nil 时不支持 StartTLS
+	wrongID   bool              // 以错误的 messageID 响应绑定请求
+	binds     int64             // 收到的绑定请求数
+	plainText int64             // 以明文收到的绑定请求数
+}
+
+func startDirectory(t *testing.T, d *fakeDirectory, ldaps *tls.Config) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := "ldap"
+	if ldaps != nil {
+		listener, scheme = tls.NewListener(listener, ldaps), "ldaps"
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn, ldaps != nil)
+		}
+	}()
+	return fmt.Sprintf("%s://%s", scheme, listener.Addr())
+}
+
+func ldapResult(id int64, tag ber.Tag, code int64) []byte {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	packet.AppendChild(result)
+	return packet.Bytes()
+}
+
+func (d *fakeDirectory) serve(conn net.Conn, secure bool) {
+	defer func() { conn.Close() }()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			atomic.AddInt64(&d.binds, 1)
+			if !secure {
+				atomic.AddInt64(&d.plainText, 1)
+			}
+			code := int64(ldap.LDAPResultInvalidCredentials)
+			if password, ok := d.users[op.Children[1].Data.String()]; ok && password == op.Children[2].Data.String() {
+				code = ldap.LDAPResultSuccess
+			}
+			if d.wrongID {
+				id++
+			}
+			conn.Write(ldapResult(id, ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationExtendedRequest:
+			if d.startTLS == nil {
+				conn.Write(ldapResult(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError))
+				continue
+			}
+			conn.Write(ldapResult(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess))
+			tlsConn := tls.Server(conn, d.startTLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+// useLDAP 将学生的认证方式配置为 LDAP，测试结束后恢复
+func useLDAP(t *testing.T, url, caFile string, startTLS bool) {
+	t.Helper()
+	providers, ldapURL, ca, start, timeout := config.AuthProviders, config.LDAPURL, config.LDAPCAFile, config.LDAPStartTLS, config.LDAPTimeout
+	t.Cleanup(func() {
+		config.AuthProviders, config.LDAPURL, config.LDAPCAFile, config.LDAPStartTLS, config.LDAPTimeout = providers, ldapURL, ca, start, timeout
+	})
+	config.AuthProviders = map[string]string{"student": "ldap"}
+	config.LDAPURL, config.LDAPCAFile, config.LDAPStartTLS, config.LDAPTimeout = url, caFile, startTLS, 2*time.Second
+}
+
+func ldapLogin(t *testing.T, account, password string) error {
+	t.Helper()
+	provider, err := AuthProviderFor("student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.Authenticate(Credentials{Identity: "student", Account: account, Password: password})
+	return err
+}
+
+var directoryUsers = map[string]string{
+	"uid=20210001,ou=students,dc=example,dc=com": "secret",
+	`uid=a\,b,ou=students,dc=example,dc=com`:     "comma",
+}
+
+func TestLDAPProviderStartTLS(t *testing.T) {
+	serverTLS, caFile := testCertificate(t)
+	d := &fakeDirectory{users: directoryUsers, startTLS: serverTLS}
+	useLDAP(t, startDirectory(t, d, nil), caFile, true)
+
+	if err := ldapLogin(t, "20210001", "secret"); err != nil {
+		t.Fatalf("密码正确应通过: %v", err)
+	}
+	if err := ldapLogin(t, "20210001", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("密码错误应返回 ErrInvalidCredentials，得到 %v", err)
+	}
+	if err := ldapLogin(t, "a,b", "comma"); err != nil {
+		t.Fatalf("账号中的特殊字符应转义: %v", err)
+	}
+	binds := atomic.LoadInt64(&d.binds)
+	if err := ldapLogin(t, "20210001", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("空密码应被拒绝，得到 %v", err)
+	}
+	if atomic.LoadInt64(&d.binds) != binds {
+		t.Fatal("空密码不应发送到目录服务器")
+	}
+	if atomic.LoadInt64(&d.plainText) != 0 {
+		t.Fatal("密码不应以明文发送")
+	}
+}
+
+func TestLDAPProviderLDAPS(t *testing.T) {
+	serverTLS, caFile := testCertificate(t)
+	d := &fakeDirectory{users: directoryUsers}
+	useLDAP(t, startDirectory(t, d, serverTLS), caFile, true)
+
+	if err := ldapLogin(t, "20210001", "secret"); err != nil {
+		t.Fatalf("密码正确应通过: %v", err)
+	}
+	if err := ldapLogin(t, "20210001", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("密码错误应返回 ErrInvalidCredentials，得到 %v", err)
+	}
+}
+
+func TestLDAPProviderRejectsUntrustedCertificate(t *testing.T) {
+	serverTLS, _ := testCertificate(t)
+	d := &fakeDirectory{users: directoryUsers, startTLS: serverTLS}
+	useLDAP(t, startDirectory(t, d, nil), "", true)
+
+	if err := ldapLogin(t, "20210001", "secret"); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("服务器证书不受信任时应报错，得到 %v", err)
+	}
+	if atomic.LoadInt64(&d.binds) != 0 {
+		t.Fatal("证书校验失败时不应发送密码")
+	}
+}
+
+func TestLDAPProviderRequiresStartTLS(t *testing.T) {
+	d := &fakeDirectory{users: directoryUsers}
+	useLDAP(t, startDirectory(t, d, nil), "", true)
+
+	if err := ldapLogin(t, "20210001", "secret"); err == nil {
+		t.Fatal("服务器不支持 StartTLS 时应报错")
+	}
+	if atomic.LoadInt64(&d.binds) != 0 {
+		t.Fatal("StartTLS 失败时不应以明文发送密码")
+	}
+}
+
+func TestLDAPProviderIgnoresMismatchedMessageID(t *testing.T) {
+	serverTLS, caFile := testCertificate(t)
+	d := &fakeDirectory{users: directoryUsers, startTLS: serverTLS, wrongID: true}
+	useLDAP(t, startDirectory(t, d, nil), caFile, true)
+	config.LDAPTimeout = 300 * time.Millisecond
+
+	if err := ldapLogin(t, "20210001", "secret"); err == nil {
+		t.Fatal("messageID 不匹配的响应不能当作绑定成功")
+	}
+}
+
+// startCAS 启动模拟的 CAS 服务器，只接受 service 一致的票据 ST-1，attributes 为返回的用户属性
+func startCAS(t *testing.T, attributes string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cas/p3/serviceValidate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		if r.URL.Query().Get("ticket") != "ST-1" || r.URL.Query().Get("service") != CASService("student") {
+			fmt.Fprint(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationFailure code="INVALID_TICKET">Ticket not recognized</cas:authenticationFailure>
+</cas:serviceResponse>`)
+			return
+		}
+		fmt.Fprintf(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationSuccess>
+    <cas:user>20210001</cas:user>
+    <cas:attributes>%s</cas:attributes>
+  </cas:authenticationSuccess>
+</cas:serviceResponse>`, attributes)
+	}))
+	t.Cleanup(server.Close)
+
+	providers, serverURL, identityAttr := config.AuthProviders, config.CASServerURL, config.CASIdentityAttribute
+	t.Cleanup(func() {
+		config.AuthProviders, config.CASServerURL, config.CASIdentityAttribute = providers, serverURL, identityAttr
+	})
+	config.AuthProviders = map[string]string{"student": "cas"}
+	config.CASServerURL = server.URL + "/cas"
+	config.CASIdentityAttribute = "type"
+}
+
+func casLogin(t *testing.T, ticket string) (*SSOProfile, error) {
+	t.Helper()
+	provider, err := AuthProviderFor("student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.PasswordLogin() {
+		t.Fatal("CAS 不能通过账号密码登录")
+	}
+	return provider.Authenticate(Credentials{Identity: "student", Ticket: ticket, Service: CASService("student")})
+}
+
+func TestCASProvider(t *testing.T) {
+	startCAS(t, `<cas:cn>张三</cas:cn><cas:mail>zs@example.com</cas:mail><cas:type>undergraduate</cas:type>`)
+
+	profile, err := casLogin(t, "ST-1")
+	if err != nil {
+		t.Fatalf("有效票据应通过: %v", err)
+	}
+	if profile.Account != "20210001" || profile.Name != "张三" || profile.Email != "zs@example.com" || !profile.IdentityVerified {
+		t.Fatalf("CAS 属性映射有误: %+v", profile)
+	}
+	if _, err := casLogin(t, "ST-2"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("无效票据应返回 ErrInvalidCredentials，得到 %v", err)
+	}
+	if _, err := casLogin(t, ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("缺少票据应返回 ErrInvalidCredentials，得到 %v", err)
+	}
+}
+
+func TestCASProviderRejectsIdentityMismatch(t *testing.T) {
+	startCAS(t, `<cas:type>faculty</cas:type>`)
+
+	if _, err := casLogin(t, "ST-1"); !isValidationError(err) {
+		t.Fatalf("CAS 返回的用户类型与登录入口不一致时应被拒绝，得到 %v", err)
+	}
+}
+
+func TestOIDCOnlyProviderDisablesPasswordLogin(t *testing.T) {
+	providers := config.AuthProviders
+	defer func() { config.AuthProviders = providers }()
+	config.AuthProviders = map[string]string{"teacher": "oidc"}
+
+	provider, err := AuthProviderFor("teacher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.PasswordLogin() {
+		t.Fatal("oidc 方式不能使用账号密码登录")
+	}
+	if local, _ := AuthProviderFor("student"); !local.PasswordLogin() {
+		t.Fatal("未配置的身份类型应使用本地密码")
+	}
+	config.AuthProviders = map[string]string{"student": "unknown"}
+	if _, err := AuthProviderFor("student"); err == nil {
+		t.Fatal("未知的认证方式应报错")
+	}
+}
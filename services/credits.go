@@ -0,0 +1,188 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+)
+
+// CreditItem 一条获奖记录折算的学分
+type CreditItem struct {
+	RecordID     int     `json:"record_id"`
+	RaceTitle    string  `json:"race_title"`
+	Level        int     `json:"level"`
+	Award        string  `json:"award"`
+	TeamRole     string  `json:"team_role"`
+	AcademicYear int     `json:"academic_year"`
+	RuleSetID    int     `json:"rule_set_id"` // 计算所用的规则版本，0 表示该学年没有适用的规则
+	Credits      float64 `json:"credits"`
+}
+
+// CreditYear 一个学年的学分，超过上限的部分不计入
+type CreditYear struct {
+	AcademicYear int          `json:"academic_year"`
+	Raw          float64      `json:"raw"`     // 封顶前的学分
+	Cap          float64      `json:"cap"`     // 学年上限，0 表示不限
+	Credits      float64      `json:"credits"` // 实得学分
+	Items        []CreditItem `json:"items"`
+}
+
+// StudentCredits 学生的竞赛学分
+type StudentCredits struct {
+	SID   string       `json:"sid"`
+	Name  string       `json:"name"`
+	Grade int          `json:"grade"`
+	Class string       `json:"class"`
+	Total float64      `json:"total"`
+	Years []CreditYear `json:"years"`
+}
+
+// AcademicYear 计算日期所属的学年，如 2025 年 3 月属于 2024 学年
+func AcademicYear(t time.Time) int {
+	if t.Month() < config.AcademicYearStartMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// creditRuleBook 按生效学年升序排列的全部规则版本
+type creditRuleBook []models.CreditRuleSets
+
+func loadCreditRuleBook() (creditRuleBook, error) {
+	var sets []models.CreditRuleSets
+	if err := config.DB.Preload("Rules").Order("effective_year ASC").Find(&sets).Error; err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// ruleSetFor 返回学年适用的规则版本：生效学年不晚于该学年的最新版本
+func (b creditRuleBook) ruleSetFor(year int) *models.CreditRuleSets {
+	var found *models.CreditRuleSets
+	for i := range b {
+		if b[i].EffectiveYear <= year {
+			found = &b[i]
+		}
+	}
+	return found
+}
+
+// creditsFor 匹配规则，团队角色精确匹配优先于通配规则
+func creditsFor(set *models.CreditRuleSets, level int, award, teamRole string) float64 {
+	wildcard := 0.0
+	for _, rule := range set.Rules {
+		if rule.Level != level || rule.Award != award {
+			continue
+		}
+		if rule.TeamRole == teamRole {
+			return rule.Credits
+		}
+		if rule.TeamRole == "*" {
+			wildcard = rule.Credits
+		}
+	}
+	return wildcard
+}
+
+// CalculateCredits 计算学生的竞赛学分，只统计审核通过且填写了获奖等级的记录
+// 获奖时间取比赛截止日期，未填写时取报名时间
+func CalculateCredits(students []models.Students) ([]StudentCredits, error) {
+	book, err := loadCreditRuleBook()
+	if err != nil {
+		return nil, err
+	}
+
+	sids := make([]string, 0, len(students))
+	for _, student := range students {
+		sids = append(sids, student.SID)
+	}
+	var records []models.Records
+	if len(sids) > 0 {
+		err := config.DB.Preload("Race").
+			Where("sid IN ? AND status = ? AND score <> ''", sids, models.RecordStatusApproved).
+			Find(&records).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 学生 -> 学年 -> 学分明细
+	items := make(map[string]map[int][]CreditItem)
+	for _, record := range records {
+		awardTime := record.Race.Enddate
+		if awardTime.IsZero() {
+			awardTime = record.CreateTime
+		}
+		teamRole := record.TeamRole
+		if teamRole == "" {
+			teamRole = models.TeamRoleIndividual
+		}
+		item := CreditItem{
+			RecordID:     record.RecordID,
+			RaceTitle:    record.Race.Title,
+			Level:        record.Race.Level,
+			Award:        record.Score,
+			TeamRole:     teamRole,
+			AcademicYear: AcademicYear(awardTime),
+		}
+		if set := book.ruleSetFor(item.AcademicYear); set != nil {
+			item.RuleSetID = set.ID
+			item.Credits = creditsFor(set, item.Level, item.Award, item.TeamRole)
+		}
+		if items[record.SID] == nil {
+			items[record.SID] = make(map[int][]CreditItem)
+		}
+		items[record.SID][item.AcademicYear] = append(items[record.SID][item.AcademicYear], item)
+	}
+
+	result := make([]StudentCredits, 0, len(students))
+	for _, student := range students {
+		credits := StudentCredits{SID: student.SID, Name: student.Name, Grade: student.Grade, Class: student.Class, Years: []CreditYear{}}
+		var years []int
+		for year := range items[student.SID] {
+			years = append(years, year)
+		}
+		sort.Ints(years)
+		for _, year := range years {
+			creditYear := CreditYear{AcademicYear: year, Items: items[student.SID][year]}
+			for _, item := range creditYear.Items {
+				creditYear.Raw += item.Credits
+			}
+			creditYear.Credits = creditYear.Raw
+			if set := book.ruleSetFor(year); set != nil && set.YearCap > 0 {
+				creditYear.Cap = set.YearCap
+				if creditYear.Credits > set.YearCap {
+					creditYear.Credits = set.YearCap
+				}
+			}
+			credits.Total += creditYear.Credits
+			credits.Years = append(credits.Years, creditYear)
+		}
+		result = append(result, credits)
+	}
+	return result, nil
+}
+
+// CreditsTable 将学分汇总转换为表格，第一行为表头，每个学生每个学年一行
+func CreditsTable(credits []StudentCredits) [][]string {
+	table := [][]string{{"学号", "姓名", "年级", "班级", "学年", "获奖项数", "学分（封顶前）", "学年上限", "实得学分"}}
+	for _, student := range credits {
+		for _, year := range student.Years {
+			table = append(table, []string{
+				student.SID,
+				student.Name,
+				strconv.Itoa(student.Grade),
+				student.Class,
+				strconv.Itoa(year.AcademicYear) + "-" + strconv.Itoa(year.AcademicYear+1),
+				strconv.Itoa(len(year.Items)),
+				strconv.FormatFloat(year.Raw, 'f', -1, 64),
+				strconv.FormatFloat(year.Cap, 'f', -1, 64),
+				strconv.FormatFloat(year.Credits, 'f', -1, 64),
+			})
+		}
+	}
+	return table
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// OrphanFile 未被引用的文件
+type OrphanFile struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	PutTime time.Time `json:"put_time"`
+}
+
+// FileGCReport 孤立文件回收报告
+type FileGCReport struct {
+	DryRun       bool         `json:"dry_run"`
+	GracePeriod  string       `json:"grace_period"`
+	Scanned      int          `json:"scanned"`       // 扫描的文件数，只包含 config.FileGCPrefixes 下的文件
+	Referenced   int          `json:"referenced"`    // 仍被引用的文件数
+	Unmanaged    int          `json:"unmanaged"`     // files 表中没有记录的文件数，不会被回收
+	InGrace      int          `json:"in_grace"`      // 未被引用但仍在保留期内的文件数
+	Orphans      []OrphanFile `json:"orphans"`       // 超过保留期的孤立文件
+	ReclaimBytes int64        `json:"reclaim_bytes"` // 孤立文件占用的空间
+	StaleRecords int64        `json:"stale_records"` // 申请了上传令牌但始终没有上传的文件记录
+}
+
+// CollectOrphanFiles 对比存储中的文件与数据库引用，回收超过保留期的孤立文件
+// 被附件引用或在 files 表中关联了实体的文件视为已引用；只扫描 config.FileGCPrefixes 下的文件，
+// files 表中没有记录的文件不回收；dryRun 为 true 时只生成报告不删除
+func CollectOrphanFiles(dryRun bool) (*FileGCReport, error) {
+	report := &FileGCReport{DryRun: dryRun, GracePeriod: config.FileGCGracePeriod.String()}
+	deadline := time.Now().Add(-config.FileGCGracePeriod)
+
+	referenced := make(map[string]bool)
+	var keys []string
+	if err := config.DB.Model(&models.Attachments{}).Distinct().Pluck("object_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		referenced[key] = true
+	}
+	keys = nil
+	if err := config.DB.Model(&models.Files{}).Where("linked_type <> ''").Pluck("object_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		referenced[key] = true
+	}
+
+	// 只有 files 表中登记过的文件才可能被回收，登记之前上传的旧文件无从判断是否仍被引用
+	managed := make(map[string]bool)
+	keys = nil
+	if err := config.DB.Model(&models.Files{}).Pluck("object_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		managed[key] = true
+	}
+
+	existing := make(map[string]bool)
+	collect := func(key string, info utils.FileInfo) error {
+		report.Scanned++
+		existing[key] = true
+		if referenced[key] {
+			report.Referenced++
+			return nil
+		}
+		if !managed[key] {
+			report.Unmanaged++
+			return nil
+		}
+		putTime := time.Unix(0, info.PutTime*100)
+		if putTime.After(deadline) {
+			report.InGrace++
+			return nil
+		}
+		report.Orphans = append(report.Orphans, OrphanFile{Key: key, Size: info.Fsize, PutTime: putTime})
+		report.ReclaimBytes += info.Fsize
+		return nil
+	}
+	for _, prefix := range config.FileGCPrefixes {
+		if err := utils.FileStorage.List(prefix, collect); err != nil {
+			return nil, err
+		}
+	}
+
+	// 申请过上传令牌但文件从未上传的记录
+	var staleKeys []string
+	var pending []models.Files
+	if err := config.DB.Where("(linked_type = '' OR linked_type IS NULL) AND create_time < ?", deadline).Find(&pending).Error; err != nil {
+		return nil, err
+	}
+	for _, file := range pending {
+		if !existing[file.ObjectKey] && gcManagedPrefix(file.ObjectKey) {
+			staleKeys = append(staleKeys, file.ObjectKey)
+		}
+	}
+	report.StaleRecords = int64(len(staleKeys))
+
+	if dryRun {
+		return report, nil
+	}
+
+	// 分批删除，避免单次批量操作过大
+	const batchSize = 500
+	for start := 0; start < len(report.Orphans); start += batchSize {
+		end := start + batchSize
+		if end > len(report.Orphans) {
+			end = len(report.Orphans)
+		}
+		batch := make([]string, 0, end-start)
+		for _, orphan := range report.Orphans[start:end] {
+			batch = append(batch, orphan.Key)
+		}
+		if err := utils.DeleteFile(batch); err != nil {
+			return nil, err
+		}
+		if err := config.DB.Where("object_key IN ?", batch).Delete(&models.Files{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(staleKeys) > 0 {
+		if err := config.DB.Where("object_key IN ?", staleKeys).Delete(&models.Files{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// gcManagedPrefix 判断对象名是否在回收任务扫描的前缀下
+func gcManagedPrefix(key string) bool {
+	for _, prefix := range config.FileGCPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartFileGC 启动孤立文件定时回收任务
+func StartFileGC() {
+	if !config.FileGCEnabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(config.FileGCInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := CollectOrphanFiles(false)
+			if err != nil {
+				log.Error().Err(err).Msg("孤立文件回收失败")
+				continue
+			}
+			log.Info().
+				Int("scanned", report.Scanned).
+				Int("deleted", len(report.Orphans)).
+				Int64("reclaim_bytes", report.ReclaimBytes).
+				Int64("stale_records", report.StaleRecords).
+				Msg("孤立文件回收完成")
+		}
+	}()
+}
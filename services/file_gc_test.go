@@ -0,0 +1,103 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"competition-server/utils"
+)
+
+// useLocalStorage 使用临时目录作为文件存储，返回存储根目录
+func useLocalStorage(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	storage, err := utils.NewLocalStorage(root, "http://localhost/files", "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.FileStorage
+	utils.FileStorage = storage
+	t.Cleanup(func() { utils.FileStorage = previous })
+	return root
+}
+
+// putObject 写入一个文件并把修改时间设置为 age 之前
+func putObject(t *testing.T, root, key string, age time.Duration) {
+	t.Helper()
+	if err := utils.FileStorage.Put(key, strings.NewReader("data"), 4, ""); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func objectExists(t *testing.T, key string) bool {
+	t.Helper()
+	_, err := utils.GetFileInfo(key)
+	return err == nil
+}
+
+func TestCollectOrphanFilesKeepsUnmanagedFiles(t *testing.T) {
+	testutil.OpenDB(t)
+	root := useLocalStorage(t)
+	expired := config.FileGCGracePeriod + time.Hour
+	old := time.Now().Add(-expired)
+
+	// 登记 files 表之前上传的旧文件
+	putObject(t, root, "legacy/2020/report.pdf", expired)
+	putObject(t, root, "evidence/20210001/legacy.pdf", expired)
+	// 登记过但没有被引用、已超过保留期
+	putObject(t, root, "evidence/20210001/orphan.pdf", expired)
+	config.DB.Create(&models.Files{ObjectKey: "evidence/20210001/orphan.pdf", Owner: "20210001", CreateTime: old})
+	// 被附件引用
+	putObject(t, root, "evidence/20210001/attached.pdf", expired)
+	config.DB.Create(&models.Files{ObjectKey: "evidence/20210001/attached.pdf", Owner: "20210001", CreateTime: old})
+	config.DB.Create(&models.Attachments{OwnerType: models.AttachmentOwnerRecord, OwnerID: 1, ObjectKey: "evidence/20210001/attached.pdf", Uploader: "20210001"})
+	// 关联了证书
+	putObject(t, root, "certificate/C1.png", expired)
+	config.DB.Create(&models.Files{ObjectKey: "certificate/C1.png", Owner: "admin", LinkedType: "certificate", LinkedID: 1, CreateTime: old})
+	// 仍在保留期内
+	putObject(t, root, "image/20210001/new.png", time.Hour)
+	config.DB.Create(&models.Files{ObjectKey: "image/20210001/new.png", Owner: "20210001", CreateTime: time.Now()})
+	// 申请了上传令牌但没有上传
+	config.DB.Create(&models.Files{ObjectKey: "direct/20210001/x_never.pdf", Owner: "20210001", CreateTime: old})
+
+	report, err := CollectOrphanFiles(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scanned != 5 || report.Referenced != 2 || report.Unmanaged != 1 || report.InGrace != 1 || report.StaleRecords != 1 {
+		t.Fatalf("回收报告有误: %+v", report)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0].Key != "evidence/20210001/orphan.pdf" {
+		t.Fatalf("只有登记过且超过保留期的未引用文件应被回收，得到 %+v", report.Orphans)
+	}
+	if !objectExists(t, "evidence/20210001/orphan.pdf") {
+		t.Fatal("dry run 不应删除文件")
+	}
+
+	if _, err := CollectOrphanFiles(false); err != nil {
+		t.Fatal(err)
+	}
+	if objectExists(t, "evidence/20210001/orphan.pdf") {
+		t.Fatal("孤立文件应被删除")
+	}
+	for _, key := range []string{"legacy/2020/report.pdf", "evidence/20210001/legacy.pdf", "evidence/20210001/attached.pdf", "certificate/C1.png", "image/20210001/new.png"} {
+		if !objectExists(t, key) {
+			t.Fatalf("%s 不应被删除", key)
+		}
+	}
+	var remaining []string
+	config.DB.Model(&models.Files{}).Order("object_key").Pluck("object_key", &remaining)
+	if strings.Join(remaining, ",") != "certificate/C1.png,evidence/20210001/attached.pdf,image/20210001/new.png" {
+		t.Fatalf("应删除孤立文件和未上传文件的记录，剩余 %v", remaining)
+	}
+}
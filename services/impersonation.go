@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"gorm.io/gorm"
+)
+
+// StartImpersonation 管理员以 account 的身份创建限时的模拟登录会话，originSession 为管理员当前的会话
+// 不能模拟自己、不能在模拟期间再次模拟，被模拟用户的权限不能超出管理员自身的权限
+func StartImpersonation(operator models.AuthenticatedUser, originSession, account, ip, userAgent string) (*models.UserSessions, *models.User, error) {
+	if operator.ImpersonatedBy != "" {
+		return nil, nil, &config.ValidationError{Message: "模拟登录期间不能再次模拟其他用户"}
+	}
+	if originSession == "" {
+		return nil, nil, &config.ValidationError{Message: "只有登录会话可以模拟用户"}
+	}
+	if account == operator.Account {
+		return nil, nil, &config.ValidationError{Message: "不能模拟自己"}
+	}
+	var user models.User
+	if err := config.DB.Where("account = ?", account).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, &config.ValidationError{Message: "用户不存在"}
+		}
+		return nil, nil, err
+	}
+	permissions, err := RolePermissions(user.RoleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if missing := MissingPermissions(permissions, operator.Permissions); len(missing) > 0 {
+		return nil, nil, &config.ValidationError{Message: "不能模拟权限超出自身的用户，缺少: " + strings.Join(missing, ", ")}
+	}
+
+	origin, err := activeSession(originSession)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 模拟会话不超过管理员原会话的有效期
+	expire := time.Now().Add(config.ImpersonationTTL)
+	if origin.ExpireTime.Before(expire) {
+		expire = origin.ExpireTime
+	}
+	id, err := CreateSession(account, ip, userAgent, expire)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := config.DB.Model(&models.UserSessions{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"impersonated_by": operator.Account,
+		"origin_session":  originSession,
+	}).Error; err != nil {
+		return nil, nil, err
+	}
+	session, err := activeSession(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, &user, nil
+}
+
+// StopImpersonation 结束模拟登录，注销模拟会话并返回管理员的原会话，原会话已失效时返回校验错误
+func StopImpersonation(sessionID string) (*models.UserSessions, error) {
+	var session models.UserSessions
+	if err := config.DB.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &config.ValidationError{Message: "会话不存在"}
+		}
+		return nil, err
+	}
+	if session.ImpersonatedBy == "" {
+		return nil, &config.ValidationError{Message: "当前不是模拟登录"}
+	}
+	if err := config.DB.Model(&models.UserSessions{}).Where("id = ? AND revoked_time IS NULL", sessionID).
+		Update("revoked_time", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return activeSession(session.OriginSession)
+}
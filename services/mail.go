@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// mailTemplate 一种邮件的主题和正文模板
+type mailTemplate struct {
+	Subject string
+	Body    string
+}
+
+// 邮件模板名
+const (
+	MailRecordApproved   = "record_approved"
+	MailRecordRejected   = "record_rejected"
+	MailDeadlineReminder = "deadline_reminder"
+	MailPasswordReset    = "password_reset"
+	MailEmailVerify      = "email_verify"
+)
+
+// mailTemplates 邮件模板，按模板名和语言区分，缺少对应语言时使用中文
+var mailTemplates = map[string]map[string]mailTemplate{
+	MailRecordApproved: {
+		"zh": {"【竞赛管理系统】报名审核通过", `<p>{{.Name}}，您好：</p><p>您报名的「{{.RaceTitle}}」已审核通过。</p>{{if .Description}}<p>审核意见：{{.Description}}</p>{{end}}`},
+		"en": {"[Competition System] Registration approved", `<p>Dear {{.Name}},</p><p>Your registration for "{{.RaceTitle}}" has been approved.</p>{{if .Description}}<p>Reviewer comments: {{.Description}}</p>{{end}}`},
+	},
+	MailRecordRejected: {
+		"zh": {"【竞赛管理系统】报名审核未通过", `<p>{{.Name}}，您好：</p><p>您报名的「{{.RaceTitle}}」未通过审核。</p>{{if .Description}}<p>审核意见：{{.Description}}</p>{{end}}`},
+		"en": {"[Competition System] Registration rejected", `<p>Dear {{.Name}},</p><p>Your registration for "{{.RaceTitle}}" was not approved.</p>{{if .Description}}<p>Reviewer comments: {{.Description}}</p>{{end}}`},
+	},
+	MailDeadlineReminder: {
+		"zh": {"【竞赛管理系统】比赛即将截止", `<p>{{.Name}}，您好：</p><p>您报名的「{{.RaceTitle}}」将于 {{.Deadline}} 截止，请及时完成相关材料。</p>`},
+		"en": {"[Competition System] Deadline approaching", `<p>Dear {{.Name}},</p><p>"{{.RaceTitle}}" closes at {{.Deadline}}. Please complete your submission in time.</p>`},
+	},
+	MailPasswordReset: {
+		"zh": {"【竞赛管理系统】重置密码", `<p>{{.Name}}，您好：</p><p>请在 {{.Expires}} 前点击以下链接重置密码：</p><p><a href="{{.URL}}">{{.URL}}</a></p><p>如果不是您本人操作，请忽略本邮件。</p>`},
+		"en": {"[Competition System] Reset your password", `<p>Dear {{.Name}},</p><p>Use the link below to reset your password before {{.Expires}}:</p><p><a href="{{.URL}}">{{.URL}}</a></p><p>If you did not request this, please ignore this email.</p>`},
+	},
+	MailEmailVerify: {
+		"zh": {"【竞赛管理系统】验证邮箱", `<p>{{.Name}}，您好：</p><p>请在 {{.Expires}} 前点击以下链接验证您的邮箱：</p><p><a href="{{.URL}}">{{.URL}}</a></p>`},
+		"en": {"[Competition System] Verify your email", `<p>Dear {{.Name}},</p><p>Please verify your email address before {{.Expires}}:</p><p><a href="{{.URL}}">{{.URL}}</a></p>`},
+	},
+}
+
+// mailLayout 邮件的公共外框
+var mailLayout = template.Must(template.New("layout").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"></head>
+<body style="font-family: sans-serif; font-size: 14px; color: #222;">
+{{.Body}}
+<hr>
+<p style="font-size: 12px; color: #888;">{{if eq .Lang "en"}}This email was sent automatically, please do not reply.{{else}}本邮件由系统自动发送，请勿回复。{{end}}</p>
+</body>
+</html>
+`))
+
+// RenderMail 按模板名和语言渲染邮件主题和正文
+func RenderMail(name, lang string, data interface{}) (string, string, error) {
+	variants, ok := mailTemplates[name]
+	if !ok {
+		return "", "", &config.ValidationError{Message: "未知的邮件模板: " + name}
+	}
+	if _, ok := variants[lang]; !ok {
+		lang = "zh"
+	}
+	tpl, err := template.New(name).Parse(variants[lang].Body)
+	if err != nil {
+		return "", "", err
+	}
+	var body bytes.Buffer
+	if err := tpl.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+
+	var html bytes.Buffer
+	if err := mailLayout.Execute(&html, struct {
+		Lang string
+		Body template.HTML
+	}{lang, template.HTML(body.String())}); err != nil {
+		return "", "", err
+	}
+	return variants[lang].Subject, html.String(), nil
+}
+
+// EnqueueMail 渲染邮件并加入发送队列，由后台任务发送
+func EnqueueMail(to, name, lang string, data interface{}) error {
+	subject, body, err := RenderMail(name, lang, data)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return config.DB.Create(&models.MailQueue{
+		Recipient:   to,
+		Subject:     subject,
+		Body:        body,
+		Status:      models.MailStatusPending,
+		NextAttempt: now,
+		CreateTime:  now,
+	}).Error
+}
+
+// SendQueuedMails 发送一批到期的邮件，失败的邮件按重试次数退避，超过上限标记为失败
+func SendQueuedMails() error {
+	var mails []models.MailQueue
+	if err := config.DB.Where("status = ? AND next_attempt <= ?", models.MailStatusPending, time.Now()).
+		Order("id ASC").Limit(config.MailQueueBatch).Find(&mails).Error; err != nil {
+		return err
+	}
+
+	for _, mail := range mails {
+		err := utils.MailSender.Send(&utils.MailMessage{To: mail.Recipient, Subject: mail.Subject, HTML: mail.Body})
+		updates := map[string]interface{}{"attempts": mail.Attempts + 1}
+		if err == nil {
+			updates["status"] = models.MailStatusSent
+			updates["sent_time"] = time.Now()
+			updates["last_error"] = ""
+		} else {
+			log.Warn().Err(err).Int("id", mail.ID).Str("to", mail.Recipient).Msg("邮件发送失败")
+			updates["last_error"] = truncate(err.Error(), 1024)
+			if mail.Attempts+1 >= config.MailMaxAttempts {
+				updates["status"] = models.MailStatusFailed
+			} else {
+				// 第 n 次失败后等待 MailRetryInterval * 2^(n-1)
+				updates["next_attempt"] = time.Now().Add(config.MailRetryInterval << mail.Attempts)
+			}
+		}
+		if err := config.DB.Model(&models.MailQueue{}).Where("id = ?", mail.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// StartMailQueue 启动邮件发送任务
+func StartMailQueue() {
+	go func() {
+		ticker := time.NewTicker(config.MailQueueInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SendQueuedMails(); err != nil {
+				log.Error().Err(err).Msg("处理邮件队列失败")
+			}
+		}
+	}()
+}
+
+// mailLang 用户设置的邮件语言，未设置时使用默认语言
+func mailLang(lang string) string {
+	if lang == "" {
+		return config.DefaultMailLang
+	}
+	return lang
+}
+
+// mailRecipient 可以接收邮件的用户
+type mailRecipient struct {
+	Account string
+	Name    string
+	Email   string
+	Lang    string
+}
+
+// verifiedRecipients 查询已验证邮箱的学生和教师
+func verifiedRecipients(accounts []string) (map[string]mailRecipient, error) {
+	result := make(map[string]mailRecipient)
+	if len(accounts) == 0 {
+		return result, nil
+	}
+	var students []models.Students
+	if err := config.DB.Where("sid IN ? AND email_verified = ? AND email <> ''", accounts, true).Find(&students).Error; err != nil {
+		return nil, err
+	}
+	for _, student := range students {
+		result[student.SID] = mailRecipient{student.SID, student.Name, student.Email, mailLang(student.MailLang)}
+	}
+	var teachers []models.Teachers
+	if err := config.DB.Where("tid IN ? AND email_verified = ? AND email <> ''", accounts, true).Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+	for _, teacher := range teachers {
+		result[teacher.TID] = mailRecipient{teacher.TID, teacher.Name, teacher.Email, mailLang(teacher.MailLang)}
+	}
+	return result, nil
+}
+
+// CheckEmailVerifyCooldown 距上次发送验证邮件不足 EmailVerifyCooldown 时返回校验错误
+func CheckEmailVerifyCooldown(account, identity string) error {
+	var count int64
+	if err := config.DB.Model(&models.EmailVerifications{}).
+		Where("account = ? AND identity = ? AND create_time > ?", account, identity, time.Now().Add(-config.EmailVerifyCooldown)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return &config.ValidationError{Message: "验证邮件发送过于频繁，请稍后再试"}
+	}
+	return nil
+}
+
+// SendEmailVerification 发送邮箱验证邮件，链接绑定本次待验证的邮箱，之前未使用的验证链接作废
+// 距上次发送不足 EmailVerifyCooldown 时返回校验错误
+func SendEmailVerification(account, identity, name, email, lang string) error {
+	if err := CheckEmailVerifyCooldown(account, identity); err != nil {
+		return err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+	expires := now.Add(config.EmailVerifyTTL)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailVerifications{}).Where("account = ? AND identity = ? AND used_time IS NULL", account, identity).
+			Update("used_time", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.EmailVerifications{
+			Account:    account,
+			Identity:   identity,
+			Email:      email,
+			TokenHash:  hashToken(token),
+			ExpireTime: expires,
+			CreateTime: now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return EnqueueMail(email, MailEmailVerify, mailLang(lang), map[string]string{
+		"Name":    name,
+		"URL":     config.EmailVerifyURL + token,
+		"Expires": expires.Format("2006-01-02 15:04"),
+	})
+}
+
+// VerifyEmail 使用验证链接中的令牌完成邮箱验证，令牌只能使用一次
+// 令牌绑定发出时的邮箱，账户邮箱已修改为其他地址时验证失败
+func VerifyEmail(token string) error {
+	invalid := &config.ValidationError{Message: "验证链接无效或已过期"}
+	var record models.EmailVerifications
+	if err := config.DB.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid
+		}
+		return err
+	}
+	if record.UsedTime != nil || time.Now().After(record.ExpireTime) {
+		return invalid
+	}
+
+	var model interface{}
+	var key string
+	switch record.Identity {
+	case "student":
+		model, key = &models.Students{}, "sid"
+	case "teacher":
+		model, key = &models.Teachers{}, "tid"
+	default:
+		return invalid
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// 先作废令牌，并发请求中只有一个能成功
+		result := tx.Model(&models.EmailVerifications{}).Where("id = ? AND used_time IS NULL", record.ID).Update("used_time", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalid
+		}
+		result = tx.Model(model).Where(key+" = ? AND email = ?", record.Account, record.Email).Update("email_verified", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalid
+		}
+		return nil
+	})
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Notice 一条待发布的通知，Mail 为邮件模板名，为空时只发送站内通知
+// 邮件模板数据中的 Name 会按接收人姓名自动填写
+type Notice struct {
+	Event      string
+	Title      string
+	Content    string
+	TargetType string
+	TargetID   int
+	Mail       string
+	MailData   map[string]string
+}
+
+// Publish 向接收人发布通知，按用户偏好分别发送站内通知和邮件，邮件只发送给已验证的邮箱
+// 通知发布失败不影响业务请求，只记录错误日志
+func Publish(notice Notice, recipients []string) {
+	recipients = uniqueAccounts(recipients)
+	if len(recipients) == 0 {
+		return
+	}
+
+	var preferences []models.NotificationPreferences
+	if err := config.DB.Where("event = ? AND account IN ?", notice.Event, recipients).Find(&preferences).Error; err != nil {
+		log.Error().Err(err).Str("event", notice.Event).Msg("查询通知偏好失败")
+		return
+	}
+	saved := make(map[string]models.NotificationPreferences)
+	for _, preference := range preferences {
+		saved[preference.Account] = preference
+	}
+
+	now := time.Now()
+	var notifications []models.Notifications
+	var mailAccounts []string
+	for _, account := range recipients {
+		preference, ok := saved[account]
+		if !ok || preference.InApp {
+			notifications = append(notifications, models.Notifications{
+				Recipient:  account,
+				Event:      notice.Event,
+				Title:      notice.Title,
+				Content:    notice.Content,
+				TargetType: notice.TargetType,
+				TargetID:   notice.TargetID,
+				CreateTime: now,
+			})
+		}
+		if !ok || preference.Email {
+			mailAccounts = append(mailAccounts, account)
+		}
+	}
+	if len(notifications) > 0 {
+		if err := config.DB.CreateInBatches(&notifications, 500).Error; err != nil {
+			log.Error().Err(err).Str("event", notice.Event).Msg("发布通知失败")
+		}
+	}
+
+	if notice.Mail == "" || len(mailAccounts) == 0 {
+		return
+	}
+	mailRecipients, err := verifiedRecipients(mailAccounts)
+	if err != nil {
+		log.Error().Err(err).Str("event", notice.Event).Msg("查询邮件接收人失败")
+		return
+	}
+	for _, recipient := range mailRecipients {
+		data := map[string]string{"Name": recipient.Name}
+		for key, value := range notice.MailData {
+			data[key] = value
+		}
+		if err := EnqueueMail(recipient.Email, notice.Mail, recipient.Lang, data); err != nil {
+			log.Error().Err(err).Str("event", notice.Event).Str("to", recipient.Email).Msg("邮件加入队列失败")
+		}
+	}
+}
+
+func uniqueAccounts(accounts []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		if account == "" || seen[account] {
+			continue
+		}
+		seen[account] = true
+		result = append(result, account)
+	}
+	return result
+}
+
+// NotifyRecordStatus 通知学生参赛记录的审核结果
+func NotifyRecordStatus(record models.Records, raceTitle string) {
+	notice := Notice{
+		Event:      models.NotifyRecordStatus,
+		Title:      "参赛记录审核结果",
+		TargetType: "record",
+		TargetID:   record.RecordID,
+		MailData:   map[string]string{"RaceTitle": raceTitle, "Description": record.Description},
+	}
+	switch record.Status {
+	case models.RecordStatusApproved:
+		notice.Content = fmt.Sprintf("您报名的「%s」已审核通过。", raceTitle)
+		notice.Mail = MailRecordApproved
+	case models.RecordStatusRejected:
+		notice.Content = fmt.Sprintf("您报名的「%s」未通过审核。", raceTitle)
+		notice.Mail = MailRecordRejected
+	default:
+		notice.Content = fmt.Sprintf("您报名的「%s」已重新进入待审核状态。", raceTitle)
+	}
+	if record.Description != "" {
+		notice.Content += "审核意见：" + record.Description
+	}
+	Publish(notice, []string{record.SID})
+}
+
+// NotifyAdvisorRequest 通知教师有学生选择其为指导教师
+func NotifyAdvisorRequest(record models.Records, raceTitle, studentName string) {
+	content := fmt.Sprintf("学生%s（%s）报名「%s」时选择您作为指导教师。", studentName, record.SID, raceTitle)
+	Publish(Notice{Event: models.NotifyAdvisorRequest, Title: "指导教师申请", Content: content, TargetType: "record", TargetID: record.RecordID}, []string{record.TID})
+}
+
+// NotifyRacePublished 通知全部学生有新比赛发布
+func NotifyRacePublished(race models.Races) {
+	var sids []string
+	if err := config.DB.Model(&models.Students{}).Pluck("sid", &sids).Error; err != nil {
+		log.Error().Err(err).Msg("查询学生失败")
+		return
+	}
+	content := fmt.Sprintf("新比赛「%s」已发布", race.Title)
+	if !race.Enddate.IsZero() {
+		content += "，截止日期 " + race.Enddate.Format("2006-01-02")
+	}
+	Publish(Notice{Event: models.NotifyRacePublished, Title: "新比赛发布", Content: content + "。", TargetType: "race", TargetID: race.RaceID}, sids)
+}
+
+// SendDeadlineReminders 提醒已报名的学生比赛即将截止，每场比赛只提醒一次
+func SendDeadlineReminders() error {
+	now := time.Now()
+	var races []models.Races
+	if err := config.DB.Where("enddate > ? AND enddate <= ? AND deadline_reminded = ?", now, now.Add(config.DeadlineReminderAhead), false).
+		Find(&races).Error; err != nil {
+		return err
+	}
+
+	for _, race := range races {
+		var sids []string
+		if err := config.DB.Model(&models.Records{}).Where("race_id = ?", race.RaceID).Pluck("sid", &sids).Error; err != nil {
+			return err
+		}
+		deadline := race.Enddate.Format("2006-01-02 15:04")
+		Publish(Notice{
+			Event:      models.NotifyDeadlineReminder,
+			Title:      "比赛即将截止",
+			Content:    fmt.Sprintf("您报名的「%s」将于 %s 截止，请及时完成相关材料。", race.Title, deadline),
+			TargetType: "race",
+			TargetID:   race.RaceID,
+			Mail:       MailDeadlineReminder,
+			MailData:   map[string]string{"RaceTitle": race.Title, "Deadline": deadline},
+		}, sids)
+		if err := config.DB.Model(&models.Races{}).Where("race_id = ?", race.RaceID).Update("deadline_reminded", true).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartDeadlineReminder 启动比赛截止提醒定时任务
+func StartDeadlineReminder() {
+	if !config.DeadlineReminderEnabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(config.DeadlineReminderInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SendDeadlineReminders(); err != nil {
+				log.Error().Err(err).Msg("发送截止提醒失败")
+			}
+		}
+	}()
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+func TestPublishFollowsPreferences(t *testing.T) {
+	testutil.OpenDB(t)
+	sex := 1
+	for _, sid := range []string{"default", "mail_only", "in_app_only", "muted"} {
+		config.DB.Create(&models.Students{SID: sid, Name: sid, Password: "x", Sex: &sex, Grade: 2021, Class: "1", Email: sid + "@example.com", EmailVerified: true})
+	}
+	config.DB.Create(&models.NotificationPreferences{Account: "mail_only", Event: models.NotifyRecordStatus, InApp: false, Email: true})
+	config.DB.Create(&models.NotificationPreferences{Account: "in_app_only", Event: models.NotifyRecordStatus, InApp: true, Email: false})
+	config.DB.Create(&models.NotificationPreferences{Account: "muted", Event: models.NotifyRecordStatus, InApp: false, Email: false})
+	// 其他事件的偏好不影响本次通知
+	config.DB.Create(&models.NotificationPreferences{Account: "default", Event: models.NotifyRacePublished, InApp: false, Email: false})
+
+	for i, sid := range []string{"default", "mail_only", "in_app_only", "muted"} {
+		NotifyRecordStatus(models.Records{RecordID: i + 1, SID: sid, Status: models.RecordStatusApproved}, "程序设计竞赛")
+	}
+
+	cases := []struct {
+		account       string
+		inApp, mailed bool
+	}{
+		{"default", true, true},
+		{"mail_only", false, true},
+		{"in_app_only", true, false},
+		{"muted", false, false},
+	}
+	for _, tc := range cases {
+		var notifications, mails int64
+		config.DB.Model(&models.Notifications{}).Where("recipient = ?", tc.account).Count(&notifications)
+		config.DB.Model(&models.MailQueue{}).Where("recipient = ?", tc.account+"@example.com").Count(&mails)
+		if (notifications == 1) != tc.inApp || (mails == 1) != tc.mailed {
+			t.Errorf("%s: 期望站内通知 %v 邮件 %v，得到 %d 条通知 %d 封邮件", tc.account, tc.inApp, tc.mailed, notifications, mails)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"competition-server/config"
+	"competition-server/models"
+	"gorm.io/gorm"
+)
+
+// LookupClass 查询班级，不存在时返回校验错误
+func LookupClass(id int) (*models.Classes, error) {
+	var class models.Classes
+	if err := config.DB.Where("id = ?", id).First(&class).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &config.ValidationError{Message: "班级不存在"}
+		}
+		return nil, err
+	}
+	return &class, nil
+}
+
+// CheckCollege 校验学院存在
+func CheckCollege(id int) error {
+	var count int64
+	if err := config.DB.Model(&models.Colleges{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &config.ValidationError{Message: "学院不存在"}
+	}
+	return nil
+}
+
+// ClassIDByName 按班级名称查找班级，名称按 NormalizeClassName 规范化后比较，找不到时返回 nil
+func ClassIDByName(name string) *int {
+	name = NormalizeClassName(name)
+	if name == "" {
+		return nil
+	}
+	var class models.Classes
+	if err := config.DB.Where("name = ?", name).First(&class).Error; err != nil {
+		return nil
+	}
+	return &class.ID
+}
+
+// NormalizeClassName 去掉空白和末尾的“班”，使“软件工程2101班”和“软件工程 2101”对应同一个班级
+func NormalizeClassName(name string) string {
+	name = strings.Join(strings.Fields(name), "")
+	return strings.TrimSuffix(name, "班")
+}
+
+// classMajorPattern 班级名称为“专业名称 + 数字编号”，如“软件工程2101”
+var classMajorPattern = regexp.MustCompile(`^(\D+?)(?:专业)?(\d+)$`)
+
+// OrgMigrationOptions 将学生信息中的班级名称迁移为学院、专业和班级
+type OrgMigrationOptions struct {
+	DryRun         bool              `json:"dry_run"`         // 只生成报告，不写入数据库
+	MajorColleges  map[string]string `json:"major_colleges"`  // 专业名称对应的学院名称
+	ClassMajors    map[string]string `json:"class_majors"`    // 班级名称对应的专业名称，用于名称中没有专业的班级，如“1709”
+	DefaultCollege string            `json:"default_college"` // 未在 MajorColleges 中指定学院的专业归入该学院，为空时记为冲突
+}
+
+// OrgConflict 迁移时无法处理或需要人工确认的班级，Skipped 为 true 表示该班级的学生未关联到班级
+type OrgConflict struct {
+	Class    string `json:"class"`
+	Students int64  `json:"students"`
+	Reason   string `json:"reason"`
+	Skipped  bool   `json:"skipped"`
+}
+
+// OrgMigrationReport 迁移结果，Merged 为规范化后合并为同一班级的不同写法
+type OrgMigrationReport struct {
+	DryRun    bool                `json:"dry_run"`
+	Colleges  int                 `json:"colleges"`
+	Majors    int                 `json:"majors"`
+	Classes   int                 `json:"classes"`
+	Students  int64               `json:"students"`
+	Merged    map[string][]string `json:"merged"`
+	Conflicts []OrgConflict       `json:"conflicts"`
+}
+
+// errDryRun 试运行时用于回滚事务
+var errDryRun = errors.New("dry run")
+
+// classGroup 规范化后名称相同的一组班级写法
+type classGroup struct {
+	name     string
+	variants []string
+	students int64
+	grades   map[int]int64
+}
+
+// MigrateClasses 解析尚未关联班级的学生的班级名称，创建对应的学院、专业和班级并关联学生，可重复执行
+// 班级已存在时直接关联；无法确定专业或学院的班级记入冲突报告，这些学生保持未关联
+func MigrateClasses(opts OrgMigrationOptions) (*OrgMigrationReport, error) {
+	report := &OrgMigrationReport{DryRun: opts.DryRun, Merged: map[string][]string{}, Conflicts: []OrgConflict{}}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			Class    string
+			Grade    int
+			Students int64
+		}
+		if err := tx.Unscoped().Model(&models.Students{}).
+			Select("class, grade, COUNT(*) AS students").
+			Where("class_id IS NULL").
+			Group("class").Group("grade").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		groups := make(map[string]*classGroup)
+		for _, row := range rows {
+			name := NormalizeClassName(row.Class)
+			if name == "" {
+				report.Conflicts = append(report.Conflicts, OrgConflict{Class: row.Class, Students: row.Students, Reason: "未填写班级", Skipped: true})
+				continue
+			}
+			group, ok := groups[name]
+			if !ok {
+				group = &classGroup{name: name, grades: map[int]int64{}}
+				groups[name] = group
+			}
+			if !containsString(group.variants, row.Class) {
+				group.variants = append(group.variants, row.Class)
+			}
+			group.students += row.Students
+			group.grades[row.Grade] += row.Students
+		}
+
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := migrateClassGroup(tx, groups[name], opts, report); err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// migrateClassGroup 为一组班级写法找到或创建班级，并关联这些学生
+func migrateClassGroup(tx *gorm.DB, group *classGroup, opts OrgMigrationOptions, report *OrgMigrationReport) error {
+	conflict := func(reason string, skipped bool) {
+		report.Conflicts = append(report.Conflicts, OrgConflict{Class: strings.Join(group.variants, "、"), Students: group.students, Reason: reason, Skipped: skipped})
+	}
+
+	// 班级内学生年级不一致时取人数最多的年级，并提示人工确认
+	grade, most := 0, int64(-1)
+	for g, count := range group.grades {
+		if count > most || (count == most && g < grade) {
+			grade, most = g, count
+		}
+	}
+	if len(group.grades) > 1 {
+		conflict(fmt.Sprintf("班级内学生年级不一致，已按人数最多的年级 %d 创建班级", grade), false)
+	}
+
+	majorName := opts.ClassMajors[group.name]
+	for _, variant := range group.variants {
+		if majorName == "" {
+			majorName = opts.ClassMajors[variant]
+		}
+	}
+	if majorName == "" {
+		if match := classMajorPattern.FindStringSubmatch(group.name); match != nil {
+			majorName = strings.TrimSpace(match[1])
+		}
+	}
+
+	var class models.Classes
+	err := tx.Where("name = ?", group.name).First(&class).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil {
+		// 班级已存在：专业一致或无法识别专业时直接关联
+		if majorName != "" {
+			var major models.Majors
+			if err := tx.Where("id = ?", class.MajorID).First(&major).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if major.Name != majorName {
+				conflict(fmt.Sprintf("班级已存在且属于专业“%s”，与识别出的专业“%s”不一致", major.Name, majorName), true)
+				return nil
+			}
+		}
+	} else {
+		if majorName == "" {
+			conflict("无法从班级名称识别专业，请在 class_majors 中指定", true)
+			return nil
+		}
+		major, err := findOrCreateMajor(tx, majorName, opts, report)
+		if err != nil {
+			var validationErr *config.ValidationError
+			if errors.As(err, &validationErr) {
+				conflict(validationErr.Message, true)
+				return nil
+			}
+			return err
+		}
+		class = models.Classes{MajorID: major.ID, Name: group.name, Grade: grade}
+		if err := tx.Create(&class).Error; err != nil {
+			return err
+		}
+		report.Classes++
+	}
+
+	if len(group.variants) > 1 || group.variants[0] != group.name {
+		report.Merged[group.name] = group.variants
+	}
+	result := tx.Unscoped().Model(&models.Students{}).
+		Where("class IN ? AND class_id IS NULL", group.variants).
+		Updates(map[string]interface{}{"class_id": class.ID, "class": class.Name})
+	if result.Error != nil {
+		return result.Error
+	}
+	report.Students += result.RowsAffected
+	return nil
+}
+
+// findOrCreateMajor 按专业名称找到所属学院下的专业，不存在时创建学院和专业
+func findOrCreateMajor(tx *gorm.DB, name string, opts OrgMigrationOptions, report *OrgMigrationReport) (*models.Majors, error) {
+	collegeName := opts.MajorColleges[name]
+	if collegeName == "" {
+		// 未指定学院时，只有一个同名专业则直接使用
+		var majors []models.Majors
+		if err := tx.Where("name = ?", name).Find(&majors).Error; err != nil {
+			return nil, err
+		}
+		if len(majors) == 1 {
+			return &majors[0], nil
+		}
+		if len(majors) > 1 {
+			return nil, &config.ValidationError{Message: fmt.Sprintf("多个学院都有专业“%s”，请在 major_colleges 中指定学院", name)}
+		}
+		collegeName = opts.DefaultCollege
+	}
+	if collegeName == "" {
+		return nil, &config.ValidationError{Message: fmt.Sprintf("专业“%s”未指定学院，请在 major_colleges 中指定或设置 default_college", name)}
+	}
+
+	var college models.Colleges
+	err := tx.Where("name = ?", collegeName).First(&college).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		college = models.Colleges{Name: collegeName}
+		if err = tx.Create(&college).Error; err == nil {
+			report.Colleges++
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var major models.Majors
+	err = tx.Where("college_id = ? AND name = ?", college.ID, name).First(&major).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		major = models.Majors{CollegeID: college.ID, Name: name}
+		if err = tx.Create(&major).Error; err == nil {
+			report.Majors++
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &major, nil
+}
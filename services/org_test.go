@@ -0,0 +1,104 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+func TestMigrateClassesReportsConflicts(t *testing.T) {
+	testutil.OpenDB(t)
+	students := []struct {
+		sid, class string
+		grade      int
+	}{
+		{"1", "软件工程2101班", 2021},
+		{"2", "软件工程 2101", 2021},
+		{"3", "软件工程2102", 2021},
+		{"4", "软件工程2102", 2021},
+		{"5", "软件工程2102", 2020},
+		{"6", "计算机2001", 2020},
+		{"7", "1709", 2017},
+		{"8", "", 2019},
+		{"9", "通信2201", 2022},
+	}
+	sex := 1
+	for _, s := range students {
+		if err := config.DB.Create(&models.Students{SID: s.sid, Name: "学生" + s.sid, Password: "x", Sex: &sex, Class: s.class, Grade: s.grade}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 已有的班级属于其他专业
+	config.DB.Create(&models.Colleges{ID: 1, Name: "电子学院"})
+	config.DB.Create(&models.Majors{ID: 1, CollegeID: 1, Name: "电子信息"})
+	config.DB.Create(&models.Classes{ID: 1, MajorID: 1, Name: "通信2201", Grade: 2022})
+
+	opts := OrgMigrationOptions{MajorColleges: map[string]string{"软件工程": "软件学院"}}
+	check := func(report *OrgMigrationReport) {
+		t.Helper()
+		if report.Colleges != 1 || report.Majors != 1 || report.Classes != 2 || report.Students != 5 {
+			t.Fatalf("应创建 1 个学院、1 个专业、2 个班级并关联 5 名学生，得到 %+v", report)
+		}
+		if variants := report.Merged["软件工程2101"]; len(variants) != 2 {
+			t.Fatalf("不同写法的班级应合并，得到 %v", report.Merged)
+		}
+		want := map[string]struct {
+			reason  string
+			skipped bool
+		}{
+			"软件工程2102": {"年级不一致", false},
+			"计算机2001":  {"未指定学院", true},
+			"1709":     {"无法从班级名称识别专业", true},
+			"":         {"未填写班级", true},
+			"通信2201":   {"不一致", true},
+		}
+		if len(report.Conflicts) != len(want) {
+			t.Fatalf("应报告 %d 个冲突，得到 %+v", len(want), report.Conflicts)
+		}
+		for _, conflict := range report.Conflicts {
+			expected, ok := want[conflict.Class]
+			if !ok || !strings.Contains(conflict.Reason, expected.reason) || conflict.Skipped != expected.skipped {
+				t.Fatalf("冲突报告有误: %+v", conflict)
+			}
+		}
+	}
+
+	// 试运行只生成报告
+	opts.DryRun = true
+	report, err := MigrateClasses(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(report)
+	var count int64
+	config.DB.Model(&models.Classes{}).Count(&count)
+	if count != 1 {
+		t.Fatal("试运行不应写入数据库")
+	}
+
+	opts.DryRun = false
+	report, err = MigrateClasses(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(report)
+	var class models.Classes
+	if err := config.DB.Where("name = ?", "软件工程2102").First(&class).Error; err != nil || class.Grade != 2021 {
+		t.Fatalf("年级不一致时应按人数最多的年级创建班级: %+v, %v", class, err)
+	}
+	var student models.Students
+	config.DB.Where("sid = ?", "2").First(&student)
+	if student.ClassID == nil || student.Class != "软件工程2101" {
+		t.Fatalf("学生应关联到规范化后的班级: %+v", student)
+	}
+	for _, sid := range []string{"6", "7", "8", "9"} {
+		var skipped models.Students
+		config.DB.Where("sid = ?", sid).First(&skipped)
+		if skipped.ClassID != nil {
+			t.Fatalf("冲突跳过的学生 %s 不应关联班级", sid)
+		}
+	}
+}
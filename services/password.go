@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// commonPasswords 内置的常见弱密码
+var commonPasswords = []string{
+	"123456", "1234567", "12345678", "123456789", "1234567890", "111111", "000000", "888888", "666666",
+	"123123", "654321", "112233", "abc123", "abcd1234", "a1234567", "a12345678", "aa123456", "qwe123",
+	"qwerty", "qwerty123", "qwe123456", "1q2w3e4r", "1qaz2wsx", "zxcvbnm", "asdfghjkl", "password",
+	"password1", "password123", "passw0rd", "p@ssw0rd", "admin", "admin123", "admin888", "root",
+	"iloveyou", "woaini1314", "5201314", "welcome", "welcome1", "letmein", "test1234",
+}
+
+var (
+	commonPasswordOnce sync.Once
+	commonPasswordSet  map[string]bool
+)
+
+// isCommonPassword 检查密码是否在弱密码列表中，不区分大小写
+func isCommonPassword(password string) bool {
+	commonPasswordOnce.Do(func() {
+		commonPasswordSet = make(map[string]bool)
+		for _, p := range commonPasswords {
+			commonPasswordSet[p] = true
+		}
+		if config.CommonPasswordFile == "" {
+			return
+		}
+		data, err := os.ReadFile(config.CommonPasswordFile)
+		if err != nil {
+			log.Error().Err(err).Str("file", config.CommonPasswordFile).Msg("读取弱密码列表失败")
+			return
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				commonPasswordSet[strings.ToLower(line)] = true
+			}
+		}
+	})
+	return commonPasswordSet[strings.ToLower(password)]
+}
+
+// ValidatePassword 检查密码是否满足密码策略
+func ValidatePassword(account, password string) error {
+	if len([]rune(password)) < config.PasswordMinLength {
+		return &config.ValidationError{Message: fmt.Sprintf("密码长度不能少于%d位", config.PasswordMinLength)}
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsSpace(r):
+			return &config.ValidationError{Message: "密码不能包含空白字符"}
+		default:
+			symbol = true
+		}
+	}
+	switch {
+	case config.PasswordRequireLetter && !upper && !lower:
+		return &config.ValidationError{Message: "密码必须包含字母"}
+	case config.PasswordRequireUpper && !upper:
+		return &config.ValidationError{Message: "密码必须包含大写字母"}
+	case config.PasswordRequireLower && !lower:
+		return &config.ValidationError{Message: "密码必须包含小写字母"}
+	case config.PasswordRequireDigit && !digit:
+		return &config.ValidationError{Message: "密码必须包含数字"}
+	case config.PasswordRequireSymbol && !symbol:
+		return &config.ValidationError{Message: "密码必须包含特殊字符"}
+	}
+	if strings.EqualFold(password, account) {
+		return &config.ValidationError{Message: "密码不能与账号相同"}
+	}
+	if isCommonPassword(password) {
+		return &config.ValidationError{Message: "密码过于简单，请更换"}
+	}
+	return nil
+}
+
+// SetPassword 更新账户密码，同时更新学生或教师表中的密码
+// 修改时间之前签发的登录令牌及全部会话随之失效，mustChange 为 true 时用户下次登录后必须修改密码
+// db 可以是外层事务，与调用方的其他修改一起提交或回滚
+func SetPassword(db *gorm.DB, account, password string, mustChange bool) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	// 数据库时间精度为秒，截断后与令牌签发时间比较
+	now := time.Now().Truncate(time.Second)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("account = ?", account).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &config.ValidationError{Message: "用户不存在"}
+			}
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("account = ?", account).Updates(map[string]interface{}{
+			"password":             string(hashed),
+			"must_change_password": mustChange,
+			"password_changed_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+		if _, err := RevokeSessions(tx, account, ""); err != nil {
+			return err
+		}
+		switch user.Identity {
+		case "student":
+			return tx.Model(&models.Students{}).Where("sid = ?", account).Update("password", string(hashed)).Error
+		case "teacher":
+			return tx.Model(&models.Teachers{}).Where("tid = ?", account).Update("password", string(hashed)).Error
+		}
+		return nil
+	})
+}
+
+// TempPassword 生成满足密码策略的随机临时密码
+func TempPassword() (string, error) {
+	// 去掉容易混淆的 0/O、1/l/I
+	const charset = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!@#$%^&*"
+	length := config.TempPasswordLength
+	if length < config.PasswordMinLength {
+		length = config.PasswordMinLength
+	}
+	for {
+		password := make([]byte, length)
+		for i := range password {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", err
+			}
+			password[i] = charset[n.Int64()]
+		}
+		// 随机结果缺少策略要求的字符类别时重新生成
+		if ValidatePassword("", string(password)) == nil {
+			return string(password), nil
+		}
+	}
+}
+
+// hashToken 找回密码和邮箱验证的令牌只保存 SHA-256 摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestPasswordReset 申请找回密码，账号与已验证的邮箱匹配时发送重置链接
+// 账号或邮箱不匹配时不返回错误，避免泄露账号是否存在
+func RequestPasswordReset(account, email string) error {
+	var user models.User
+	if err := config.DB.Where("account = ?", account).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	recipients, err := verifiedRecipients([]string{account})
+	if err != nil {
+		return err
+	}
+	recipient, ok := recipients[account]
+	if !ok || !strings.EqualFold(strings.TrimSpace(email), recipient.Email) {
+		return nil
+	}
+
+	now := time.Now()
+	var count int64
+	if err := config.DB.Model(&models.PasswordResetTokens{}).
+		Where("account = ? AND create_time > ?", account, now.Add(-config.PasswordResetCooldown)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	expires := now.Add(config.PasswordResetTTL)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// 新链接生成后，之前未使用的链接作废
+		if err := tx.Model(&models.PasswordResetTokens{}).Where("account = ? AND used_time IS NULL", account).
+			Update("used_time", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetTokens{
+			Account:    account,
+			TokenHash:  hashToken(token),
+			ExpireTime: expires,
+			CreateTime: now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return EnqueueMail(recipient.Email, MailPasswordReset, recipient.Lang, map[string]string{
+		"Name":    recipient.Name,
+		"URL":     config.PasswordResetURL + token,
+		"Expires": expires.Format("2006-01-02 15:04"),
+	})
+}
+
+// ResetPasswordByToken 使用重置链接中的令牌设置新密码，令牌只能使用一次
+func ResetPasswordByToken(token, password string) error {
+	invalid := &config.ValidationError{Message: "重置链接无效或已过期"}
+	var record models.PasswordResetTokens
+	if err := config.DB.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid
+		}
+		return err
+	}
+	if record.UsedTime != nil || time.Now().After(record.ExpireTime) {
+		return invalid
+	}
+	if err := ValidatePassword(record.Account, password); err != nil {
+		return err
+	}
+
+	// 标记令牌已使用和修改密码在同一事务中，并发请求中只有一个能成功，修改失败时令牌仍可使用
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordResetTokens{}).Where("id = ? AND used_time IS NULL", record.ID).Update("used_time", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalid
+		}
+		return SetPassword(tx, record.Account, password, false)
+	})
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// requestResetToken 申请找回密码，从待发送的邮件中取出重置令牌
+func requestResetToken(t *testing.T, account, email string) string {
+	t.Helper()
+	if err := RequestPasswordReset(account, email); err != nil {
+		t.Fatal(err)
+	}
+	var mail models.MailQueue
+	if err := config.DB.Where("recipient = ?", email).Order("id DESC").First(&mail).Error; err != nil {
+		t.Fatalf("应发送重置邮件: %v", err)
+	}
+	match := resetTokenPattern.FindStringSubmatch(mail.Body)
+	if match == nil {
+		t.Fatalf("邮件中没有重置链接: %s", mail.Body)
+	}
+	return match[1]
+}
+
+func seedResetUser(t *testing.T) {
+	t.Helper()
+	testutil.CreateUser(t, "20210001", "student", 3, "Old-Passw0rd")
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "1", Email: "zs@example.com", EmailVerified: true})
+}
+
+func resetTokenUsed(t *testing.T) bool {
+	t.Helper()
+	var record models.PasswordResetTokens
+	if err := config.DB.Where("account = ?", "20210001").Order("id DESC").First(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	return record.UsedTime != nil
+}
+
+func TestResetPasswordByToken(t *testing.T) {
+	testutil.OpenDB(t)
+	seedResetUser(t)
+	if _, err := CreateSession("20210001", "127.0.0.1", "test", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	token := requestResetToken(t, "20210001", "zs@example.com")
+
+	if err := ResetPasswordByToken(token, "weak"); !isValidationError(err) {
+		t.Fatalf("不符合密码策略应被拒绝，得到 %v", err)
+	}
+	if resetTokenUsed(t) {
+		t.Fatal("修改失败时令牌不应作废")
+	}
+
+	if err := ResetPasswordByToken(token, "New-Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	var user models.User
+	config.DB.Where("account = ?", "20210001").First(&user)
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("New-Passw0rd")) != nil {
+		t.Fatal("密码应已修改")
+	}
+	if sessions, _ := ListSessions("20210001", ""); len(sessions) != 0 {
+		t.Fatal("修改密码后应注销全部会话")
+	}
+	if err := ResetPasswordByToken(token, "Other-Passw0rd"); !isValidationError(err) {
+		t.Fatalf("重置链接只能使用一次，得到 %v", err)
+	}
+}
+
+func TestResetPasswordByTokenRollsBackOnFailure(t *testing.T) {
+	testutil.OpenDB(t)
+	seedResetUser(t)
+	token := requestResetToken(t, "20210001", "zs@example.com")
+
+	// 修改密码失败时，令牌的使用标记随事务回滚，链接仍然可用
+	config.DB.Where("account = ?", "20210001").Delete(&models.User{})
+	if err := ResetPasswordByToken(token, "New-Passw0rd"); err == nil {
+		t.Fatal("用户不存在时应失败")
+	}
+	if resetTokenUsed(t) {
+		t.Fatal("修改密码失败时令牌不应被标记为已使用")
+	}
+}
+
+func TestValidatePasswordPolicy(t *testing.T) {
+	upper, symbol := config.PasswordRequireUpper, config.PasswordRequireSymbol
+	t.Cleanup(func() { config.PasswordRequireUpper, config.PasswordRequireSymbol = upper, symbol })
+
+	cases := []struct {
+		name, account, password string
+		ok                      bool
+	}{
+		{"满足默认策略", "20210001", "Good-Passw0rd", true},
+		{"长度不足", "20210001", "a1b2c3", false},
+		{"缺少数字", "20210001", "onlyletters", false},
+		{"缺少字母", "20210001", "1234567890123", false},
+		{"包含空白字符", "20210001", "pass word1", false},
+		{"与账号相同", "abc12345", "ABC12345", false},
+		{"常见弱密码", "20210001", "Password123", false},
+	}
+	for _, tc := range cases {
+		if err := ValidatePassword(tc.account, tc.password); (err == nil) != tc.ok {
+			t.Errorf("%s: 期望通过 %v，得到 %v", tc.name, tc.ok, err)
+		} else if err != nil && !isValidationError(err) {
+			t.Errorf("%s: 应返回校验错误，得到 %v", tc.name, err)
+		}
+	}
+
+	// 开启大写字母和特殊字符要求后，临时密码仍满足策略
+	config.PasswordRequireUpper, config.PasswordRequireSymbol = true, true
+	if err := ValidatePassword("20210001", "good-passw0rd"); err == nil {
+		t.Fatal("要求大写字母时应拒绝全小写的密码")
+	}
+	if err := ValidatePassword("20210001", "GoodPassw0rd"); err == nil {
+		t.Fatal("要求特殊字符时应拒绝不含特殊字符的密码")
+	}
+	for i := 0; i < 20; i++ {
+		password, err := TempPassword()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidatePassword("", password); err != nil {
+			t.Fatalf("临时密码 %q 应满足密码策略: %v", password, err)
+		}
+	}
+}
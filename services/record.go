@@ -0,0 +1,22 @@
+package services
+
+import (
+	"competition-server/config"
+	"competition-server/models"
+)
+
+// CheckRecordEvidence 比赛要求提供证明材料时，没有附件的参赛记录不能审核通过
+// 审核和恢复历史版本都要经过此校验
+func CheckRecordEvidence(record models.Records, status int) error {
+	if status != models.RecordStatusApproved || !record.Race.RequireEvidence {
+		return nil
+	}
+	var count int64
+	if err := config.DB.Model(&models.Attachments{}).Where("owner_type = ? AND owner_id = ?", models.AttachmentOwnerRecord, record.RecordID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &config.ValidationError{Message: "该比赛要求上传证明材料后才能审核通过"}
+	}
+	return nil
+}
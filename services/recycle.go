@@ -0,0 +1,267 @@
+package services
+
+import (
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// 删除比赛或学生时，其参赛记录使用相同的删除时间一并软删除，恢复时按删除时间一起恢复
+//
+// 只有比赛、参赛记录、学生和教师（含账户）软删除，其余数据删除后不进回收站：
+//   - 学院、专业、班级、角色、权限：仍被引用时拒绝删除，能删除的都是空数据
+//   - 学分规则版本：只能删除尚未生效的版本，已生效的版本不能删除
+//   - 服务账号：删除时一并撤销 API 密钥，恢复等同于重新启用凭据，需重新创建
+//   - 证书模板：已生成的证书不受影响，背景图交由孤立文件回收处理
+//   - 附件和文件：主动删除时立即删除存储对象，操作记录在审计日志中；软删除的比赛和参赛记录的附件保留到彻底删除时
+//   - 会话、令牌、通知、邮件队列、审计日志等：系统产生的数据，按各自的有效期或清理规则处理
+
+// DeleteRaces 软删除比赛及其参赛记录
+func DeleteRaces(ids []int) error {
+	now := time.Now()
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Races{}).Where("race_id IN ?", ids).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Records{}).Where("race_id IN ?", ids).Update("deleted_at", now).Error
+	})
+}
+
+// DeleteRecords 软删除参赛记录
+func DeleteRecords(ids []int) error {
+	return config.DB.Where("record_id IN ?", ids).Delete(&models.Records{}).Error
+}
+
+// DeleteStudents 软删除学生、账户及其参赛记录
+func DeleteStudents(sids []string) error {
+	now := time.Now()
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Students{}).Where("sid IN ?", sids).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("account IN ?", sids).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Records{}).Where("sid IN ?", sids).Update("deleted_at", now).Error
+	})
+}
+
+// DeleteTeachers 软删除教师及其账户，指导的参赛记录保留
+func DeleteTeachers(tids []string) error {
+	now := time.Now()
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Teachers{}).Where("tid IN ?", tids).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("account IN ?", tids).Update("deleted_at", now).Error
+	})
+}
+
+// RestoreRaces 恢复比赛及与其一同删除的参赛记录
+func RestoreRaces(ids []int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var races []models.Races
+		if err := tx.Unscoped().Where("race_id IN ? AND deleted_at IS NOT NULL", ids).Find(&races).Error; err != nil {
+			return err
+		}
+		if len(races) == 0 {
+			return &config.ValidationError{Message: "回收站中没有对应的比赛"}
+		}
+		for _, race := range races {
+			if err := tx.Unscoped().Model(&models.Records{}).
+				Where("race_id = ? AND deleted_at = ?", race.RaceID, race.DeletedAt.Time).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Races{}).Where("race_id = ?", race.RaceID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreRecords 恢复参赛记录，所属比赛或学生仍在回收站中时需要先恢复比赛或学生
+func RestoreRecords(ids []int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var records []models.Records
+		if err := tx.Unscoped().Where("record_id IN ? AND deleted_at IS NOT NULL", ids).Find(&records).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return &config.ValidationError{Message: "回收站中没有对应的参赛记录"}
+		}
+		for _, record := range records {
+			var count int64
+			tx.Model(&models.Races{}).Where("race_id = ?", record.RaceID).Count(&count)
+			if count == 0 {
+				return &config.ValidationError{Message: "参赛记录所属的比赛已删除，请先恢复比赛"}
+			}
+			tx.Model(&models.Students{}).Where("sid = ?", record.SID).Count(&count)
+			if count == 0 {
+				return &config.ValidationError{Message: "参赛记录所属的学生已删除，请先恢复学生"}
+			}
+		}
+		return tx.Unscoped().Model(&models.Records{}).Where("record_id IN ?", ids).Update("deleted_at", nil).Error
+	})
+}
+
+// RestoreStudents 恢复学生、账户及与其一同删除的参赛记录
+func RestoreStudents(sids []string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var students []models.Students
+		if err := tx.Unscoped().Where("sid IN ? AND deleted_at IS NOT NULL", sids).Find(&students).Error; err != nil {
+			return err
+		}
+		if len(students) == 0 {
+			return &config.ValidationError{Message: "回收站中没有对应的学生"}
+		}
+		for _, student := range students {
+			if err := tx.Unscoped().Model(&models.Records{}).
+				Where("sid = ? AND deleted_at = ?", student.SID, student.DeletedAt.Time).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.User{}).Where("account = ?", student.SID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Students{}).Where("sid = ?", student.SID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreTeachers 恢复教师及其账户
+func RestoreTeachers(tids []string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var teachers []models.Teachers
+		if err := tx.Unscoped().Where("tid IN ? AND deleted_at IS NOT NULL", tids).Find(&teachers).Error; err != nil {
+			return err
+		}
+		if len(teachers) == 0 {
+			return &config.ValidationError{Message: "回收站中没有对应的教师"}
+		}
+		for _, teacher := range teachers {
+			if err := tx.Unscoped().Model(&models.User{}).Where("account = ?", teacher.TID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Teachers{}).Where("tid = ?", teacher.TID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PurgeReport 回收站清理结果
+type PurgeReport struct {
+	Records  int64 `json:"records"`
+	Races    int64 `json:"races"`
+	Students int64 `json:"students"`
+	Teachers int64 `json:"teachers"`
+}
+
+// PurgeRecycleBin 彻底删除超过保留期的数据
+// 参赛记录和比赛的附件、证书一并删除，对应文件解除关联后由孤立文件回收任务清理
+func PurgeRecycleBin() (*PurgeReport, error) {
+	report := &PurgeReport{}
+	cutoff := time.Now().Add(-config.RecycleRetention)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var recordIDs []int
+		if err := tx.Unscoped().Model(&models.Records{}).Where("deleted_at < ?", cutoff).Pluck("record_id", &recordIDs).Error; err != nil {
+			return err
+		}
+		if len(recordIDs) > 0 {
+			if err := purgeAttachments(tx, models.AttachmentOwnerRecord, recordIDs); err != nil {
+				return err
+			}
+			var certificateIDs []int
+			if err := tx.Model(&models.Certificates{}).Where("record_id IN ?", recordIDs).Pluck("id", &certificateIDs).Error; err != nil {
+				return err
+			}
+			if len(certificateIDs) > 0 {
+				if err := unlinkFiles(tx, "certificate", certificateIDs); err != nil {
+					return err
+				}
+				if err := tx.Where("id IN ?", certificateIDs).Delete(&models.Certificates{}).Error; err != nil {
+					return err
+				}
+			}
+			result := tx.Unscoped().Where("record_id IN ?", recordIDs).Delete(&models.Records{})
+			if result.Error != nil {
+				return result.Error
+			}
+			report.Records = result.RowsAffected
+		}
+
+		var raceIDs []int
+		if err := tx.Unscoped().Model(&models.Races{}).Where("deleted_at < ?", cutoff).Pluck("race_id", &raceIDs).Error; err != nil {
+			return err
+		}
+		if len(raceIDs) > 0 {
+			if err := purgeAttachments(tx, models.AttachmentOwnerRace, raceIDs); err != nil {
+				return err
+			}
+			result := tx.Unscoped().Where("race_id IN ?", raceIDs).Delete(&models.Races{})
+			if result.Error != nil {
+				return result.Error
+			}
+			report.Races = result.RowsAffected
+		}
+
+		result := tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.Students{})
+		if result.Error != nil {
+			return result.Error
+		}
+		report.Students = result.RowsAffected
+		result = tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.Teachers{})
+		if result.Error != nil {
+			return result.Error
+		}
+		report.Teachers = result.RowsAffected
+		return tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&models.User{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// purgeAttachments 删除附件记录，不再被引用的文件交由孤立文件回收处理
+func purgeAttachments(tx *gorm.DB, ownerType string, ownerIDs []int) error {
+	return tx.Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).Delete(&models.Attachments{}).Error
+}
+
+func unlinkFiles(tx *gorm.DB, linkedType string, linkedIDs []int) error {
+	return tx.Model(&models.Files{}).Where("linked_type = ? AND linked_id IN ?", linkedType, linkedIDs).
+		Updates(map[string]interface{}{"linked_type": "", "linked_id": 0}).Error
+}
+
+// StartRecyclePurge 启动回收站定时清理任务
+func StartRecyclePurge() {
+	if !config.RecyclePurgeEnabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(config.RecyclePurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := PurgeRecycleBin()
+			if err != nil {
+				log.Error().Err(err).Msg("回收站清理失败")
+				continue
+			}
+			log.Info().
+				Int64("records", report.Records).
+				Int64("races", report.Races).
+				Int64("students", report.Students).
+				Int64("teachers", report.Teachers).
+				Msg("回收站清理完成")
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+func TestPurgeRecycleBinCascades(t *testing.T) {
+	testutil.OpenDB(t)
+	now := time.Now()
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "1"})
+	for _, id := range []int{1, 2} {
+		config.DB.Create(&models.Races{RaceID: id, Title: "比赛"})
+		config.DB.Create(&models.Records{RecordID: id, SID: "20210001", RaceID: id, CreateTime: now})
+		config.DB.Create(&models.Attachments{OwnerType: models.AttachmentOwnerRecord, OwnerID: id, ObjectKey: "evidence/record.pdf", Uploader: "20210001"})
+		config.DB.Create(&models.Attachments{OwnerType: models.AttachmentOwnerRace, OwnerID: id, ObjectKey: "evidence/race.pdf", Uploader: "admin"})
+		config.DB.Create(&models.Certificates{ID: id, RecordID: id, Serial: string(rune('A' + id)), VerifyCode: string(rune('a' + id))})
+		config.DB.Create(&models.Files{ObjectKey: "certificate/" + string(rune('A'+id)) + ".png", Owner: "20210001", LinkedType: "certificate", LinkedID: id})
+	}
+	if err := DeleteRaces([]int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	// 比赛 1 已超过保留期，比赛 2 刚删除
+	expired := now.Add(-config.RecycleRetention - time.Hour)
+	config.DB.Unscoped().Model(&models.Races{}).Where("race_id = ?", 1).Update("deleted_at", expired)
+	config.DB.Unscoped().Model(&models.Records{}).Where("race_id = ?", 1).Update("deleted_at", expired)
+
+	report, err := PurgeRecycleBin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Races != 1 || report.Records != 1 {
+		t.Fatalf("应彻底删除一场比赛和一条参赛记录，得到 %+v", report)
+	}
+
+	var count int64
+	config.DB.Unscoped().Model(&models.Races{}).Where("race_id = ?", 1).Count(&count)
+	if count != 0 {
+		t.Fatal("过期的比赛应彻底删除")
+	}
+	config.DB.Unscoped().Model(&models.Records{}).Where("record_id = ?", 1).Count(&count)
+	if count != 0 {
+		t.Fatal("过期比赛的参赛记录应彻底删除")
+	}
+	config.DB.Model(&models.Attachments{}).Where("owner_id = ?", 1).Count(&count)
+	if count != 0 {
+		t.Fatalf("参赛记录和比赛的附件应一并删除，剩余 %d 条", count)
+	}
+	config.DB.Model(&models.Certificates{}).Where("record_id = ?", 1).Count(&count)
+	if count != 0 {
+		t.Fatal("参赛记录的证书应一并删除")
+	}
+	var file models.Files
+	config.DB.Where("object_key = ?", "certificate/B.png").First(&file)
+	if file.LinkedType != "" || file.LinkedID != 0 {
+		t.Fatalf("证书文件应解除关联交由孤立文件回收，得到 %s/%d", file.LinkedType, file.LinkedID)
+	}
+
+	// 未过期的数据保持原样
+	config.DB.Unscoped().Model(&models.Records{}).Where("record_id = ? AND deleted_at IS NOT NULL", 2).Count(&count)
+	if count != 1 {
+		t.Fatal("未过期的参赛记录应留在回收站")
+	}
+	config.DB.Model(&models.Attachments{}).Where("owner_id = ?", 2).Count(&count)
+	if count != 2 {
+		t.Fatalf("未过期数据的附件应保留，得到 %d 条", count)
+	}
+	var kept models.Files
+	config.DB.Where("object_key = ?", "certificate/C.png").First(&kept)
+	if kept.LinkedType != "certificate" || kept.LinkedID != 2 {
+		t.Fatal("未过期证书的文件关联应保留")
+	}
+}
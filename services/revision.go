@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// loadSnapshot 读取比赛或参赛记录的当前数据，不包含关联数据
+func loadSnapshot(entityType string, id int) (map[string]interface{}, error) {
+	var value interface{}
+	var omit []string
+	switch entityType {
+	case models.RevisionEntityRace:
+		var race models.Races
+		if err := config.DB.Unscoped().Where("race_id = ?", id).First(&race).Error; err != nil {
+			return nil, err
+		}
+		value, omit = race, []string{"records"}
+	case models.RevisionEntityRecord:
+		var record models.Records
+		if err := config.DB.Unscoped().Where("record_id = ?", id).First(&record).Error; err != nil {
+			return nil, err
+		}
+		value, omit = record, []string{"student", "teacher", "race"}
+	default:
+		return nil, &config.ValidationError{Message: "未知的类型"}
+	}
+
+	snapshot, _ := toAuditMap(value)
+	for _, key := range omit {
+		delete(snapshot, key)
+	}
+	return snapshot, nil
+}
+
+func latestRevision(entityType string, id int) (*models.Revisions, error) {
+	var revision models.Revisions
+	err := config.DB.Where("entity_type = ? AND entity_id = ?", entityType, id).Order("version DESC").First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// saveRevision 保存当前数据为新版本，与上一版本相同时不重复保存
+func saveRevision(entityType string, id int, action, operator string) error {
+	snapshot, err := loadSnapshot(entityType, id)
+	if err != nil {
+		return err
+	}
+	latest, err := latestRevision(entityType, id)
+	if err != nil {
+		return err
+	}
+
+	version := 1
+	if latest != nil {
+		var previous map[string]interface{}
+		if json.Unmarshal([]byte(latest.Snapshot), &previous) == nil && reflect.DeepEqual(previous, snapshot) {
+			return nil
+		}
+		version = latest.Version + 1
+	}
+	return config.DB.Create(&models.Revisions{
+		EntityType: entityType,
+		EntityID:   id,
+		Version:    version,
+		Action:     action,
+		Operator:   operator,
+		Snapshot:   marshalAudit(snapshot),
+		CreateTime: time.Now(),
+	}).Error
+}
+
+// BeginRevision 在修改比赛或参赛记录之前调用，尚无历史版本时先保存当前数据作为初始版本
+// 版本记录失败不影响业务请求，只记录错误日志
+func BeginRevision(entityType string, id int) {
+	latest, err := latestRevision(entityType, id)
+	if err == nil && latest == nil {
+		err = saveRevision(entityType, id, models.RevisionActionBaseline, "")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("type", entityType).Int("id", id).Msg("保存初始版本失败")
+	}
+}
+
+// SaveRevision 在修改比赛或参赛记录之后调用，保存修改后的数据为新版本，操作人取自请求上下文
+func SaveRevision(c *gin.Context, entityType string, id int, action string) {
+	operator := ""
+	if user, exists := c.Get("authenticatedUser"); exists {
+		operator = user.(models.AuthenticatedUser).Account
+	}
+	if err := saveRevision(entityType, id, action, operator); err != nil {
+		log.Error().Err(err).Str("type", entityType).Int("id", id).Msg("保存历史版本失败")
+	}
+}
+
+// ListRevisions 查询全部历史版本，按版本号升序
+func ListRevisions(entityType string, id int) ([]models.Revisions, error) {
+	var revisions []models.Revisions
+	err := config.DB.Where("entity_type = ? AND entity_id = ?", entityType, id).Order("version ASC").Find(&revisions).Error
+	return revisions, err
+}
+
+// GetRevision 查询指定版本
+func GetRevision(entityType string, id, version int) (*models.Revisions, error) {
+	var revision models.Revisions
+	err := config.DB.Where("entity_type = ? AND entity_id = ? AND version = ?", entityType, id, version).First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &config.ValidationError{Message: "版本不存在"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RevisionAt 查询某一时刻的数据版本，即该时刻之前最后保存的版本
+func RevisionAt(entityType string, id int, at time.Time) (*models.Revisions, error) {
+	var revision models.Revisions
+	err := config.DB.Where("entity_type = ? AND entity_id = ? AND create_time <= ?", entityType, id, at).
+		Order("version DESC").First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &config.ValidationError{Message: "该时间点没有历史版本"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// RevisionSnapshot 解析版本中保存的数据
+func RevisionSnapshot(revision *models.Revisions) map[string]interface{} {
+	snapshot := make(map[string]interface{})
+	json.Unmarshal([]byte(revision.Snapshot), &snapshot)
+	return snapshot
+}
+
+// DiffRevisions 比较两个版本，返回发生变化的字段
+func DiffRevisions(entityType string, id, from, to int) (map[string]interface{}, error) {
+	fromRevision, err := GetRevision(entityType, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := GetRevision(entityType, id, to)
+	if err != nil {
+		return nil, err
+	}
+	return AuditDiff(RevisionSnapshot(fromRevision), RevisionSnapshot(toRevision)), nil
+}
+
+// RevertRecord 将参赛记录的获奖等级和审核状态恢复为指定版本，恢复操作本身也会保存为新版本
+func RevertRecord(c *gin.Context, id, version int) error {
+	revision, err := GetRevision(models.RevisionEntityRecord, id, version)
+	if err != nil {
+		return err
+	}
+	snapshot := RevisionSnapshot(revision)
+	score, _ := snapshot["score"].(string)
+	status, ok := snapshot["status"].(float64)
+	if !ok {
+		return &config.ValidationError{Message: "版本数据不完整"}
+	}
+
+	var record models.Records
+	if err := config.DB.Preload("Race").Where("record_id = ?", id).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &config.ValidationError{Message: "记录不存在"}
+		}
+		return err
+	}
+	// 恢复历史版本与正常审核一样，要求的证明材料缺失时不能恢复为审核通过
+	if err := CheckRecordEvidence(record, int(status)); err != nil {
+		return err
+	}
+
+	BeginRevision(models.RevisionEntityRecord, id)
+	if err := config.DB.Model(&models.Records{}).Where("record_id = ?", id).Updates(map[string]interface{}{
+		"score":       score,
+		"status":      int(status),
+		"update_time": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	SaveRevision(c, models.RevisionEntityRecord, id, models.RevisionActionRevert)
+
+	record.Score = score
+	record.Status = int(status)
+	NotifyRecordStatus(record, record.Race.Title)
+	return nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// setRecordStatus 以审核人身份修改参赛记录状态并保存版本，与审核接口的流程一致
+func setRecordStatus(t *testing.T, c *gin.Context, id, status int, score string) {
+	t.Helper()
+	BeginRevision(models.RevisionEntityRecord, id)
+	if err := config.DB.Model(&models.Records{}).Where("record_id = ?", id).Updates(map[string]interface{}{"status": status, "score": score}).Error; err != nil {
+		t.Fatal(err)
+	}
+	SaveRevision(c, models.RevisionEntityRecord, id, models.RevisionActionStatus)
+}
+
+func TestRevertRecordChecksEvidenceAndNotifiesOwner(t *testing.T) {
+	testutil.OpenDB(t)
+	sex := 1
+	config.DB.Create(&models.Students{SID: "20210001", Name: "张三", Password: "x", Sex: &sex, Grade: 2021, Class: "1"})
+	config.DB.Create(&models.Races{RaceID: 1, Title: "程序设计竞赛", Level: 3, RequireEvidence: true, Startdate: time.Now()})
+	config.DB.Create(&models.Records{RecordID: 1, SID: "20210001", RaceID: 1, Status: models.RecordStatusPending, CreateTime: time.Now()})
+	attachment := models.Attachments{OwnerType: models.AttachmentOwnerRecord, OwnerID: 1, ObjectKey: "evidence/20210001/a.pdf", Uploader: "20210001"}
+	config.DB.Create(&attachment)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("authenticatedUser", models.AuthenticatedUser{Account: "T001", Identity: "teacher"})
+	setRecordStatus(t, c, 1, models.RecordStatusApproved, "一等奖")
+	setRecordStatus(t, c, 1, models.RecordStatusRejected, "")
+	revisions, _ := ListRevisions(models.RevisionEntityRecord, 1)
+	approved := revisions[1].Version
+
+	// 证明材料被删除后，不能通过恢复历史版本绕过校验
+	config.DB.Delete(&attachment)
+	if err := RevertRecord(c, 1, approved); !isValidationError(err) {
+		t.Fatalf("缺少证明材料时不能恢复为审核通过，得到 %v", err)
+	}
+	var record models.Records
+	config.DB.Where("record_id = ?", 1).First(&record)
+	if record.Status != models.RecordStatusRejected {
+		t.Fatalf("记录不应被修改，状态为 %d", record.Status)
+	}
+
+	config.DB.Create(&models.Attachments{OwnerType: models.AttachmentOwnerRecord, OwnerID: 1, ObjectKey: "evidence/20210001/b.pdf", Uploader: "20210001"})
+	if err := RevertRecord(c, 1, approved); err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Where("record_id = ?", 1).First(&record)
+	if record.Status != models.RecordStatusApproved || record.Score != "一等奖" {
+		t.Fatalf("应恢复为审核通过: %+v", record)
+	}
+	var notices []models.Notifications
+	config.DB.Where("recipient = ? AND event = ?", "20210001", models.NotifyRecordStatus).Find(&notices)
+	if len(notices) != 1 || notices[0].TargetID != 1 {
+		t.Fatalf("恢复历史版本应通知学生，得到 %+v", notices)
+	}
+
+	if err := RevertRecord(c, 2, 1); !isValidationError(err) {
+		t.Fatalf("不存在的记录应返回校验错误，得到 %v", err)
+	}
+}
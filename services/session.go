@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// CreateSession 登录成功后创建会话，返回写入登录令牌的 sid
+func CreateSession(account, ip, userAgent string, expire time.Time) (string, error) {
+	id, err := utils.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	session := models.UserSessions{
+		ID:           id,
+		Account:      account,
+		Device:       DeviceName(userAgent),
+		IP:           ip,
+		UserAgent:    truncate(userAgent, 512),
+		CreateTime:   now,
+		LastSeenTime: now,
+		ExpireTime:   expire,
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// ValidateSession 校验令牌对应的会话仍然有效，并按 SessionTouchInterval 更新最近访问时间和 IP
+// 模拟登录的会话还要求管理员的原会话仍然有效
+func ValidateSession(id, account, ip string) (*models.UserSessions, error) {
+	session, err := activeSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Account != account {
+		return nil, &config.ValidationError{Message: "会话已注销，请重新登录"}
+	}
+	if session.ImpersonatedBy != "" {
+		if _, err := activeSession(session.OriginSession); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	if now.Sub(session.LastSeenTime) >= config.SessionTouchInterval || session.IP != ip {
+		if err := config.DB.Model(&models.UserSessions{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"last_seen_time": now,
+			"ip":             ip,
+		}).Error; err != nil {
+			log.Error().Err(err).Str("account", account).Msg("更新会话访问时间失败")
+		}
+	}
+	return session, nil
+}
+
+// activeSession 查询未注销且未过期的会话
+func activeSession(id string) (*models.UserSessions, error) {
+	var session models.UserSessions
+	if err := config.DB.Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &config.ValidationError{Message: "会话不存在，请重新登录"}
+		}
+		return nil, err
+	}
+	if session.RevokedTime != nil {
+		return nil, &config.ValidationError{Message: "会话已注销，请重新登录"}
+	}
+	if time.Now().After(session.ExpireTime) {
+		return nil, &config.ValidationError{Message: "会话已过期，请重新登录"}
+	}
+	return &session, nil
+}
+
+// ListSessions 查询账户当前有效的会话，最近访问的在前
+// 管理员模拟该账户的会话不对用户展示，current 为发起请求的会话，模拟期间仍能看到自身
+func ListSessions(account, current string) ([]models.UserSessions, error) {
+	var sessions []models.UserSessions
+	err := config.DB.Where("account = ? AND revoked_time IS NULL AND expire_time > ?", account, time.Now()).
+		Where("COALESCE(impersonated_by, '') = '' OR id = ?", current).
+		Order("last_seen_time DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession 注销账户的某个会话，会话不存在或已注销时返回校验错误
+// 管理员的模拟会话只能由其自身（current）注销，用户不能注销
+func RevokeSession(account, id, current string) error {
+	result := config.DB.Model(&models.UserSessions{}).
+		Where("id = ? AND account = ? AND revoked_time IS NULL", id, account).
+		Where("COALESCE(impersonated_by, '') = '' OR id = ?", current).
+		Update("revoked_time", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &config.ValidationError{Message: "会话不存在或已注销"}
+	}
+	return nil
+}
+
+// RevokeSessions 注销账户除 except 以外的全部会话，返回注销的数量
+// 指定 except 时为用户注销其他设备，保留管理员的模拟会话；强制下线和重置密码时全部注销
+func RevokeSessions(db *gorm.DB, account, except string) (int64, error) {
+	query := db.Model(&models.UserSessions{}).Where("account = ? AND revoked_time IS NULL", account)
+	if except != "" {
+		query = query.Where("id <> ? AND COALESCE(impersonated_by, '') = ''", except)
+	}
+	result := query.Update("revoked_time", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// DeviceName 从 User-Agent 中识别操作系统和浏览器，用于会话列表展示
+func DeviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	var platform, browser string
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+	// Edge、Opera 和微信的 User-Agent 同时包含 Chrome/Safari，需先判断
+	switch {
+	case strings.Contains(ua, "micromessenger"):
+		browser = "微信"
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	}
+	switch {
+	case platform != "" && browser != "":
+		return platform + " " + browser
+	case platform != "" || browser != "":
+		return platform + browser
+	case userAgent != "":
+		return truncate(userAgent, 64)
+	}
+	return "未知设备"
+}
+
+// StartSessionPurge 定时删除过期或已注销超过 SessionRetention 的会话
+func StartSessionPurge() {
+	go func() {
+		ticker := time.NewTicker(config.SessionPurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			before := time.Now().Add(-config.SessionRetention)
+			result := config.DB.Where("expire_time < ? OR revoked_time < ?", before, before).Delete(&models.UserSessions{})
+			if result.Error != nil {
+				log.Error().Err(result.Error).Msg("会话清理失败")
+				continue
+			}
+			log.Info().Int64("sessions", result.RowsAffected).Msg("会话清理完成")
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+// createSession 为账号创建一个有效期一小时的会话
+func createSession(t *testing.T, account string) string {
+	t.Helper()
+	id, err := CreateSession(account, "192.0.2.1", "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// impersonate 以 admin 的身份模拟 20210001，返回管理员原会话和模拟会话
+func impersonate(t *testing.T) (string, string) {
+	t.Helper()
+	testutil.SeedRole(t, 1, "user:impersonate", "race:query")
+	testutil.SeedRole(t, 3, "race:query")
+	testutil.CreateUser(t, "admin", "teacher", 1, "pw")
+	testutil.CreateUser(t, "20210001", "student", 3, "pw")
+	origin := createSession(t, "admin")
+	operator := models.AuthenticatedUser{Account: "admin", Identity: "teacher", Permissions: []string{"user:impersonate", "race:query"}}
+	session, _, err := StartImpersonation(operator, origin, "20210001", "192.0.2.1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return origin, session.ID
+}
+
+func TestRevokeSessionsKeepsImpersonation(t *testing.T) {
+	testutil.OpenDB(t)
+	_, impersonation := impersonate(t)
+	current := createSession(t, "20210001")
+	other := createSession(t, "20210001")
+
+	// 用户注销其他设备时保留管理员的模拟会话
+	count, err := RevokeSessions(config.DB, "20210001", current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("应只注销一个其他设备的会话，得到 %d", count)
+	}
+	if _, err := ValidateSession(other, "20210001", "192.0.2.1"); !isValidationError(err) {
+		t.Fatalf("其他设备的会话应失效，得到 %v", err)
+	}
+	for _, id := range []string{current, impersonation} {
+		if _, err := ValidateSession(id, "20210001", "192.0.2.1"); err != nil {
+			t.Fatalf("会话 %s 应保持有效: %v", id, err)
+		}
+	}
+
+	// 强制下线时全部注销，包括模拟会话
+	if _, err := RevokeSessions(config.DB, "20210001", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{current, impersonation} {
+		if _, err := ValidateSession(id, "20210001", "192.0.2.1"); !isValidationError(err) {
+			t.Fatalf("强制下线后会话 %s 应失效，得到 %v", id, err)
+		}
+	}
+}
+
+func TestValidateSessionRejectsRevokedOrigin(t *testing.T) {
+	testutil.OpenDB(t)
+	origin, impersonation := impersonate(t)
+	if _, err := ValidateSession(impersonation, "20210001", "192.0.2.1"); err != nil {
+		t.Fatalf("模拟会话应有效: %v", err)
+	}
+
+	// 管理员的原会话注销后，模拟会话随之失效
+	if err := RevokeSession("admin", origin, origin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateSession(impersonation, "20210001", "192.0.2.1"); !isValidationError(err) {
+		t.Fatalf("原会话注销后模拟会话应失效，得到 %v", err)
+	}
+}
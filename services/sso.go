@@ -0,0 +1,193 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// SSOProfile 从身份提供方获取的用户信息
+// IdentityVerified 表示用户类型由身份提供方给出，否则不会自动创建账户
+type SSOProfile struct {
+	Account          string
+	Identity         string
+	IdentityVerified bool
+	Name             string
+	Email            string
+	EmailVerified    bool
+	Sex              int
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	if name == "" {
+		return ""
+	}
+	switch v := claims[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	case []interface{}:
+		// 部分身份提供方以数组返回用户类型，取第一个能识别的值
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				if _, known := config.SSOIdentityValues[s]; known {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// mutableClaims 用户可以在身份提供方自行修改的声明，不能用于映射本地账户
+var mutableClaims = map[string]bool{
+	"preferred_username": true,
+	"email":              true,
+	"name":               true,
+	"nickname":           true,
+	"given_name":         true,
+	"family_name":        true,
+	"phone_number":       true,
+}
+
+// OIDCProfile 按配置的声明映射解析 ID Token 中的用户信息
+func OIDCProfile(claims map[string]interface{}) (*SSOProfile, error) {
+	if mutableClaims[config.OIDCAccountClaim] {
+		return nil, fmt.Errorf("OIDCAccountClaim 不能使用用户可修改的声明 %s", config.OIDCAccountClaim)
+	}
+	profile := &SSOProfile{
+		Account: claimString(claims, config.OIDCAccountClaim),
+		Name:    claimString(claims, config.OIDCNameClaim),
+		Email:   claimString(claims, config.OIDCEmailClaim),
+	}
+	if profile.Account == "" {
+		return nil, &config.ValidationError{Message: "身份信息中缺少学号/工号"}
+	}
+
+	profile.Identity = config.OIDCDefaultIdentity
+	if value := claimString(claims, config.OIDCIdentityClaim); value != "" {
+		identity, ok := config.SSOIdentityValues[value]
+		if !ok {
+			return nil, &config.ValidationError{Message: "不支持的用户类型: " + value}
+		}
+		profile.Identity = identity
+	}
+	if profile.Identity != "student" && profile.Identity != "teacher" {
+		return nil, &config.ValidationError{Message: "无法确定用户身份"}
+	}
+	profile.IdentityVerified = true
+
+	if profile.Name == "" {
+		profile.Name = profile.Account
+	}
+	profile.EmailVerified, _ = claims["email_verified"].(bool)
+	switch strings.ToLower(claimString(claims, config.OIDCGenderClaim)) {
+	case "male", "m", "1":
+		profile.Sex = 1
+	}
+	return profile, nil
+}
+
+// unusablePassword 单点登录创建的账户使用随机密码，需要本地登录时可通过找回密码或管理员重置设置
+func unusablePassword() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(buf)), bcrypt.DefaultCost)
+	return string(hashed), err
+}
+
+// ssoRoleDenied 判断角色是否禁止通过单点登录登录
+func ssoRoleDenied(roleID int) bool {
+	for _, denied := range config.SSODeniedRoles {
+		if roleID == denied {
+			return true
+		}
+	}
+	return false
+}
+
+// ProvisionUser 查找单点登录用户对应的账户，账户不存在且允许自动创建时创建学生/教师档案，角色为默认角色
+// 账户已存在时身份类型必须一致；SSODeniedRoles 中的角色既不能登录也不会被创建
+func ProvisionUser(profile *SSOProfile) (*models.User, error) {
+	var user models.User
+	err := config.DB.Unscoped().Where("account = ?", profile.Account).First(&user).Error
+	if err == nil {
+		if user.DeletedAt.Valid {
+			return nil, &config.ValidationError{Message: "账户已被删除，请联系管理员"}
+		}
+		if user.Identity != profile.Identity {
+			return nil, &config.ValidationError{Message: "账户身份与统一身份认证不一致，请联系管理员"}
+		}
+		if ssoRoleDenied(user.RoleID) {
+			return nil, &config.ValidationError{Message: "该账户不能使用统一身份认证登录，请使用账号密码登录"}
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !config.SSOProvision || !profile.IdentityVerified {
+		return nil, &config.ValidationError{Message: "账户不存在，请联系管理员开通"}
+	}
+
+	password, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	roleID := config.DefaultRoleID(profile.Identity, profile.Account)
+	if ssoRoleDenied(roleID) {
+		return nil, &config.ValidationError{Message: "账户不存在，请联系管理员开通"}
+	}
+	user = models.User{Account: profile.Account, Password: password, Identity: profile.Identity, RoleID: roleID}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		switch profile.Identity {
+		case "student":
+			sex := profile.Sex
+			if err := tx.Create(&models.Students{
+				SID:           profile.Account,
+				Name:          profile.Name,
+				Password:      password,
+				Sex:           &sex,
+				RoleID:        roleID,
+				Email:         profile.Email,
+				EmailVerified: profile.Email != "" && profile.EmailVerified,
+				CreateTime:    now,
+				UpdateTime:    now,
+			}).Error; err != nil {
+				return err
+			}
+		case "teacher":
+			if err := tx.Create(&models.Teachers{
+				TID:           profile.Account,
+				Name:          profile.Name,
+				Password:      password,
+				RoleID:        roleID,
+				Email:         profile.Email,
+				EmailVerified: profile.Email != "" && profile.EmailVerified,
+				CreateTime:    now,
+				UpdateTime:    now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("account", profile.Account).Str("identity", profile.Identity).Msg("单点登录自动创建账户")
+	return &user, nil
+}
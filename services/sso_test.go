@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+func TestOIDCProfileUsesImmutableAccountClaim(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":                "20210001",
+		"preferred_username": "admin",
+		"user_type":          []interface{}{"alumni", "undergraduate"},
+		"name":               "张三",
+		"email":              "zs@example.com",
+		"email_verified":     true,
+		"gender":             "male",
+	}
+	profile, err := OIDCProfile(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Account != "20210001" || profile.Identity != "student" || !profile.IdentityVerified {
+		t.Fatalf("应按 sub 映射账户: %+v", profile)
+	}
+	if profile.Name != "张三" || !profile.EmailVerified || profile.Sex != 1 {
+		t.Fatalf("声明映射有误: %+v", profile)
+	}
+
+	defer func(claim string) { config.OIDCAccountClaim = claim }(config.OIDCAccountClaim)
+	config.OIDCAccountClaim = "preferred_username"
+	if _, err := OIDCProfile(claims); err == nil {
+		t.Fatal("使用可修改的声明映射账户应报错")
+	}
+}
+
+func TestOIDCProfileRejectsUnknownIdentity(t *testing.T) {
+	if _, err := OIDCProfile(map[string]interface{}{"sub": "x", "user_type": "alumni"}); !isValidationError(err) {
+		t.Fatalf("未知用户类型应被拒绝，得到 %v", err)
+	}
+	if _, err := OIDCProfile(map[string]interface{}{"user_type": "student"}); !isValidationError(err) {
+		t.Fatalf("缺少学号应被拒绝，得到 %v", err)
+	}
+}
+
+func TestProvisionUser(t *testing.T) {
+	testutil.OpenDB(t)
+
+	user, err := ProvisionUser(&SSOProfile{Account: "20210001", Identity: "student", IdentityVerified: true, Name: "张三"})
+	if err != nil {
+		t.Fatalf("首次登录应自动创建账户: %v", err)
+	}
+	if user.RoleID != 3 {
+		t.Fatalf("学生默认角色应为 3，得到 %d", user.RoleID)
+	}
+	var student models.Students
+	if err := config.DB.Where("sid = ?", "20210001").First(&student).Error; err != nil || student.Name != "张三" {
+		t.Fatalf("应创建学生档案: %+v, %v", student, err)
+	}
+
+	again, err := ProvisionUser(&SSOProfile{Account: "20210001", Identity: "student"})
+	if err != nil || again.Account != "20210001" {
+		t.Fatalf("再次登录应关联已有账户: %v", err)
+	}
+	if _, err := ProvisionUser(&SSOProfile{Account: "20210001", Identity: "teacher", IdentityVerified: true}); !isValidationError(err) {
+		t.Fatalf("身份不一致应被拒绝，得到 %v", err)
+	}
+	if _, err := ProvisionUser(&SSOProfile{Account: "20219999", Identity: "student"}); !isValidationError(err) {
+		t.Fatalf("用户类型未经身份提供方确认时不应创建账户，得到 %v", err)
+	}
+}
+
+func TestProvisionUserNeverTouchesAdminRole(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.CreateUser(t, "root", "teacher", 1, "pw")
+
+	if _, err := ProvisionUser(&SSOProfile{Account: "root", Identity: "teacher", IdentityVerified: true}); !isValidationError(err) {
+		t.Fatalf("单点登录不能登录超级管理员账户，得到 %v", err)
+	}
+	if _, err := ProvisionUser(&SSOProfile{Account: "admin", Identity: "student", IdentityVerified: true}); !isValidationError(err) {
+		t.Fatalf("单点登录不能创建超级管理员账户，得到 %v", err)
+	}
+	var count int64
+	config.DB.Model(&models.User{}).Where("account = ?", "admin").Count(&count)
+	if count != 0 {
+		t.Fatal("不应创建 admin 账户")
+	}
+}
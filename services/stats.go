@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"gorm.io/gorm"
+)
+
+// StatsFilter 统计的筛选条件，时间范围按参赛记录的创建时间计算
+type StatsFilter struct {
+	Start     *time.Time
+	End       *time.Time
+	Level     *int
+	Type      string
+	CollegeID *int // 学生所属学院
+}
+
+// StatsRow 一个分组的参赛人次和获奖人次
+type StatsRow struct {
+	Key           string `json:"key"`
+	Label         string `json:"label"`
+	Participation int64  `json:"participation"`
+	Awards        int64  `json:"awards"`
+}
+
+// StatsSummary 总体统计
+type StatsSummary struct {
+	Records  int64 `json:"records"`  // 参赛人次
+	Students int64 `json:"students"` // 参赛学生数
+	Races    int64 `json:"races"`    // 有人参加的比赛数
+	Awards   int64 `json:"awards"`   // 获奖人次
+	Pending  int64 `json:"pending"`  // 待审核
+	Approved int64 `json:"approved"` // 审核通过
+	Rejected int64 `json:"rejected"` // 审核驳回
+}
+
+// 分组维度对应的分组字段和显示字段
+var statsDimensions = map[string]struct{ key, label string }{
+	"level":   {"races.level", "races.level"},
+	"type":    {"races.type", "races.type"},
+	"grade":   {"students.grade", "students.grade"},
+	"class":   {"students.class", "students.class"},
+	"college": {"colleges.id", "colleges.name"},
+	"major":   {"majors.id", "majors.name"},
+	"teacher": {"records.tid", "teachers.name"},
+	"year":    {"YEAR(records.create_time)", "YEAR(records.create_time)"},
+	"award":   {"records.score", "records.score"},
+}
+
+// 排行榜维度
+var statsTopDimensions = map[string]struct{ key, label string }{
+	"student": {"records.sid", "students.name"},
+	"teacher": {"records.tid", "teachers.name"},
+	"race":    {"records.race_id", "races.title"},
+	"class":   {"students.class", "students.class"},
+	"college": {"colleges.id", "colleges.name"},
+}
+
+// 获奖：审核通过且填写了获奖等级
+var awardCondition = fmt.Sprintf("records.status = %d AND records.score <> ''", models.RecordStatusApproved)
+
+var statsCache = utils.NewTTLCache(config.StatsCacheTTL)
+
+// ClearStatsCache 清空统计缓存
+func ClearStatsCache() {
+	statsCache.Clear()
+}
+
+// statsQuery 构造带筛选条件的参赛记录联表查询
+func statsQuery(filter StatsFilter) *gorm.DB {
+	query := config.DB.Table("records").
+		Joins("LEFT JOIN races ON races.race_id = records.race_id").
+		Joins("LEFT JOIN students ON students.sid = records.sid").
+		Joins("LEFT JOIN classes ON classes.id = students.class_id").
+		Joins("LEFT JOIN majors ON majors.id = classes.major_id").
+		Joins("LEFT JOIN colleges ON colleges.id = majors.college_id").
+		Joins("LEFT JOIN teachers ON teachers.tid = records.tid AND teachers.deleted_at IS NULL").
+		Where("records.deleted_at IS NULL")
+	if filter.Start != nil {
+		query = query.Where("records.create_time >= ?", *filter.Start)
+	}
+	if filter.End != nil {
+		query = query.Where("records.create_time < ?", *filter.End)
+	}
+	if filter.Level != nil {
+		query = query.Where("races.level = ?", *filter.Level)
+	}
+	if filter.Type != "" {
+		query = query.Where("races.type = ?", filter.Type)
+	}
+	if filter.CollegeID != nil {
+		query = query.Where("colleges.id = ?", *filter.CollegeID)
+	}
+	return query
+}
+
+func (f StatsFilter) cacheKey() string {
+	key := ""
+	if f.Start != nil {
+		key += f.Start.Format(time.RFC3339)
+	}
+	key += "~"
+	if f.End != nil {
+		key += f.End.Format(time.RFC3339)
+	}
+	if f.Level != nil {
+		key += fmt.Sprintf("|level=%d", *f.Level)
+	}
+	if f.CollegeID != nil {
+		key += fmt.Sprintf("|college=%d", *f.CollegeID)
+	}
+	return key + "|type=" + f.Type
+}
+
+// cached 优先从缓存读取，未命中时计算并写入缓存
+func cached[T any](key string, compute func() (T, error)) (T, error) {
+	if value, ok := statsCache.Get(key); ok {
+		return value.(T), nil
+	}
+	value, err := compute()
+	if err != nil {
+		return value, err
+	}
+	statsCache.Set(key, value)
+	return value, nil
+}
+
+// GetStatsSummary 总体统计
+func GetStatsSummary(filter StatsFilter) (*StatsSummary, error) {
+	return cached("summary|"+filter.cacheKey(), func() (*StatsSummary, error) {
+		var summary StatsSummary
+		err := statsQuery(filter).Select(fmt.Sprintf(`COUNT(*) AS records,
+			COUNT(DISTINCT records.sid) AS students,
+			COUNT(DISTINCT records.race_id) AS races,
+			COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS awards,
+			COALESCE(SUM(CASE WHEN records.status = %d THEN 1 ELSE 0 END), 0) AS pending,
+			COALESCE(SUM(CASE WHEN records.status = %d THEN 1 ELSE 0 END), 0) AS approved,
+			COALESCE(SUM(CASE WHEN records.status = %d THEN 1 ELSE 0 END), 0) AS rejected`,
+			awardCondition, models.RecordStatusPending, models.RecordStatusApproved, models.RecordStatusRejected)).
+			Scan(&summary).Error
+		return &summary, err
+	})
+}
+
+// GetStatsGroup 按维度分组统计参赛人次和获奖人次
+func GetStatsGroup(dimension string, filter StatsFilter) ([]StatsRow, error) {
+	dim, ok := statsDimensions[dimension]
+	if !ok {
+		return nil, errors.New("未知的统计维度")
+	}
+	return cached("group|"+dimension+"|"+filter.cacheKey(), func() ([]StatsRow, error) {
+		var rows []StatsRow
+		err := statsQuery(filter).
+			Select(fmt.Sprintf("CAST(%s AS CHAR) AS `key`, CAST(COALESCE(%s, '') AS CHAR) AS label, COUNT(*) AS participation, COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS awards", dim.key, dim.label, awardCondition)).
+			Group(dim.key).Group(dim.label).
+			Order("participation DESC").
+			Scan(&rows).Error
+		return rows, err
+	})
+}
+
+// GetStatsTrend 按月或按年统计参赛人次和获奖人次的变化趋势
+func GetStatsTrend(interval string, filter StatsFilter) ([]StatsRow, error) {
+	format := "%Y-%m"
+	switch interval {
+	case "", "month":
+	case "year":
+		format = "%Y"
+	default:
+		return nil, errors.New("未知的统计周期")
+	}
+	return cached("trend|"+format+"|"+filter.cacheKey(), func() ([]StatsRow, error) {
+		var rows []StatsRow
+		period := fmt.Sprintf("DATE_FORMAT(records.create_time, '%s')", format)
+		err := statsQuery(filter).
+			Select(fmt.Sprintf("%s AS `key`, %s AS label, COUNT(*) AS participation, COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS awards", period, period, awardCondition)).
+			Group(period).
+			Order("`key` ASC").
+			Scan(&rows).Error
+		return rows, err
+	})
+}
+
+// GetStatsTop 获奖人次排行榜，获奖相同时按参赛人次排序
+func GetStatsTop(dimension string, n int, filter StatsFilter) ([]StatsRow, error) {
+	dim, ok := statsTopDimensions[dimension]
+	if !ok {
+		return nil, errors.New("未知的排行维度")
+	}
+	if n <= 0 || n > 100 {
+		n = 10
+	}
+	return cached(fmt.Sprintf("top|%s|%d|%s", dimension, n, filter.cacheKey()), func() ([]StatsRow, error) {
+		var rows []StatsRow
+		err := statsQuery(filter).
+			Where(dim.key + " IS NOT NULL").
+			Select(fmt.Sprintf("CAST(%s AS CHAR) AS `key`, CAST(COALESCE(%s, '') AS CHAR) AS label, COUNT(*) AS participation, COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS awards", dim.key, dim.label, awardCondition)).
+			Group(dim.key).Group(dim.label).
+			Order("awards DESC, participation DESC").
+			Limit(n).
+			Scan(&rows).Error
+		return rows, err
+	})
+}
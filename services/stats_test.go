@@ -0,0 +1,101 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+)
+
+// seedStats 两个学院各一名学生，参加不同级别和类型的比赛
+func seedStats(t *testing.T) {
+	t.Helper()
+	ClearStatsCache()
+	t.Cleanup(ClearStatsCache)
+	for id, name := range map[int]string{1: "软件学院", 2: "信息学院"} {
+		config.DB.Create(&models.Colleges{ID: id, Name: name})
+		config.DB.Create(&models.Majors{ID: id, CollegeID: id, Name: name + "专业"})
+		config.DB.Create(&models.Classes{ID: id, MajorID: id, Name: name + "2101", Grade: 2021})
+	}
+	sex := 1
+	for i, sid := range []string{"20210001", "20210002"} {
+		classID := i + 1
+		if err := config.DB.Create(&models.Students{SID: sid, Name: sid, Password: "x", Sex: &sex, Grade: 2021, Class: "1", ClassID: &classID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	config.DB.Create(&models.Races{RaceID: 1, Title: "程序设计", Level: 1, Type: "学科竞赛"})
+	config.DB.Create(&models.Races{RaceID: 2, Title: "创新创业", Level: 2, Type: "创新创业"})
+
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return d
+	}
+	records := []models.Records{
+		{RecordID: 1, SID: "20210001", RaceID: 1, Status: models.RecordStatusApproved, Score: "一等奖", CreateTime: day("2023-03-01")},
+		{RecordID: 2, SID: "20210001", RaceID: 2, Status: models.RecordStatusPending, CreateTime: day("2024-03-01")},
+		{RecordID: 3, SID: "20210002", RaceID: 1, Status: models.RecordStatusApproved, Score: "二等奖", CreateTime: day("2024-05-01")},
+		{RecordID: 4, SID: "20210002", RaceID: 2, Status: models.RecordStatusRejected, CreateTime: day("2024-06-01")},
+	}
+	for _, record := range records {
+		if err := config.DB.Create(&record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStatsFilters(t *testing.T) {
+	testutil.OpenDB(t)
+	seedStats(t)
+
+	level, college := 1, 2
+	start, _ := time.ParseInLocation("2006-01-02", "2024-01-01", time.Local)
+	end := start.AddDate(1, 0, 0)
+	cases := []struct {
+		name           string
+		filter         StatsFilter
+		records, award int64
+	}{
+		{"不筛选", StatsFilter{}, 4, 2},
+		{"按时间", StatsFilter{Start: &start, End: &end}, 3, 1},
+		{"按级别", StatsFilter{Level: &level}, 2, 2},
+		{"按类型", StatsFilter{Type: "创新创业"}, 2, 0},
+		{"按学院", StatsFilter{CollegeID: &college}, 2, 1},
+		{"组合条件", StatsFilter{Start: &start, End: &end, Level: &level, CollegeID: &college}, 1, 1},
+	}
+	for _, tc := range cases {
+		summary, err := GetStatsSummary(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Records != tc.records || summary.Awards != tc.award {
+			t.Errorf("%s: 期望参赛 %d 获奖 %d，得到 %+v", tc.name, tc.records, tc.award, summary)
+		}
+	}
+
+	// 分组统计使用相同的筛选条件
+	rows, err := GetStatsGroup("college", StatsFilter{Level: &level})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("应按学院分为两组，得到 %+v", rows)
+	}
+	for _, row := range rows {
+		if row.Participation != 1 || row.Awards != 1 {
+			t.Fatalf("每个学院应各有一条获奖记录，得到 %+v", row)
+		}
+	}
+
+	// 删除的参赛记录不计入统计，清空缓存后生效
+	config.DB.Where("record_id = ?", 1).Delete(&models.Records{})
+	if summary, _ := GetStatsSummary(StatsFilter{}); summary.Records != 4 {
+		t.Fatal("缓存有效期内应返回缓存的结果")
+	}
+	ClearStatsCache()
+	if summary, _ := GetStatsSummary(StatsFilter{}); summary.Records != 3 || summary.Students != 2 {
+		t.Fatalf("软删除的参赛记录不应计入统计，得到 %+v", summary)
+	}
+}
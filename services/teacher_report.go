@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"competition-server/config"
+	"competition-server/models"
+)
+
+// TeacherReport 教师指导工作量及获奖情况
+type TeacherReport struct {
+	TID     string           `json:"tid"`
+	Name    string           `json:"name"`
+	Rank    int              `json:"rank"`
+	Records int64            `json:"records"`  // 指导参赛人次
+	Awards  int64            `json:"awards"`   // 指导获奖人次
+	Points  float64          `json:"points"`   // 按积分规则计算的总积分
+	ByLevel map[int]int64    `json:"by_level"` // 各比赛级别的获奖人次
+	ByAward map[string]int64 `json:"by_award"` // 各获奖等级的人次
+}
+
+// TeacherPoints 按积分规则计算一次获奖的积分
+func TeacherPoints(level int, award string) float64 {
+	if points, ok := config.TeacherPointRules[level][award]; ok {
+		return points
+	}
+	return config.TeacherPointDefault
+}
+
+// BuildTeacherReport 汇总教师在时间范围内的指导记录，tid 为空时包含全部教师，按积分从高到低排序
+func BuildTeacherReport(filter StatsFilter, tid string) ([]TeacherReport, error) {
+	var teachers []models.Teachers
+	teacherQuery := config.DB.Model(&models.Teachers{})
+	if tid != "" {
+		teacherQuery = teacherQuery.Where("tid = ?", tid)
+	}
+	if err := teacherQuery.Order("tid ASC").Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+
+	reports := make(map[string]*TeacherReport)
+	var order []string
+	for _, teacher := range teachers {
+		reports[teacher.TID] = &TeacherReport{
+			TID:     teacher.TID,
+			Name:    teacher.Name,
+			Rank:    teacher.Rank,
+			ByLevel: make(map[int]int64),
+			ByAward: make(map[string]int64),
+		}
+		order = append(order, teacher.TID)
+	}
+
+	var rows []struct {
+		TID    string
+		Level  int
+		Score  string
+		Status int
+		Count  int64
+	}
+	query := statsQuery(filter).Where("records.tid IS NOT NULL AND records.tid <> ''")
+	if tid != "" {
+		query = query.Where("records.tid = ?", tid)
+	}
+	err := query.
+		Select("records.tid AS t_id, COALESCE(races.level, 0) AS level, COALESCE(records.score, '') AS score, records.status AS status, COUNT(*) AS count").
+		Group("records.tid").Group("races.level").Group("records.score").Group("records.status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		report, ok := reports[row.TID]
+		if !ok {
+			continue
+		}
+		report.Records += row.Count
+		if row.Status != models.RecordStatusApproved || row.Score == "" {
+			continue
+		}
+		report.Awards += row.Count
+		report.ByLevel[row.Level] += row.Count
+		report.ByAward[row.Score] += row.Count
+		report.Points += TeacherPoints(row.Level, row.Score) * float64(row.Count)
+	}
+
+	result := make([]TeacherReport, 0, len(order))
+	for _, id := range order {
+		result = append(result, *reports[id])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Points > result[j].Points
+	})
+	return result, nil
+}
+
+// TeacherReportTable 将报表转换为表格，第一行为表头，供导出 xlsx/pdf 使用
+func TeacherReportTable(reports []TeacherReport) [][]string {
+	var levels []int
+	for level := range config.RaceLevelNames {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+
+	header := []string{"工号", "姓名", "职称等级", "指导人次", "获奖人次"}
+	for _, level := range levels {
+		header = append(header, config.RaceLevelNames[level]+"获奖")
+	}
+	header = append(header, "积分")
+
+	table := [][]string{header}
+	for _, report := range reports {
+		row := []string{
+			report.TID,
+			report.Name,
+			strconv.Itoa(report.Rank),
+			strconv.FormatInt(report.Records, 10),
+			strconv.FormatInt(report.Awards, 10),
+		}
+		for _, level := range levels {
+			row = append(row, strconv.FormatInt(report.ByLevel[level], 10))
+		}
+		row = append(row, fmt.Sprintf("%g", report.Points))
+		table = append(table, row)
+	}
+	return table
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/utils"
+	"gorm.io/gorm"
+)
+
+// twoFactorCipher 由 TwoFactorKey 派生的 AES-GCM 密钥，用于加密保存 TOTP 密钥
+func twoFactorCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(config.TwoFactorKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptSecret(secret string) (string, error) {
+	aead, err := twoFactorCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func decryptSecret(encrypted string) (string, error) {
+	aead, err := twoFactorCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("两步验证密钥已损坏")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("两步验证密钥已损坏")
+	}
+	return string(plain), nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// TwoFactorRequired 判断拥有这些权限的用户是否必须启用两步验证
+func TwoFactorRequired(permissions []string) bool {
+	for _, required := range config.TwoFactorRequiredPermissions {
+		for _, p := range permissions {
+			if p == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TwoFactorEnabled 查询用户是否已启用两步验证
+func TwoFactorEnabled(account string) (bool, error) {
+	var count int64
+	err := config.DB.Model(&models.TwoFactors{}).Where("account = ? AND enabled = ?", account, true).Count(&count).Error
+	return count > 0, err
+}
+
+// RemainingRecoveryCodes 查询未使用的恢复码数量
+func RemainingRecoveryCodes(account string) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.TwoFactorRecoveryCodes{}).Where("account = ? AND used_time IS NULL", account).Count(&count).Error
+	return count, err
+}
+
+// SetupTwoFactor 为用户生成新的 TOTP 密钥，返回密钥和验证器配置地址，验证通过后才会启用
+func SetupTwoFactor(account string) (string, string, error) {
+	enabled, err := TwoFactorEnabled(account)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", &config.ValidationError{Message: "已启用两步验证，如需更换请先停用"}
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := encryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := config.DB.Save(&models.TwoFactors{Account: account, Secret: encrypted, CreateTime: time.Now()}).Error; err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPURI(config.TwoFactorIssuer, account, secret), nil
+}
+
+// EnableTwoFactor 校验验证器生成的验证码并启用两步验证，返回新生成的恢复码
+func EnableTwoFactor(account, code string) ([]string, error) {
+	var setting models.TwoFactors
+	if err := config.DB.Where("account = ?", account).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &config.ValidationError{Message: "请先生成两步验证密钥"}
+		}
+		return nil, err
+	}
+	if setting.Enabled {
+		return nil, &config.ValidationError{Message: "已启用两步验证"}
+	}
+	secret, err := decryptSecret(setting.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.VerifyTOTP(secret, code, time.Now(), setting.LastStep)
+	if !ok {
+		return nil, &config.ValidationError{Message: "验证码错误"}
+	}
+
+	var codes []string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.TwoFactors{}).Where("account = ?", account).Updates(map[string]interface{}{
+			"enabled":         true,
+			"last_step":       step,
+			"failed_attempts": 0,
+			"locked_until":    nil,
+			"enabled_time":    now,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, account)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes 作废原有恢复码并生成新的恢复码
+func RegenerateRecoveryCodes(account string) ([]string, error) {
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, account)
+		return err
+	})
+	return codes, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, account string) ([]string, error) {
+	if err := tx.Where("account = ?", account).Delete(&models.TwoFactorRecoveryCodes{}).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	codes := make([]string, 0, config.TwoFactorRecoveryCodes)
+	rows := make([]models.TwoFactorRecoveryCodes, 0, config.TwoFactorRecoveryCodes)
+	for i := 0; i < config.TwoFactorRecoveryCodes; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		// 10 位十六进制，分两段便于抄写
+		raw := hex.EncodeToString(buf)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		rows = append(rows, models.TwoFactorRecoveryCodes{Account: account, CodeHash: hashRecoveryCode(code), CreateTime: now})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactor 校验登录第二步的验证码，可以使用验证器生成的验证码或未使用过的恢复码
+// 连续失败达到上限后锁定一段时间
+func VerifyTwoFactor(account, code string) error {
+	var setting models.TwoFactors
+	if err := config.DB.Where("account = ? AND enabled = ?", account, true).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &config.ValidationError{Message: "未启用两步验证"}
+		}
+		return err
+	}
+	now := time.Now()
+	if setting.LockedUntil != nil && now.Before(*setting.LockedUntil) {
+		return &config.ValidationError{Message: "验证失败次数过多，请于 " + setting.LockedUntil.Format("15:04") + " 后重试"}
+	}
+
+	secret, err := decryptSecret(setting.Secret)
+	if err != nil {
+		return err
+	}
+	if step, ok := utils.VerifyTOTP(secret, code, now, setting.LastStep); ok {
+		// 条件更新，并发请求中同一验证码只有一个能通过
+		result := config.DB.Model(&models.TwoFactors{}).Where("account = ? AND last_step < ?", account, step).
+			Updates(map[string]interface{}{"last_step": step, "failed_attempts": 0, "locked_until": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+	} else if len(strings.TrimSpace(code)) != utils.TOTPDigits {
+		result := config.DB.Model(&models.TwoFactorRecoveryCodes{}).
+			Where("account = ? AND code_hash = ? AND used_time IS NULL", account, hashRecoveryCode(code)).
+			Update("used_time", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return config.DB.Model(&models.TwoFactors{}).Where("account = ?", account).
+				Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error
+		}
+	}
+
+	updates := map[string]interface{}{"failed_attempts": setting.FailedAttempts + 1}
+	if setting.FailedAttempts+1 >= config.TwoFactorMaxAttempts {
+		updates["failed_attempts"] = 0
+		updates["locked_until"] = now.Add(config.TwoFactorLockout)
+	}
+	if err := config.DB.Model(&models.TwoFactors{}).Where("account = ?", account).Updates(updates).Error; err != nil {
+		return err
+	}
+	return &config.ValidationError{Message: "验证码错误"}
+}
+
+// DisableTwoFactor 停用两步验证，删除密钥和恢复码
+func DisableTwoFactor(account string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("account = ?", account).Delete(&models.TwoFactorRecoveryCodes{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("account = ?", account).Delete(&models.TwoFactors{}).Error
+	})
+}
+
+// AuthorizeAccountReset 管理员重置其他用户的密码或两步验证前的校验
+// 模拟登录期间不能操作，目标用户的权限不能超出管理员自身的权限，管理员必须已启用两步验证并提交当前的验证码
+func AuthorizeAccountReset(operator models.AuthenticatedUser, account, code string) error {
+	if operator.ImpersonatedBy != "" {
+		return &config.ValidationError{Message: "模拟登录期间不能重置用户的密码或两步验证"}
+	}
+	var user models.User
+	if err := config.DB.Where("account = ?", account).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &config.ValidationError{Message: "用户账户不存在"}
+		}
+		return err
+	}
+	permissions, err := RolePermissions(user.RoleID)
+	if err != nil {
+		return err
+	}
+	if missing := MissingPermissions(permissions, operator.Permissions); len(missing) > 0 {
+		return &config.ValidationError{Message: "不能重置权限超出自身的用户，缺少: " + strings.Join(missing, ", ")}
+	}
+
+	enabled, err := TwoFactorEnabled(operator.Account)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return &config.ValidationError{Message: "请先为自己的账户启用两步验证"}
+	}
+	if strings.TrimSpace(code) == "" {
+		return &config.ValidationError{Message: "请输入两步验证码"}
+	}
+	return VerifyTwoFactor(operator.Account, code)
+}
+
+// RolePermissions 查询角色拥有的权限，格式为 type:action
+func RolePermissions(roleID int) ([]string, error) {
+	var permissions []models.Permissions
+	if err := config.DB.Where("id IN (?)", config.DB.Model(&models.Rolepermission{}).Select("permission_id").Where("role_id = ?", roleID)).
+		Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		result = append(result, p.Type+":"+p.Action)
+	}
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"competition-server/testutil"
+	"competition-server/utils"
+)
+
+// enableTwoFactor 为账户启用两步验证，启用时使用上一个时间步的验证码，返回密钥和恢复码
+func enableTwoFactor(t *testing.T, account string) (string, []string) {
+	t.Helper()
+	secret, _, err := SetupTwoFactor(account)
+	if err != nil {
+		t.Fatalf("SetupTwoFactor: %v", err)
+	}
+	codes, err := EnableTwoFactor(account, totpCode(t, secret, -1))
+	if err != nil {
+		t.Fatalf("EnableTwoFactor: %v", err)
+	}
+	return secret, codes
+}
+
+// totpCode 计算相对当前时间偏移 offset 个时间步的验证码
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func isValidationError(err error) bool {
+	var validationErr *config.ValidationError
+	return errors.As(err, &validationErr)
+}
+
+func TestVerifyTwoFactorRejectsReplayedCode(t *testing.T) {
+	testutil.OpenDB(t)
+	secret, _ := enableTwoFactor(t, "alice")
+
+	code := totpCode(t, secret, 0)
+	if err := VerifyTwoFactor("alice", code); err != nil {
+		t.Fatalf("首次使用验证码应通过: %v", err)
+	}
+	if err := VerifyTwoFactor("alice", code); !isValidationError(err) {
+		t.Fatalf("重复使用验证码应被拒绝，得到 %v", err)
+	}
+}
+
+func TestVerifyTwoFactorRecoveryCodeSingleUse(t *testing.T) {
+	testutil.OpenDB(t)
+	_, codes := enableTwoFactor(t, "alice")
+
+	if err := VerifyTwoFactor("alice", codes[0]); err != nil {
+		t.Fatalf("恢复码应通过: %v", err)
+	}
+	if err := VerifyTwoFactor("alice", codes[0]); !isValidationError(err) {
+		t.Fatalf("恢复码只能使用一次，得到 %v", err)
+	}
+	remaining, err := RemainingRecoveryCodes("alice")
+	if err != nil || remaining != int64(config.TwoFactorRecoveryCodes-1) {
+		t.Fatalf("剩余恢复码 = %d, %v", remaining, err)
+	}
+}
+
+func TestVerifyTwoFactorLocksAfterMaxAttempts(t *testing.T) {
+	testutil.OpenDB(t)
+	secret, _ := enableTwoFactor(t, "alice")
+
+	for i := 0; i < config.TwoFactorMaxAttempts; i++ {
+		if err := VerifyTwoFactor("alice", "000000x"); !isValidationError(err) {
+			t.Fatalf("错误验证码应被拒绝，得到 %v", err)
+		}
+	}
+	if err := VerifyTwoFactor("alice", totpCode(t, secret, 0)); err == nil {
+		t.Fatal("锁定期间正确的验证码也应被拒绝")
+	}
+	var setting models.TwoFactors
+	config.DB.Where("account = ?", "alice").First(&setting)
+	if setting.LockedUntil == nil || !setting.LockedUntil.After(time.Now()) {
+		t.Fatalf("应记录锁定时间，得到 %v", setting.LockedUntil)
+	}
+}
+
+func TestAuthorizeAccountReset(t *testing.T) {
+	testutil.OpenDB(t)
+	testutil.SeedRole(t, 2, "user:update", "user:query")
+	testutil.SeedRole(t, 3, "user:query")
+	testutil.SeedRole(t, 1, "user:update", "user:query", "role:update")
+	testutil.CreateUser(t, "operator", "teacher", 2, "pw")
+	testutil.CreateUser(t, "student", "student", 3, "pw")
+	testutil.CreateUser(t, "admin", "student", 1, "pw")
+	operator := models.AuthenticatedUser{Account: "operator", Permissions: []string{"user:update", "user:query"}}
+
+	if err := AuthorizeAccountReset(operator, "student", ""); !isValidationError(err) {
+		t.Fatalf("管理员未启用两步验证时应被拒绝，得到 %v", err)
+	}
+	secret, _ := enableTwoFactor(t, "operator")
+
+	if err := AuthorizeAccountReset(operator, "admin", totpCode(t, secret, 0)); !isValidationError(err) {
+		t.Fatalf("不能重置权限超出自身的用户，得到 %v", err)
+	}
+	if err := AuthorizeAccountReset(operator, "student", ""); !isValidationError(err) {
+		t.Fatalf("未提交验证码时应被拒绝，得到 %v", err)
+	}
+	if err := AuthorizeAccountReset(operator, "student", "123"); !isValidationError(err) {
+		t.Fatalf("验证码错误时应被拒绝，得到 %v", err)
+	}
+	code := totpCode(t, secret, 0)
+	if err := AuthorizeAccountReset(operator, "student", code); err != nil {
+		t.Fatalf("校验应通过: %v", err)
+	}
+	if err := AuthorizeAccountReset(operator, "student", code); !isValidationError(err) {
+		t.Fatalf("同一验证码不能再次使用，得到 %v", err)
+	}
+
+	impersonating := operator
+	impersonating.ImpersonatedBy = "root"
+	if err := AuthorizeAccountReset(impersonating, "student", totpCode(t, secret, 1)); !isValidationError(err) {
+		t.Fatalf("模拟登录期间应被拒绝，得到 %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Package testutil 测试共用的内存数据库及测试数据，只在 *_test.go 中引用
+package testutil
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"competition-server/config"
+	"competition-server/models"
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// allModels 测试数据库中创建的全部表
+var allModels = []interface{}{
+	&models.Roles{}, &models.Permissions{}, &models.Rolepermission{}, &models.User{},
+	&models.Races{}, &models.Students{}, &models.Teachers{}, &models.Colleges{}, &models.Majors{}, &models.Classes{},
+	&models.Records{}, &models.Attachments{}, &models.Files{}, &models.AuditLogs{},
+	&models.CertificateTemplates{}, &models.Certificates{}, &models.CreditRuleSets{}, &models.CreditRules{},
+	&models.TwoFactors{}, &models.TwoFactorRecoveryCodes{}, &models.ServiceAccounts{}, &models.APIKeys{}, &models.APIKeyUsage{},
+	&models.UserSessions{}, &models.PasswordResetTokens{}, &models.EmailVerifications{}, &models.Revisions{},
+	&models.Notifications{}, &models.NotificationPreferences{}, &models.MailQueue{},
+}
+
+var dbSeq int64
+
+// OpenDB 创建独立的内存 SQLite 数据库并替换 config.DB，测试结束后恢复
+// MySQL 的 enum 类型在 SQLite 中建为 text
+func OpenDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared", atomic.AddInt64(&dbSeq, 1))
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	for _, model := range allModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("解析模型失败: %v", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if strings.HasPrefix(string(field.DataType), "enum(") {
+				field.DataType = "text"
+			}
+		}
+		if err := db.AutoMigrate(model); err != nil {
+			t.Fatalf("创建表失败 %T: %v", model, err)
+		}
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// SeedRole 创建角色及其权限，权限格式为 type:action
+func SeedRole(t *testing.T, roleID int, permissions ...string) {
+	t.Helper()
+	if err := config.DB.Create(&models.Roles{ID: roleID, Label: fmt.Sprintf("role%d", roleID)}).Error; err != nil {
+		t.Fatalf("创建角色失败: %v", err)
+	}
+	for _, p := range permissions {
+		typ, action, _ := strings.Cut(p, ":")
+		var permission models.Permissions
+		if err := config.DB.Where(models.Permissions{Type: typ, Action: action}).
+			Attrs(models.Permissions{Label: p}).FirstOrCreate(&permission).Error; err != nil {
+			t.Fatalf("创建权限失败: %v", err)
+		}
+		if err := config.DB.Create(&models.Rolepermission{RoleID: roleID, PermissionID: permission.ID}).Error; err != nil {
+			t.Fatalf("分配权限失败: %v", err)
+		}
+	}
+}
+
+// CreateUser 创建用户，密码以 bcrypt 摘要保存
+func CreateUser(t *testing.T, account, identity string, roleID int, password string) models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := models.User{Account: account, Password: string(hash), Identity: identity, RoleID: roleID, CreatedAt: now, UpdatedAt: now}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
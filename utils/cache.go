@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// TTLCache 带过期时间的内存缓存，用于缓存统计等计算量较大的查询结果
+type TTLCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+// NewTTLCache 创建缓存，ttl 为每个条目的有效期
+func NewTTLCache(ttl time.Duration) *TTLCache {
+	return &TTLCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// Get 获取未过期的缓存值
+func (c *TTLCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expireAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set 写入缓存，顺带清理已过期的条目
+func (c *TTLCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expireAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{value: value, expireAt: now.Add(c.ttl)}
+}
+
+// Clear 清空缓存
+func (c *TTLCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrCASInvalidTicket CAS 服务器拒绝了票据，票据无效、已使用或与 service 不匹配
+var ErrCASInvalidTicket = errors.New("CAS 票据无效")
+
+// CASClient CAS 3.0 协议客户端
+type CASClient struct {
+	ServerURL string
+	Client    *http.Client
+}
+
+// casServiceResponse /p3/serviceValidate 的响应，按本地名称匹配 cas: 命名空间下的元素
+type casServiceResponse struct {
+	XMLName xml.Name `xml:"serviceResponse"`
+	Success *struct {
+		User       string `xml:"user"`
+		Attributes struct {
+			Values []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"attributes"`
+	} `xml:"authenticationSuccess"`
+	Failure *struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"authenticationFailure"`
+}
+
+// LoginURL CAS 登录页地址，登录后 CAS 服务器带 ticket 参数跳转回 service
+func (c *CASClient) LoginURL(service string) string {
+	return strings.TrimRight(c.ServerURL, "/") + "/login?service=" + url.QueryEscape(service)
+}
+
+// ValidateTicket 校验票据，返回用户名和属性，service 必须与登录时使用的地址完全一致
+func (c *CASClient) ValidateTicket(service, ticket string) (string, map[string]string, error) {
+	if ticket == "" {
+		return "", nil, ErrCASInvalidTicket
+	}
+	endpoint := strings.TrimRight(c.ServerURL, "/") + "/p3/serviceValidate?" + url.Values{
+		"service": {service},
+		"ticket":  {ticket},
+	}.Encode()
+	resp, err := c.Client.Get(endpoint)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("CAS 票据校验请求失败: %s", resp.Status)
+	}
+
+	var result casServiceResponse
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", nil, fmt.Errorf("解析 CAS 响应失败: %v", err)
+	}
+	if result.Failure != nil {
+		return "", nil, fmt.Errorf("%w: %s %s", ErrCASInvalidTicket, result.Failure.Code, strings.TrimSpace(result.Failure.Message))
+	}
+	if result.Success == nil || strings.TrimSpace(result.Success.User) == "" {
+		return "", nil, errors.New("CAS 响应中缺少用户信息")
+	}
+
+	attributes := make(map[string]string)
+	for _, attr := range result.Success.Attributes.Values {
+		// 多值属性只保留第一个值
+		if _, ok := attributes[attr.XMLName.Local]; !ok {
+			attributes[attr.XMLName.Local] = strings.TrimSpace(attr.Value)
+		}
+	}
+	return strings.TrimSpace(result.Success.User), attributes, nil
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"competition-server/config"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/mojocn/base64Captcha"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// 证书模板支持的文字字段
+var CertificateFieldKeys = map[string]bool{
+	"student_name": true, // 学生姓名
+	"race_title":   true, // 比赛名称
+	"award":        true, // 获奖等级
+	"date":         true, // 颁发日期
+	"serial":       true, // 证书编号
+	"verify_code":  true, // 验证码
+	"verify_url":   true, // 验证地址
+}
+
+// CertificateField 证书模板中的一个文字位置，(X, Y) 为文字基线的锚点
+type CertificateField struct {
+	Key   string  `json:"key"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Size  float64 `json:"size"`  // 字号（像素）
+	Color string  `json:"color"` // #RRGGBB，默认黑色
+	Align string  `json:"align"` // left/center/right，默认 left
+}
+
+// ParseCertificateFields 解析并校验模板的字段配置
+func ParseCertificateFields(data string) ([]CertificateField, error) {
+	var fields []CertificateField
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, &config.ValidationError{Message: "字段配置格式有误"}
+	}
+	for _, field := range fields {
+		if !CertificateFieldKeys[field.Key] {
+			return nil, &config.ValidationError{Message: "未知的证书字段: " + field.Key}
+		}
+		if field.Size <= 0 {
+			return nil, &config.ValidationError{Message: "字号必须大于0"}
+		}
+		if _, err := parseHexColor(field.Color); err != nil {
+			return nil, &config.ValidationError{Message: "颜色格式有误: " + field.Color}
+		}
+		switch field.Align {
+		case "", "left", "center", "right":
+		default:
+			return nil, &config.ValidationError{Message: "对齐方式有误: " + field.Align}
+		}
+	}
+	return fields, nil
+}
+
+// fontCoverageSample 校验字体是否包含常用中文字形的样本
+const fontCoverageSample = "张王李刘陈杨获奖证书一二三等奖学年度竞赛档案"
+
+var (
+	fontData *truetype.Font
+	fontPath string
+	fontMu   sync.Mutex
+)
+
+// InitFont 启动时加载并校验绘图字体，字体缺失或不含中文字形时返回错误
+func InitFont() error {
+	_, err := loadFont()
+	return err
+}
+
+// loadFont 加载绘图字体，未配置字体文件时使用验证码组件内置的文泉驿微米黑
+func loadFont() (*truetype.Font, error) {
+	fontMu.Lock()
+	defer fontMu.Unlock()
+	if fontData != nil && fontPath == config.CertificateFontPath {
+		return fontData, nil
+	}
+
+	var ttf *truetype.Font
+	if config.CertificateFontPath == "" {
+		ttf = base64Captcha.DefaultEmbeddedFonts.LoadFontByName("fonts/wqy-microhei.ttc")
+	} else {
+		data, err := os.ReadFile(config.CertificateFontPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取字体文件失败: %v", err)
+		}
+		if ttf, err = truetype.Parse(data); err != nil {
+			return nil, fmt.Errorf("字体文件格式有误: %v", err)
+		}
+	}
+	if missing := missingGlyphs(ttf, fontCoverageSample); missing != "" {
+		return nil, fmt.Errorf("字体文件 %s 不包含中文字形（缺少 %s），请改用思源黑体等中文字体", config.CertificateFontPath, missing)
+	}
+	fontData, fontPath = ttf, config.CertificateFontPath
+	return fontData, nil
+}
+
+// missingGlyphs 返回字体中没有字形的字符，空白和控制字符不需要字形
+func missingGlyphs(ttf *truetype.Font, text string) string {
+	var missing []rune
+	for _, r := range text {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) || ttf.Index(r) != 0 {
+			continue
+		}
+		if !strings.ContainsRune(string(missing), r) {
+			missing = append(missing, r)
+		}
+	}
+	return string(missing)
+}
+
+// checkGlyphs 确认字体能绘制全部文字，避免生僻字被画成方框
+func checkGlyphs(ttf *truetype.Font, texts ...string) error {
+	if missing := missingGlyphs(ttf, strings.Join(texts, "")); missing != "" {
+		return &config.ValidationError{Message: "字体缺少以下文字的字形: " + missing + "，请联系管理员更换字体"}
+	}
+	return nil
+}
+
+// RenderCertificate 在背景图上按模板位置绘制文字
+func RenderCertificate(background image.Image, fields []CertificateField, values map[string]string) (*image.RGBA, error) {
+	ttf, err := loadFont()
+	if err != nil {
+		return nil, err
+	}
+
+	var texts []string
+	for _, field := range fields {
+		texts = append(texts, values[field.Key])
+	}
+	if err := checkGlyphs(ttf, texts...); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(background.Bounds())
+	draw.Draw(img, img.Bounds(), background, background.Bounds().Min, draw.Src)
+
+	for _, field := range fields {
+		text := values[field.Key]
+		if text == "" {
+			continue
+		}
+		c, _ := parseHexColor(field.Color)
+		face := truetype.NewFace(ttf, &truetype.Options{Size: field.Size, DPI: 72, Hinting: font.HintingFull})
+		drawer := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+
+		x := fixed.Int26_6(field.X * 64)
+		switch field.Align {
+		case "center":
+			x -= drawer.MeasureString(text) / 2
+		case "right":
+			x -= drawer.MeasureString(text)
+		}
+		drawer.Dot = fixed.Point26_6{X: x + fixed.I(img.Bounds().Min.X), Y: fixed.Int26_6(field.Y*64) + fixed.I(img.Bounds().Min.Y)}
+		drawer.DrawString(text)
+		face.Close()
+	}
+	return img, nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	if s == "" {
+		return color.RGBA{A: 255}, nil
+	}
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
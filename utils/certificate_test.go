@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"competition-server/config"
+)
+
+func blankBackground() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	return img
+}
+
+func TestRenderCertificateDrawsChineseName(t *testing.T) {
+	useFontPath(t, "")
+	fields, err := ParseCertificateFields(`[{"key":"student_name","x":400,"y":200,"size":48,"align":"center"},{"key":"award","x":400,"y":300,"size":32,"color":"#cc0000","align":"center"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := RenderCertificate(blankBackground(), fields, map[string]string{"student_name": "欧阳娜娜", "award": "一等奖"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 姓名以 x=400 居中，基线在 y=200
+	left := inkedPixels(img, image.Rect(300, 150, 400, 205))
+	right := inkedPixels(img, image.Rect(400, 150, 500, 205))
+	if left == 0 || right == 0 {
+		t.Fatalf("姓名应居中绘制，左侧 %d 右侧 %d 个像素", left, right)
+	}
+	if inkedPixels(img, image.Rect(0, 0, 800, 140)) != 0 {
+		t.Fatal("姓名上方不应有文字")
+	}
+	if inkedPixels(img, image.Rect(330, 270, 470, 305)) == 0 {
+		t.Fatal("应绘制获奖等级")
+	}
+
+	// 字形与 .notdef（方框）不同，说明确实画出了中文而不是占位符
+	ttf, _ := loadFont()
+	for _, r := range "欧阳娜娜一等奖" {
+		if ttf.Index(r) == 0 {
+			t.Fatalf("字体缺少 %q 的字形", r)
+		}
+	}
+}
+
+func TestRenderCertificateRejectsMissingGlyphs(t *testing.T) {
+	useFontPath(t, "")
+	fields := []CertificateField{{Key: "student_name", X: 10, Y: 100, Size: 32}}
+	_, err := RenderCertificate(blankBackground(), fields, map[string]string{"student_name": "张\U0001F600"})
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("字体缺少字形时应拒绝生成证书，得到 %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"competition-server/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SetCookie 按 config 中的 Secure、SameSite 和域名设置写入 Cookie，maxAge 小于 0 表示删除
+func SetCookie(c *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	c.SetSameSite(config.CookieSameSite)
+	c.SetCookie(name, value, maxAge, "/", config.CookieDomain, config.CookieSecure, httpOnly)
+}
+
+// IssueCSRFToken 生成新的 CSRF 令牌并写入前端可读取的 Cookie
+func IssueCSRFToken(c *gin.Context) (string, error) {
+	token, err := RandomToken()
+	if err != nil {
+		return "", err
+	}
+	SetCookie(c, config.CSRFCookieName, token, int(config.CSRFTokenTTL.Seconds()), false)
+	return token, nil
+}
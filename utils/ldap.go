@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrLDAPInvalidCredentials LDAP 服务器返回 invalidCredentials(49)，即账号或密码错误
+var ErrLDAPInvalidCredentials = errors.New("LDAP 账号或密码错误")
+
+// LDAPBinder 通过 LDAP 简单绑定校验账号密码
+// URL 为 ldaps:// 时直接建立 TLS 连接；为 ldap:// 时 StartTLS 为 true 则先升级为 TLS，否则以明文传输密码
+// CAFile 为校验服务器证书的 CA 证书（PEM），为空时使用系统证书
+type LDAPBinder struct {
+	URL      string
+	StartTLS bool
+	CAFile   string
+	Timeout  time.Duration
+}
+
+// EscapeLDAPDN 转义 DN 中的属性值（RFC 4514）
+func EscapeLDAPDN(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			r == '#' && i == 0,
+			r == ' ' && (i == 0 || i == len(value)-1):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// tlsConfig 校验服务器证书的 TLS 配置，证书中的主机名必须与 URL 一致
+func (l *LDAPBinder) tlsConfig(host string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if l.CAFile != "" {
+		pem, err := os.ReadFile(l.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 LDAP CA 证书失败: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("LDAP CA 证书格式有误")
+		}
+	}
+	return config, nil
+}
+
+// Bind 以指定 DN 和密码进行简单绑定，绑定成功表示账号密码正确
+func (l *LDAPBinder) Bind(dn, password string) error {
+	// 空密码会被服务器当作匿名绑定而成功，必须拒绝
+	if password == "" {
+		return ErrLDAPInvalidCredentials
+	}
+
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return fmt.Errorf("LDAP 地址有误: %v", err)
+	}
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+	}
+	tlsConfig, err := l.tlsConfig(host)
+	if err != nil {
+		return err
+	}
+
+	conn, err := ldap.DialURL(l.URL, ldap.DialWithDialer(&net.Dialer{Timeout: l.Timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetTimeout(l.Timeout)
+
+	if u.Scheme == "ldap" && l.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("LDAP StartTLS 失败: %v", err)
+		}
+	}
+
+	err = conn.Bind(dn, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return ErrLDAPInvalidCredentials
+	}
+	if err != nil {
+		return fmt.Errorf("LDAP 绑定失败: %v", err)
+	}
+	// 断开前发送 UnbindRequest，失败不影响认证结果
+	conn.Unbind()
+	return nil
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage 本地磁盘存储后端，文件通过带签名的 gin 路由上传和下载
+type LocalStorage struct {
+	root    string
+	baseURL string
+	signKey []byte
+}
+
+// NewLocalStorage 创建本地存储，根目录不存在时自动创建
+func NewLocalStorage(root, baseURL, signKey string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		signKey: []byte(signKey),
+	}, nil
+}
+
+// PresignUpload 生成带签名的上传链接，使用 PUT 请求体或 POST 表单的 file 字段上传
+func (l *LocalStorage) PresignUpload(key string, expires time.Duration) (*UploadCredential, error) {
+	if _, err := l.resolve(key); err != nil {
+		return nil, err
+	}
+	return &UploadCredential{URL: l.signedURL("upload", key, expires)}, nil
+}
+
+// PresignDownload 生成带签名的下载链接
+func (l *LocalStorage) PresignDownload(key string, expires time.Duration) string {
+	return l.signedURL("download", key, expires)
+}
+
+// Put 写入文件，本地存储不记录 MIME 类型，读取时重新探测
+func (l *LocalStorage) Put(key string, r io.Reader, size int64, mimeType string) error {
+	return l.Save(key, r)
+}
+
+// Get 读取文件内容
+func (l *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	return l.Open(key)
+}
+
+// Stat 获取文件信息，Hash 为文件内容的 SHA-256
+func (l *LocalStorage) Stat(key string) (*FileInfo, error) {
+	filename, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, os.ErrNotExist
+	}
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	hash := sha256.New()
+	hash.Write(head[:n])
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+
+	return &FileInfo{
+		Fsize:    stat.Size(),
+		Hash:     hex.EncodeToString(hash.Sum(nil)),
+		MimeType: http.DetectContentType(head[:n]),
+		PutTime:  stat.ModTime().UnixNano() / 100, // 与七牛云一致，单位为100纳秒
+	}, nil
+}
+
+// Delete 批量删除文件，文件不存在时忽略
+func (l *LocalStorage) Delete(keys []string) error {
+	for _, key := range keys {
+		filename, err := l.resolve(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh 本地存储没有 CDN 缓存，无需刷新
+func (l *LocalStorage) Refresh(key string) error {
+	return nil
+}
+
+// List 遍历根目录下的文件，跳过未写完的临时文件，Hash 和 MimeType 不计算
+func (l *LocalStorage) List(prefix string, fn func(key string, info FileInfo) error) error {
+	return filepath.WalkDir(l.root, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, filename)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(key, FileInfo{Fsize: stat.Size(), PutTime: stat.ModTime().UnixNano() / 100})
+	})
+}
+
+// Open 打开文件用于下载
+func (l *LocalStorage) Open(key string) (*os.File, error) {
+	filename, err := l.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filename)
+}
+
+// Save 写入文件，先写临时文件再重命名，避免读到写了一半的文件
+func (l *LocalStorage) Save(key string, r io.Reader) error {
+	filename, err := l.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Verify 校验签名链接的操作类型（upload/download）、有效期和签名
+func (l *LocalStorage) Verify(op, key, expires, sign string) error {
+	deadline, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("无效的链接")
+	}
+	if time.Now().Unix() > deadline {
+		return errors.New("链接已过期")
+	}
+	expected := l.sign(op, key, deadline)
+	if !hmac.Equal([]byte(expected), []byte(sign)) {
+		return errors.New("签名错误")
+	}
+	return nil
+}
+
+func (l *LocalStorage) signedURL(op, key string, expires time.Duration) string {
+	deadline := time.Now().Add(expires).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(deadline, 10))
+	query.Set("sign", l.sign(op, key, deadline))
+	return fmt.Sprintf("%s/%s?%s", l.baseURL, (&url.URL{Path: key}).EscapedPath(), query.Encode())
+}
+
+func (l *LocalStorage) sign(op, key string, deadline int64) string {
+	mac := hmac.New(sha256.New, l.signKey)
+	fmt.Fprintf(mac, "%s\n%s\n%d", op, key, deadline)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resolve 将对象名转换为根目录下的文件路径，拒绝绝对路径和 .. 等越界访问
+func (l *LocalStorage) resolve(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", errors.New("非法的文件名")
+	}
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.New("非法的文件名")
+	}
+	return filepath.Join(l.root, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	local, err := NewLocalStorage(filepath.Join(t.TempDir(), "files"), "http://localhost/storage/local/", "sign-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return local
+}
+
+// signedQuery 解析签名链接中的对象名和查询参数
+func signedQuery(t *testing.T, link string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimPrefix(u.Path, "/storage/local/"), u.Query()
+}
+
+func TestLocalStorageResolveRejectsTraversal(t *testing.T) {
+	local := newTestLocalStorage(t)
+	valid := map[string]string{
+		"a.pdf":                   "a.pdf",
+		"evidence/20210001/a.pdf": filepath.Join("evidence", "20210001", "a.pdf"),
+		"a..b/c":                  filepath.Join("a..b", "c"),
+	}
+	for key, want := range valid {
+		got, err := local.resolve(key)
+		if err != nil || got != filepath.Join(local.root, want) {
+			t.Errorf("resolve(%q) = %q, %v", key, got, err)
+		}
+	}
+
+	for _, key := range []string{
+		"", ".", "..", "../secret", "../../etc/passwd", "a/../../secret", "a/../b",
+		"/etc/passwd", `..\secret`, `a\b`, "./a", "a//b", "a/", "a/./b",
+	} {
+		if got, err := local.resolve(key); err == nil {
+			t.Errorf("resolve(%q) 应被拒绝，得到 %q", key, got)
+		}
+	}
+
+	// 越界的对象名不能写入、读取或删除根目录之外的文件
+	outside := filepath.Join(filepath.Dir(local.root), "secret")
+	os.WriteFile(outside, []byte("secret"), 0o644)
+	if err := local.Save("../secret", strings.NewReader("x")); err == nil {
+		t.Fatal("不能写入根目录之外")
+	}
+	if _, err := local.Open("../secret"); err == nil {
+		t.Fatal("不能读取根目录之外")
+	}
+	if err := local.Delete([]string{"../secret"}); err == nil {
+		t.Fatal("不能删除根目录之外")
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "secret" {
+		t.Fatal("根目录之外的文件不应被改动")
+	}
+	if _, err := local.PresignUpload("../secret", time.Hour); err == nil {
+		t.Fatal("越界的对象名不应生成上传链接")
+	}
+}
+
+func TestLocalStorageSignedURL(t *testing.T) {
+	local := newTestLocalStorage(t)
+	credential, err := local.PresignUpload("evidence/张三/a b.pdf", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, query := signedQuery(t, credential.URL)
+	if key != "evidence/张三/a b.pdf" {
+		t.Fatalf("链接中的对象名有误: %q", key)
+	}
+	if err := local.Verify("upload", key, query.Get("expires"), query.Get("sign")); err != nil {
+		t.Fatalf("签名应有效: %v", err)
+	}
+
+	cases := []struct {
+		name                   string
+		op, key, expires, sign string
+	}{
+		{"上传链接不能用于下载", "download", key, query.Get("expires"), query.Get("sign")},
+		{"不能改为其他对象", "upload", "evidence/张三/b.pdf", query.Get("expires"), query.Get("sign")},
+		{"不能延长有效期", "upload", key, query.Get("expires") + "0", query.Get("sign")},
+		{"有效期格式错误", "upload", key, "abc", query.Get("sign")},
+		{"签名错误", "upload", key, query.Get("expires"), strings.Repeat("0", 64)},
+		{"缺少签名", "upload", key, query.Get("expires"), ""},
+	}
+	for _, tc := range cases {
+		if err := local.Verify(tc.op, tc.key, tc.expires, tc.sign); err == nil {
+			t.Errorf("%s: 校验应失败", tc.name)
+		}
+	}
+
+	other, _ := NewLocalStorage(t.TempDir(), "http://localhost/storage/local", "other-key")
+	if err := other.Verify("upload", key, query.Get("expires"), query.Get("sign")); err == nil {
+		t.Fatal("不同签名密钥签发的链接应无效")
+	}
+
+	_, expired := signedQuery(t, local.PresignDownload("a.pdf", -time.Second))
+	if err := local.Verify("download", "a.pdf", expired.Get("expires"), expired.Get("sign")); err == nil {
+		t.Fatal("过期的链接应无效")
+	}
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	local := newTestLocalStorage(t)
+	content := []byte("%PDF-1.4 test")
+	if err := local.Put("evidence/20210001/a.pdf", bytes.NewReader(content), int64(len(content)), "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	// 未写完的临时文件不应被列出
+	os.WriteFile(filepath.Join(local.root, "evidence", "20210001", ".upload-123"), []byte("x"), 0o644)
+
+	info, err := local.Stat("evidence/20210001/a.pdf")
+	if err != nil || info.Fsize != int64(len(content)) || info.MimeType != "application/pdf" {
+		t.Fatalf("文件信息有误: %+v, %v", info, err)
+	}
+	reader, err := local.Get("evidence/20210001/a.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(data, content) {
+		t.Fatal("读取的内容有误")
+	}
+	if _, err := local.Stat("evidence/20210001"); err == nil {
+		t.Fatal("目录不是文件")
+	}
+
+	var keys []string
+	local.List("evidence/", func(key string, info FileInfo) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if len(keys) != 1 || keys[0] != "evidence/20210001/a.pdf" {
+		t.Fatalf("列出的文件有误: %v", keys)
+	}
+
+	if err := local.Delete([]string{"evidence/20210001/a.pdf", "missing.pdf"}); err != nil {
+		t.Fatalf("删除不存在的文件应忽略: %v", err)
+	}
+	if _, err := local.Stat("evidence/20210001/a.pdf"); err == nil {
+		t.Fatal("文件应已删除")
+	}
+}
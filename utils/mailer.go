@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"competition-server/config"
+	"github.com/rs/zerolog/log"
+)
+
+// MailMessage 一封 HTML 邮件
+type MailMessage struct {
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Mailer 邮件发送接口，SMTP 和日志两种实现
+type Mailer interface {
+	Send(msg *MailMessage) error
+}
+
+// MailSender 当前使用的邮件发送实现，由 InitMailer 根据配置初始化
+var MailSender Mailer
+
+// InitMailer 根据配置选择邮件发送实现
+func InitMailer() error {
+	switch config.MailBackend {
+	case "smtp":
+		MailSender = &SMTPMailer{
+			Addr:     config.SMTPHost + ":" + strconv.Itoa(config.SMTPPort),
+			Host:     config.SMTPHost,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.MailFrom,
+		}
+	case "log":
+		MailSender = LogMailer{}
+	default:
+		return fmt.Errorf("未知的邮件发送方式: %s", config.MailBackend)
+	}
+	return nil
+}
+
+// SMTPMailer 通过 SMTP 发送邮件，Username 为空时不认证
+type SMTPMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(msg *MailMessage) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Addr, auth, from.Address, []string{msg.To}, buildMail(from.String(), msg))
+}
+
+// buildMail 组装 MIME 邮件，主题按 RFC 2047 编码以支持中文
+func buildMail(from string, msg *MailMessage) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mimeEncodeWord(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.HTML, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func mimeEncodeWord(s string) string {
+	for _, r := range s {
+		if r > 127 {
+			return mime.BEncoding.Encode("UTF-8", s)
+		}
+	}
+	return s
+}
+
+// LogMailer 只把邮件写入日志，用于开发环境
+type LogMailer struct{}
+
+// Send 记录邮件内容
+func (LogMailer) Send(msg *MailMessage) error {
+	log.Info().Str("to", msg.To).Str("subject", msg.Subject).Msg("邮件（未实际发送）")
+	return nil
+}
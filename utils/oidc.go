@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"competition-server/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OIDCProvider OpenID Connect 授权码模式客户端，端点地址通过 Issuer 的发现文档获取
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Client       *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+	fetched   time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcKeysTTL 发现文档和签名公钥的缓存时间，遇到未知的 kid 时提前刷新
+const oidcKeysTTL = time.Hour
+
+// OIDC 当前使用的 OIDC 客户端，未启用单点登录时为 nil
+var OIDC *OIDCProvider
+
+// InitOIDC 根据配置初始化 OIDC 客户端
+func InitOIDC() {
+	if !config.OIDCEnabled {
+		return
+	}
+	OIDC = &OIDCProvider{
+		Issuer:       strings.TrimRight(config.OIDCIssuer, "/"),
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  config.OIDCRedirectURL,
+		Scopes:       config.OIDCScopes,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// RandomToken 生成 URL 安全的随机字符串，用于 state、nonce 和 PKCE
+func RandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// PKCEChallenge 计算 PKCE 的 S256 code_challenge
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *OIDCProvider) getJSON(endpoint string, v interface{}) error {
+	resp, err := p.Client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 失败: %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// load 获取发现文档和签名公钥，force 为 true 时忽略缓存
+func (p *OIDCProvider) load(force bool) (*oidcDiscovery, map[string]*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !force && p.discovery != nil && time.Since(p.fetched) < oidcKeysTTL {
+		return p.discovery, p.keys, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, nil, err
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != p.Issuer {
+		return nil, nil, fmt.Errorf("发现文档中的 issuer 不一致: %s", discovery.Issuer)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}
+	}
+	if len(keys) == 0 {
+		return nil, nil, errors.New("未获取到可用的签名公钥")
+	}
+
+	p.discovery, p.keys, p.fetched = &discovery, keys, time.Now()
+	return p.discovery, p.keys, nil
+}
+
+// AuthCodeURL 生成跳转到身份提供方登录页的地址
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	discovery, _, err := p.load(false)
+	if err != nil {
+		return "", err
+	}
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientID)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("scope", strings.Join(p.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", PKCEChallenge(verifier))
+	values.Set("code_challenge_method", "S256")
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange 使用授权码换取 ID Token
+func (p *OIDCProvider) Exchange(code, verifier string) (string, error) {
+	discovery, _, err := p.load(false)
+	if err != nil {
+		return "", err
+	}
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("code_verifier", verifier)
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析令牌响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Error != "" {
+		return "", fmt.Errorf("换取令牌失败: %s %s", result.Error, result.ErrorDescription)
+	}
+	if result.IDToken == "" {
+		return "", errors.New("令牌响应中缺少 id_token")
+	}
+	return result.IDToken, nil
+}
+
+// VerifyIDToken 校验 ID Token 的签名、issuer、audience、有效期和 nonce，返回其中的声明
+func (p *OIDCProvider) VerifyIDToken(raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("不支持的签名方法: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		_, keys, err := p.load(false)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keys[kid]
+		if !ok && kid == "" && len(keys) == 1 {
+			for _, only := range keys {
+				key, ok = only, true
+			}
+		}
+		if !ok {
+			// 身份提供方可能已轮换密钥，刷新后再查找一次
+			if _, keys, err = p.load(true); err != nil {
+				return nil, err
+			}
+			if key, ok = keys[kid]; !ok {
+				return nil, fmt.Errorf("未知的签名公钥: %s", kid)
+			}
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != p.Issuer {
+		return nil, fmt.Errorf("issuer 不匹配: %s", iss)
+	}
+	if !claims.VerifyAudience(p.ClientID, true) {
+		return nil, errors.New("audience 不匹配")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id_token 缺少过期时间")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("nonce 不匹配")
+	}
+	return claims, nil
+}
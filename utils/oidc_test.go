@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// mockIdP 模拟的 OIDC 身份提供方，提供发现文档、JWKS 和令牌端点
+type mockIdP struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	verifier string // 令牌端点期望的 PKCE code_verifier
+	idToken  string // 令牌端点返回的 ID Token
+	jwksHits int
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, kid: "k1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksHits++
+		e := big.NewInt(int64(idp.key.E)).Bytes()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": idp.kid, "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(e),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("code_verifier") != idp.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Issuer:       idp.server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+		Scopes:       []string{"openid"},
+		Client:       idp.server.Client(),
+	}
+}
+
+// sign 使用身份提供方的私钥签发 ID Token，overrides 覆盖默认声明
+func (idp *mockIdP) sign(t *testing.T, overrides jwt.MapClaims) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   "client",
+		"sub":   "20210001",
+		"nonce": "n1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.kid
+	raw, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+
+	claims, err := p.VerifyIDToken(idp.sign(t, nil), "n1")
+	if err != nil {
+		t.Fatalf("有效的 ID Token 应通过: %v", err)
+	}
+	if claims["sub"] != "20210001" {
+		t.Fatalf("sub = %v", claims["sub"])
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": idp.server.URL, "aud": "client", "nonce": "n1", "exp": time.Now().Add(time.Minute).Unix()})
+	forged.Header["kid"] = idp.kid
+	forgedRaw, _ := forged.SignedString(other)
+
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": idp.server.URL, "aud": "client", "nonce": "n1", "exp": time.Now().Add(time.Minute).Unix()})
+	hmacRaw, _ := hmac.SignedString([]byte("secret"))
+
+	cases := map[string]struct {
+		raw   string
+		nonce string
+	}{
+		"签名密钥不符":      {forgedRaw, "n1"},
+		"HMAC 签名":     {hmacRaw, "n1"},
+		"issuer 不符":   {idp.sign(t, jwt.MapClaims{"iss": "https://evil.example"}), "n1"},
+		"audience 不符": {idp.sign(t, jwt.MapClaims{"aud": "other"}), "n1"},
+		"已过期":         {idp.sign(t, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), "n1"},
+		"缺少过期时间":      {idp.sign(t, jwt.MapClaims{"exp": nil}), "n1"},
+		"nonce 不符":    {idp.sign(t, nil), "n2"},
+	}
+	for name, tc := range cases {
+		if _, err := p.VerifyIDToken(tc.raw, tc.nonce); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+}
+
+func TestOIDCRefreshesKeysOnUnknownKid(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	if _, err := p.VerifyIDToken(idp.sign(t, nil), "n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 身份提供方轮换密钥后，未知的 kid 触发重新获取 JWKS
+	idp.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	idp.kid = "k2"
+	hits := idp.jwksHits
+	if _, err := p.VerifyIDToken(idp.sign(t, nil), "n1"); err != nil {
+		t.Fatalf("轮换密钥后应通过: %v", err)
+	}
+	if idp.jwksHits != hits+1 {
+		t.Fatalf("应重新获取一次 JWKS，实际 %d 次", idp.jwksHits-hits)
+	}
+}
+
+func TestOIDCAuthCodeFlow(t *testing.T) {
+	idp := newMockIdP(t)
+	p := idp.provider()
+	idp.verifier = "verifier-123"
+	idp.idToken = idp.sign(t, nil)
+
+	authURL, err := p.AuthCodeURL("s1", "n1", idp.verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	query := u.Query()
+	if !strings.HasSuffix(u.Path, "/authorize") || query.Get("state") != "s1" || query.Get("nonce") != "n1" {
+		t.Fatalf("授权地址有误: %s", authURL)
+	}
+	if query.Get("code_challenge") != PKCEChallenge(idp.verifier) || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("缺少 PKCE 参数: %s", authURL)
+	}
+
+	raw, err := p.Exchange("good-code", idp.verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err := p.VerifyIDToken(raw, "n1"); err != nil {
+		t.Fatalf("换取的 ID Token 应通过校验: %v", err)
+	}
+	if _, err := p.Exchange("good-code", "wrong-verifier"); err == nil {
+		t.Fatal("code_verifier 不符时应失败")
+	}
+	p.ClientSecret = "wrong"
+	if _, err := p.Exchange("good-code", idp.verifier); err == nil {
+		t.Fatal("客户端密钥错误时应失败")
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+)
+
+// ImageToPDF 将图片逐页嵌入 PDF，每页按 A4 长边缩放并保持图片比例
+func ImageToPDF(pages ...image.Image) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, errors.New("PDF 至少需要一页")
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	writeObject := func(id int, body string, stream []byte) {
+		for len(offsets) < id {
+			offsets = append(offsets, 0)
+		}
+		offsets[id-1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", id, body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	// 对象编号：1 Catalog，2 Pages，之后每页依次为 Page、图片、内容流
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 3+3*i)
+	}
+	buf.WriteString("%PDF-1.4\n")
+	writeObject(1, "<< /Type /Catalog /Pages 2 0 R >>", nil)
+	writeObject(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)), nil)
+
+	for i, img := range pages {
+		var jpg bytes.Buffer
+		if err := jpeg.Encode(&jpg, img, &jpeg.Options{Quality: 92}); err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		w, h := float64(bounds.Dx()), float64(bounds.Dy())
+		scale := 842 / w // A4 长边 842pt
+		if h > w {
+			scale = 842 / h
+		}
+		pageW, pageH := w*scale, h*scale
+
+		page := 3 + 3*i
+		writeObject(page, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>", pageW, pageH, page+1, page+2), nil)
+		writeObject(page+1, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", bounds.Dx(), bounds.Dy(), jpg.Len()), jpg.Bytes())
+		content := []byte(fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", pageW, pageH))
+		writeObject(page+2, fmt.Sprintf("<< /Length %d >>", len(content)), content)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes(), nil
+}
@@ -1,61 +1,80 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
 	"github.com/qiniu/go-sdk/v7/cdn"
 	"github.com/qiniu/go-sdk/v7/storage"
+	"io"
+	"net/http"
 	"time"
 )
 
-// 七牛云的访问密钥、桶名和域名
-var (
-	accessKey = "your_access_key"
-	secretKey = "your_secret_key"
-	bucket    = "your_bucket"
-	domain    = "your_domain"
-)
+// QiniuStorage 七牛云对象存储后端
+type QiniuStorage struct {
+	bucket        string
+	domain        string
+	mac           *qbox.Mac
+	bucketManager *storage.BucketManager
+	cdnManager    *cdn.CdnManager
+}
 
-// 初始化 Mac、配置、桶管理器和 CDN 管理器
-var mac = qbox.NewMac(accessKey, secretKey)
-var cfg = storage.Config{}
-var bucketManager = storage.NewBucketManager(mac, &cfg)
-var cdnManager = cdn.NewCdnManager(mac)
+// NewQiniuStorage 初始化 Mac、桶管理器和 CDN 管理器
+func NewQiniuStorage(accessKey, secretKey, bucket, domain string) *QiniuStorage {
+	mac := qbox.NewMac(accessKey, secretKey)
+	cfg := storage.Config{}
+	return &QiniuStorage{
+		bucket:        bucket,
+		domain:        domain,
+		mac:           mac,
+		bucketManager: storage.NewBucketManager(mac, &cfg),
+		cdnManager:    cdn.NewCdnManager(mac),
+	}
+}
 
-// GetToken 生成上传令牌
-func GetToken(name string) string {
+// PresignUpload 生成上传令牌
+func (q *QiniuStorage) PresignUpload(key string, expires time.Duration) (*UploadCredential, error) {
 	putPolicy := storage.PutPolicy{
-		Scope:   fmt.Sprintf("%s:%s", bucket, name),
-		Expires: 3600, // 令牌有效期为1小时
+		Scope:   fmt.Sprintf("%s:%s", q.bucket, key),
+		Expires: uint64(expires.Seconds()),
 	}
-	return putPolicy.UploadToken(mac)
+	return &UploadCredential{Token: putPolicy.UploadToken(q.mac)}, nil
 }
 
-// GetFileUrl 生成下载链接
-func GetFileUrl(filename string) string {
-	deadline := time.Now().Add(time.Minute).Unix() // 链接有效期为1分钟
-	return storage.MakePrivateURL(mac, domain, filename, deadline)
+// PresignDownload 生成私有空间的下载链接
+func (q *QiniuStorage) PresignDownload(key string, expires time.Duration) string {
+	deadline := time.Now().Add(expires).Unix()
+	return storage.MakePrivateURL(q.mac, q.domain, key, deadline)
 }
 
-// RefreshUrl 刷新 CDN 缓存
-func RefreshUrl(name string) error {
-	urls := []string{fmt.Sprintf("%s/%s", domain, name)}
-	_, err := cdnManager.RefreshUrls(urls)
-	return err
+// Put 通过表单上传写入文件
+func (q *QiniuStorage) Put(key string, r io.Reader, size int64, mimeType string) error {
+	putPolicy := storage.PutPolicy{
+		Scope:   fmt.Sprintf("%s:%s", q.bucket, key),
+		Expires: 3600,
+	}
+	uploader := storage.NewFormUploader(&storage.Config{})
+	var ret storage.PutRet
+	return uploader.Put(context.Background(), &ret, putPolicy.UploadToken(q.mac), key, r, size, &storage.PutExtra{MimeType: mimeType})
 }
 
-// FileInfo 文件信息结构体
-type FileInfo struct {
-	Fsize    int64
-	Hash     string
-	MimeType string
-	PutTime  int64
-	Type     int
+// Get 通过私有下载链接读取文件内容
+func (q *QiniuStorage) Get(key string) (io.ReadCloser, error) {
+	resp, err := http.Get(q.PresignDownload(key, time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("下载文件失败: %s", resp.Status)
+	}
+	return resp.Body, nil
 }
 
-// GetFileInfo 获取文件信息
-func GetFileInfo(name string) (*FileInfo, error) {
-	fileInfo, err := bucketManager.Stat(bucket, name)
+// Stat 获取文件信息
+func (q *QiniuStorage) Stat(key string) (*FileInfo, error) {
+	fileInfo, err := q.bucketManager.Stat(q.bucket, key)
 	if err != nil {
 		return nil, err
 	}
@@ -69,13 +88,47 @@ func GetFileInfo(name string) (*FileInfo, error) {
 	}, nil
 }
 
-// DeleteFile 删除文件
-func DeleteFile(names []string) error {
-	deleteOps := make([]string, len(names))
-	for i, name := range names {
-		deleteOps[i] = storage.URIDelete(bucket, name)
+// Delete 批量删除文件
+func (q *QiniuStorage) Delete(keys []string) error {
+	deleteOps := make([]string, len(keys))
+	for i, key := range keys {
+		deleteOps[i] = storage.URIDelete(q.bucket, key)
 	}
 
-	_, err := bucketManager.Batch(deleteOps)
+	_, err := q.bucketManager.Batch(deleteOps)
+	return err
+}
+
+// Refresh 刷新 CDN 缓存
+func (q *QiniuStorage) Refresh(key string) error {
+	urls := []string{fmt.Sprintf("%s/%s", q.domain, key)}
+	_, err := q.cdnManager.RefreshUrls(urls)
 	return err
 }
+
+// List 分页遍历桶中的文件
+func (q *QiniuStorage) List(prefix string, fn func(key string, info FileInfo) error) error {
+	marker := ""
+	for {
+		entries, _, nextMarker, hasNext, err := q.bucketManager.ListFiles(q.bucket, prefix, "", marker, 1000)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			info := FileInfo{
+				Fsize:    entry.Fsize,
+				Hash:     entry.Hash,
+				MimeType: entry.MimeType,
+				PutTime:  entry.PutTime,
+				Type:     entry.Type,
+			}
+			if err := fn(entry.Key, info); err != nil {
+				return err
+			}
+		}
+		if !hasNext {
+			return nil
+		}
+		marker = nextMarker
+	}
+}
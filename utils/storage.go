@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"competition-server/config"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Storage 文件存储后端，上传下载均通过带有效期的签名凭证完成
+type Storage interface {
+	// PresignUpload 生成指定对象的上传凭证
+	PresignUpload(key string, expires time.Duration) (*UploadCredential, error)
+	// PresignDownload 生成指定对象的限时下载链接
+	PresignDownload(key string, expires time.Duration) string
+	// Put 由服务端直接写入对象
+	Put(key string, r io.Reader, size int64, mimeType string) error
+	// Get 由服务端读取对象内容，调用方负责关闭
+	Get(key string) (io.ReadCloser, error)
+	// Stat 获取对象元数据
+	Stat(key string) (*FileInfo, error)
+	// Delete 批量删除对象
+	Delete(keys []string) error
+	// Refresh 刷新对象的 CDN 缓存
+	Refresh(key string) error
+	// List 遍历指定前缀下的所有对象，fn 返回错误时停止遍历
+	List(prefix string, fn func(key string, info FileInfo) error) error
+}
+
+// UploadCredential 上传凭证，七牛云使用 Token，本地存储使用签名后的 URL
+type UploadCredential struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// FileInfo 文件信息结构体
+type FileInfo struct {
+	Fsize    int64
+	Hash     string
+	MimeType string
+	PutTime  int64
+	Type     int
+}
+
+// FileStorage 当前使用的存储后端，由 InitStorage 根据配置初始化
+var FileStorage Storage
+
+// InitStorage 根据配置选择存储后端
+func InitStorage() error {
+	switch config.StorageBackend {
+	case "qiniu":
+		FileStorage = NewQiniuStorage(config.QiniuAccessKey, config.QiniuSecretKey, config.QiniuBucket, config.QiniuDomain)
+	case "local":
+		local, err := NewLocalStorage(config.LocalStorageDir, config.LocalStorageBaseURL, config.LocalStorageSignKey)
+		if err != nil {
+			return err
+		}
+		FileStorage = local
+	default:
+		return fmt.Errorf("未知的存储后端: %s", config.StorageBackend)
+	}
+	return nil
+}
+
+// GetToken 生成上传令牌
+func GetToken(name string) (*UploadCredential, error) {
+	return FileStorage.PresignUpload(name, time.Hour) // 令牌有效期为1小时
+}
+
+// GetFileUrl 生成下载链接
+func GetFileUrl(filename string) string {
+	return FileStorage.PresignDownload(filename, time.Minute) // 链接有效期为1分钟
+}
+
+// RefreshUrl 刷新 CDN 缓存
+func RefreshUrl(name string) error {
+	return FileStorage.Refresh(name)
+}
+
+// GetFileInfo 获取文件信息
+func GetFileInfo(name string) (*FileInfo, error) {
+	return FileStorage.Stat(name)
+}
+
+// DeleteFile 删除文件
+func DeleteFile(names []string) error {
+	return FileStorage.Delete(names)
+}
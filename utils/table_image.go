@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	tableFontSize  = 16
+	tableTitleSize = 22
+	tableRowHeight = 32
+	tablePadding   = 12
+	tableMargin    = 30
+	tablePageRows  = 30 // 每页最多的数据行数，表头在每页重复
+)
+
+// RenderTable 将表格按页绘制为图片，第一行为表头，用于导出 PDF 报表
+// 各页列宽一致，表头在每页重复，页数大于 1 时标题后注明页码
+func RenderTable(title string, rows [][]string) ([]image.Image, error) {
+	ttf, err := loadFont()
+	if err != nil {
+		return nil, err
+	}
+	texts := []string{title}
+	for _, row := range rows {
+		texts = append(texts, row...)
+	}
+	if err := checkGlyphs(ttf, texts...); err != nil {
+		return nil, err
+	}
+	face := truetype.NewFace(ttf, &truetype.Options{Size: tableFontSize, DPI: 72, Hinting: font.HintingFull})
+	defer face.Close()
+	titleFace := truetype.NewFace(ttf, &truetype.Options{Size: tableTitleSize, DPI: 72, Hinting: font.HintingFull})
+	defer titleFace.Close()
+
+	// 按每列最长的文字计算列宽
+	var widths []int
+	for _, row := range rows {
+		for j, cell := range row {
+			w := font.MeasureString(face, cell).Ceil() + 2*tablePadding
+			if j >= len(widths) {
+				widths = append(widths, w)
+			} else if w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+
+	var header []string
+	body := rows
+	if len(rows) > 0 {
+		header, body = rows[0], rows[1:]
+	}
+	pageCount := (len(body) + tablePageRows - 1) / tablePageRows
+	if pageCount == 0 {
+		pageCount = 1
+	}
+	pages := make([]image.Image, 0, pageCount)
+	for i := 0; i < pageCount; i++ {
+		end := (i + 1) * tablePageRows
+		if end > len(body) {
+			end = len(body)
+		}
+		pageRows := body[i*tablePageRows : end]
+		if header != nil {
+			pageRows = append([][]string{header}, pageRows...)
+		}
+		pageTitle := title
+		if pageCount > 1 {
+			pageTitle = fmt.Sprintf("%s（第 %d/%d 页）", title, i+1, pageCount)
+		}
+		pages = append(pages, renderTablePage(face, titleFace, pageTitle, pageRows, widths))
+	}
+	return pages, nil
+}
+
+// renderTablePage 绘制一页表格，rows 的第一行为表头
+func renderTablePage(face, titleFace font.Face, title string, rows [][]string, widths []int) *image.RGBA {
+	tableWidth := 0
+	for _, w := range widths {
+		tableWidth += w
+	}
+	titleWidth := font.MeasureString(titleFace, title).Ceil()
+	width := tableWidth
+	if titleWidth > width {
+		width = titleWidth
+	}
+	width += 2 * tableMargin
+	titleHeight := tableTitleSize * 2
+	height := 2*tableMargin + titleHeight + len(rows)*tableRowHeight
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	black := image.NewUniform(color.Black)
+	(&font.Drawer{Dst: img, Src: black, Face: titleFace, Dot: fixed.P((width-titleWidth)/2, tableMargin+tableTitleSize)}).DrawString(title)
+
+	top := tableMargin + titleHeight
+	header := image.NewUniform(color.RGBA{R: 0xee, G: 0xee, B: 0xee, A: 0xff})
+	if len(rows) > 0 {
+		draw.Draw(img, image.Rect(tableMargin, top, tableMargin+tableWidth, top+tableRowHeight), header, image.Point{}, draw.Src)
+	}
+	for i, row := range rows {
+		x := tableMargin
+		baseline := top + i*tableRowHeight + (tableRowHeight+tableFontSize)/2 - 2
+		for j, cell := range row {
+			(&font.Drawer{Dst: img, Src: black, Face: face, Dot: fixed.P(x+tablePadding, baseline)}).DrawString(cell)
+			x += widths[j]
+		}
+	}
+
+	// 表格线
+	line := image.NewUniform(color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xff})
+	bottom := top + len(rows)*tableRowHeight
+	for i := 0; i <= len(rows); i++ {
+		y := top + i*tableRowHeight
+		draw.Draw(img, image.Rect(tableMargin, y, tableMargin+tableWidth+1, y+1), line, image.Point{}, draw.Src)
+	}
+	x := tableMargin
+	for j := 0; j <= len(widths); j++ {
+		draw.Draw(img, image.Rect(x, top, x+1, bottom), line, image.Point{}, draw.Src)
+		if j < len(widths) {
+			x += widths[j]
+		}
+	}
+	return img
+}
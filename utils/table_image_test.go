@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"competition-server/config"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// useFontPath 临时切换字体文件
+func useFontPath(t *testing.T, path string) {
+	t.Helper()
+	previous := config.CertificateFontPath
+	config.CertificateFontPath = path
+	t.Cleanup(func() { config.CertificateFontPath = previous })
+}
+
+// inkedPixels 统计区域内非白色的像素数
+func inkedPixels(img image.Image, rect image.Rectangle) int {
+	count := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestDefaultFontCoversChinese(t *testing.T) {
+	useFontPath(t, "")
+	ttf, err := loadFont()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := missingGlyphs(ttf, "张三 李四 欧阳娜娜 一等奖 2024学年"); missing != "" {
+		t.Fatalf("内置字体缺少字形: %s", missing)
+	}
+}
+
+func TestLoadFontRejectsFontWithoutChinese(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Go-Regular.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	useFontPath(t, path)
+	if err := InitFont(); err == nil {
+		t.Fatal("不含中文字形的字体应报错")
+	}
+	if _, err := RenderTable("教师报表", [][]string{{"姓名"}, {"张三"}}); err == nil {
+		t.Fatal("字体不含中文时导出应失败，而不是画出方框")
+	}
+
+	useFontPath(t, filepath.Join(t.TempDir(), "missing.ttf"))
+	if err := InitFont(); err == nil {
+		t.Fatal("字体文件不存在应报错")
+	}
+}
+
+func TestRenderTableRejectsMissingGlyphs(t *testing.T) {
+	useFontPath(t, "")
+	_, err := RenderTable("报表", [][]string{{"姓名"}, {"\U0001F600"}})
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("字体没有的字形应返回校验错误，得到 %v", err)
+	}
+}
+
+func TestRenderTablePaginates(t *testing.T) {
+	useFontPath(t, "")
+	rows := [][]string{{"姓名", "积分"}}
+	for i := 0; i < tablePageRows*2+5; i++ {
+		rows = append(rows, []string{fmt.Sprintf("张三%d", i), "3"})
+	}
+	pages, err := RenderTable("教师报表", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("%d 行数据应分为 3 页，得到 %d", len(rows)-1, len(pages))
+	}
+	for i, page := range pages {
+		// 每页第一行是重复的表头
+		headerRow := image.Rect(tableMargin, tableMargin+tableTitleSize*2+2, tableMargin+200, tableMargin+tableTitleSize*2+tableRowHeight-2)
+		if inkedPixels(page, headerRow) == 0 {
+			t.Fatalf("第 %d 页缺少表头", i+1)
+		}
+	}
+	if got, want := pages[2].Bounds().Dy(), 2*tableMargin+tableTitleSize*2+6*tableRowHeight; got != want {
+		t.Fatalf("最后一页应只有表头和 5 行数据，高度 %d，应为 %d", got, want)
+	}
+
+	pdf, err := ImageToPDF(pages...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(pdf, []byte("/Type /Page ")); n != 3 {
+		t.Fatalf("PDF 应有 3 页，得到 %d", n)
+	}
+	if !bytes.Contains(pdf, []byte("/Count 3")) {
+		t.Fatal("页面树的页数有误")
+	}
+}
+
+func TestRenderTableDrawsChinese(t *testing.T) {
+	useFontPath(t, "")
+	pages, err := RenderTable("竞赛档案", [][]string{{"姓名"}, {"张三"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("应只有一页，得到 %d", len(pages))
+	}
+	top := tableMargin + tableTitleSize*2 + tableRowHeight
+	if inkedPixels(pages[0], image.Rect(tableMargin+2, top+2, pages[0].Bounds().Dx()-tableMargin-2, top+tableRowHeight-2)) == 0 {
+		t.Fatal("应绘制出中文姓名")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"competition-server/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// UploadResult 服务端上传结果
+type UploadResult struct {
+	Key          string `json:"key"`
+	Name         string `json:"name"`
+	Size         int64  `json:"size"`
+	Hash         string `json:"hash"`
+	MimeType     string `json:"mime_type"`
+	Deduplicated bool   `json:"deduplicated"` // 相同内容已上传过，本次未重复写入
+}
+
+// 常见文件类型对应的扩展名，其余类型交给 mime 包推断
+var mimeExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+}
+
+// SaveUpload 校验并保存上传的文件
+// 文件先写入临时文件并计算 SHA-256，对象名为 用途/上传者/哈希值.扩展名，
+// 因此上传者之间不会互相覆盖，同一用户重复上传相同内容时直接复用已有对象
+func SaveUpload(purpose, owner, filename string, r io.Reader) (*UploadResult, error) {
+	policy, ok := config.UploadPolicies[purpose]
+	if !ok {
+		return nil, &config.ValidationError{Message: "未知的上传用途"}
+	}
+	if owner == "" || strings.ContainsAny(owner, `/\`) || strings.Contains(owner, "..") {
+		return nil, &config.ValidationError{Message: "非法的上传者"}
+	}
+	name := strings.TrimSpace(filename)
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, &config.ValidationError{Message: "非法的文件名"}
+	}
+
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, policy.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, &config.ValidationError{Message: "文件为空"}
+	}
+	if size > policy.MaxSize {
+		return nil, &config.ValidationError{Message: fmt.Sprintf("文件大小不能超过%dMB", policy.MaxSize>>20)}
+	}
+
+	// 按文件内容探测类型
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	allowed := false
+	for _, t := range policy.AllowedTypes {
+		if t == mimeType {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, &config.ValidationError{Message: "不支持的文件类型: " + mimeType}
+	}
+
+	ext, ok := mimeExtensions[mimeType]
+	if !ok {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	result := &UploadResult{
+		Key:      path.Join(purpose, owner, sum+ext),
+		Name:     name,
+		Size:     size,
+		Hash:     sum,
+		MimeType: mimeType,
+	}
+
+	if _, err := FileStorage.Stat(result.Key); err == nil {
+		result.Deduplicated = true
+		return result, nil
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := FileStorage.Put(result.Key, tmp, size, mimeType); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WriteXLSX 将表格写为只有一个工作表的 xlsx 文件，数值写为数字，其余写为文本
+func WriteXLSX(w io.Writer, sheet string, rows [][]string) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+		{"xl/workbook.xml", fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`, escapeXML(sheet))},
+		{"xl/worksheets/sheet1.xml", sheetXML(rows)},
+	}
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// sheetXML 生成工作表内容，第一行为表头始终写为文本
+func sheetXML(rows [][]string) string {
+	var buf strings.Builder
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&buf, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			ref := columnName(j) + strconv.Itoa(i+1)
+			if i > 0 && isNumericCell(cell) {
+				fmt.Fprintf(&buf, `<c r="%s"><v>%s</v></c>`, ref, cell)
+			} else {
+				fmt.Fprintf(&buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escapeXML(cell))
+			}
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData></worksheet>`)
+	return buf.String()
+}
+
+// isNumericCell 判断单元格是否写为数字，学号、工号等以 0 开头或过长的数字串仍按文本处理
+// NaN、Inf 和十六进制等 ParseFloat 能识别但 Excel 不能识别的写法也按文本处理，否则文件会损坏
+func isNumericCell(cell string) bool {
+	if cell == "" || len(cell) > 15 || strings.Trim(cell, "+-.0123456789eE") != "" {
+		return false
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+	return !(len(cell) > 1 && cell[0] == '0' && cell[1] != '.')
+}
+
+// columnName 列序号转换为 A、B、...、Z、AA 形式
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestIsNumericCell(t *testing.T) {
+	cases := map[string]bool{
+		"12":               true,
+		"-3.5":             true,
+		"0.5":              true,
+		"1e3":              true,
+		"0":                true,
+		"20210001":         true,
+		"020210001":        false, // 以 0 开头的学号
+		"1234567890123456": false, // 超出 Excel 精度
+		"":                 false,
+		"NaN":              false,
+		"nan":              false,
+		"Inf":              false,
+		"-Infinity":        false,
+		"0x1p3":            false,
+		"1_000":            false,
+		"1e999":            false,
+		"一等奖":              false,
+	}
+	for cell, want := range cases {
+		if got := isNumericCell(cell); got != want {
+			t.Errorf("isNumericCell(%q) = %v，应为 %v", cell, got, want)
+		}
+	}
+}